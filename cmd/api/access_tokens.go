@@ -0,0 +1,164 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/pangdfg/gopher-social/internal/auth"
+	"github.com/pangdfg/gopher-social/internal/store"
+)
+
+// Scopes a personal access token can be granted. JWT sessions are not
+// scoped and pass every check.
+const (
+	scopeRead          = "read"
+	scopePostsWrite    = "posts:write"
+	scopeCommentsWrite = "comments:write"
+	scopeUsersWrite    = "users:write"
+	scopeTagsWrite     = "tags:write"
+)
+
+type CreateAccessTokenPayload struct {
+	Name          string   `json:"name" validate:"required,max=100"`
+	Scopes        []string `json:"scopes" validate:"required,min=1,dive,oneof=read posts:write comments:write users:write tags:write"`
+	ExpiresInDays *int     `json:"expires_in_days" validate:"omitempty,min=1,max=365"`
+}
+
+type AccessTokenResponse struct {
+	ID         uint       `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type CreatedAccessTokenResponse struct {
+	AccessTokenResponse
+	Token string `json:"token"`
+}
+
+func newAccessTokenResponse(t *store.AccessToken) AccessTokenResponse {
+	return AccessTokenResponse{
+		ID:         t.ID,
+		Name:       t.Name,
+		Prefix:     t.Prefix,
+		Scopes:     t.Scopes,
+		ExpiresAt:  t.ExpiresAt,
+		LastUsedAt: t.LastUsedAt,
+		CreatedAt:  t.CreatedAt,
+	}
+}
+
+// createAccessTokenHandler godoc
+//
+//	@Summary		Creates a personal access token
+//	@Description	Creates a named, scoped token for scripts and integrations. The token is only returned by this call.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreateAccessTokenPayload	true	"Token payload"
+//	@Success		201		{object}	CreatedAccessTokenResponse
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/tokens [post]
+func (app *application) createAccessTokenHandler(c *fiber.Ctx) error {
+	var payload CreateAccessTokenPayload
+	if err := readJSON(c, &payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+	if err := Validate.Struct(payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	plain, err := auth.GenerateAccessToken()
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	user := getUserFromContext(c)
+	token := &store.AccessToken{
+		UserID: user.ID,
+		Name:   payload.Name,
+		Prefix: plain[:len(auth.AccessTokenPrefix)+6],
+		Scopes: payload.Scopes,
+	}
+	if payload.ExpiresInDays != nil {
+		exp := time.Now().Add(time.Duration(*payload.ExpiresInDays) * 24 * time.Hour)
+		token.ExpiresAt = &exp
+	}
+
+	if err := app.store.AccessTokens.Create(c.Context(), token, plain); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return app.jsonResponse(c, fiber.StatusCreated, CreatedAccessTokenResponse{
+		AccessTokenResponse: newAccessTokenResponse(token),
+		Token:               plain,
+	})
+}
+
+// getAccessTokensHandler godoc
+//
+//	@Summary		Lists personal access tokens
+//	@Description	Lists the authenticated user's tokens without their secret values
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{array}		AccessTokenResponse
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/tokens [get]
+func (app *application) getAccessTokensHandler(c *fiber.Ctx) error {
+	user := getUserFromContext(c)
+
+	tokens, err := app.store.AccessTokens.GetByUserID(c.Context(), user.ID)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	res := make([]AccessTokenResponse, 0, len(tokens))
+	for i := range tokens {
+		res = append(res, newAccessTokenResponse(&tokens[i]))
+	}
+
+	return app.jsonResponse(c, fiber.StatusOK, res)
+}
+
+// deleteAccessTokenHandler godoc
+//
+//	@Summary		Revokes a personal access token
+//	@Description	Revokes one of the authenticated user's tokens
+//	@Tags			users
+//	@Produce		json
+//	@Param			tokenID	path		int		true	"Token ID"
+//	@Success		204		{string}	string	"Token revoked"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/tokens/{tokenID} [delete]
+func (app *application) deleteAccessTokenHandler(c *fiber.Ctx) error {
+	tokenID, err := strconv.ParseInt(c.Params("tokenID"), 10, 64)
+	if err != nil || tokenID < 1 {
+		return app.badRequestResponse(c, errors.New("invalid token id"))
+	}
+
+	user := getUserFromContext(c)
+	if err := app.store.AccessTokens.Delete(c.Context(), user.ID, uint(tokenID)); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			return app.notFoundResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/pangdfg/gopher-social/internal/store"
+)
+
+type DeleteAccountPayload struct {
+	Password string `json:"password" validate:"required,min=3,max=72"`
+}
+
+type AccountDeletionResponse struct {
+	DeletionRequestedAt time.Time `json:"deletion_requested_at"`
+	DeletionScheduledAt time.Time `json:"deletion_scheduled_at"`
+}
+
+// DeleteAccountHandler godoc
+//
+//	@Summary		Request account deletion
+//	@Description	Schedules the authenticated account for deletion after a grace period. Posts, comments, follows and tag links are removed with it.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		DeleteAccountPayload	true	"Current password"
+//	@Success		202		{object}	AccountDeletionResponse
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error	"Current password invalid"
+//	@Failure		409		{object}	error	"Deletion already requested"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me [delete]
+func (app *application) DeleteAccountHandler(c *fiber.Ctx) error {
+	var payload DeleteAccountPayload
+	if err := readJSON(c, &payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+	if err := Validate.Struct(payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	ctx := c.Context()
+	user, err := app.store.Users.GetCredentials(ctx, getUserFromContext(c).ID)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	if err := user.Authenticate(payload.Password); err != nil {
+		return app.unauthorizedErrorResponse(c, err)
+	}
+
+	now := time.Now()
+	if err := app.store.Users.RequestDeletion(ctx, user.ID, now); err != nil {
+		switch err {
+		case store.ErrConflict:
+			return app.conflictResponse(c, fmt.Errorf("account deletion already requested"))
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	return app.jsonResponse(c, fiber.StatusAccepted, AccountDeletionResponse{
+		DeletionRequestedAt: now,
+		DeletionScheduledAt: now.Add(app.config.accounts.deletionGrace),
+	})
+}
+
+// CancelAccountDeletionHandler godoc
+//
+//	@Summary		Cancel account deletion
+//	@Description	Cancels a pending account deletion during its grace period
+//	@Tags			users
+//	@Produce		json
+//	@Success		204	{string}	string	"Deletion cancelled"
+//	@Failure		404	{object}	error	"No deletion pending"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/cancel-deletion [post]
+func (app *application) CancelAccountDeletionHandler(c *fiber.Ctx) error {
+	user := getUserFromContext(c)
+
+	if err := app.store.Users.CancelDeletion(c.Context(), user.ID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			return app.notFoundResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ExportAccountHandler godoc
+//
+//	@Summary		Export account data
+//	@Description	Downloads a zip archive with everything stored about the authenticated user, trashed posts and comments, revisions, media and mentions included, as JSON plus CSV tables
+//	@Tags			users
+//	@Produce		application/zip
+//	@Success		200	{file}		file
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/export [get]
+func (app *application) ExportAccountHandler(c *fiber.Ctx) error {
+	user := getUserFromContext(c)
+
+	data, err := app.store.Exports.GetUserData(c.Context(), user.ID)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	var buf bytes.Buffer
+	if err := writeUserExport(&buf, data); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	filename := fmt.Sprintf("gophersocial-%s-%s.zip", user.Username, data.ExportedAt.Format("20060102"))
+	c.Set(fiber.HeaderContentType, "application/zip")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, filename))
+	return c.Status(fiber.StatusOK).Send(buf.Bytes())
+}
+
+// writeUserExport writes export.json with the full data set and one CSV
+// per table for spreadsheet users.
+func writeUserExport(w io.Writer, data *store.UserExport) error {
+	zw := zip.NewWriter(w)
+
+	f, err := zw.Create("export.json")
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(data); err != nil {
+		return err
+	}
+
+	posts := [][]string{{"id", "title", "content", "tags", "created_at", "updated_at"}}
+	for _, p := range data.Posts {
+		tags := make([]string, 0, len(p.Tags))
+		for _, t := range p.Tags {
+			tags = append(tags, t.Title)
+		}
+		posts = append(posts, []string{
+			strconv.FormatUint(uint64(p.ID), 10),
+			p.Title,
+			p.Content,
+			strings.Join(tags, ";"),
+			p.CreatedAt.Format(time.RFC3339),
+			p.UpdatedAt.Format(time.RFC3339),
+		})
+	}
+
+	comments := [][]string{{"id", "post_id", "content", "created_at"}}
+	for _, cm := range data.Comments {
+		comments = append(comments, []string{
+			strconv.FormatUint(uint64(cm.ID), 10),
+			strconv.FormatUint(uint64(cm.PostID), 10),
+			cm.Content,
+			cm.CreatedAt.Format(time.RFC3339),
+		})
+	}
+
+	tables := []struct {
+		name string
+		rows [][]string
+	}{
+		{"posts.csv", posts},
+		{"comments.csv", comments},
+		{"followers.csv", idRows("follower_id", data.Followers)},
+		{"following.csv", idRows("user_id", data.Following)},
+		{"followed_tags.csv", idRows("tag_id", data.FollowedTags)},
+	}
+
+	for _, t := range tables {
+		f, err := zw.Create(t.name)
+		if err != nil {
+			return err
+		}
+		cw := csv.NewWriter(f)
+		if err := cw.WriteAll(t.rows); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+func idRows(header string, ids []uint) [][]string {
+	rows := make([][]string, 0, len(ids)+1)
+	rows = append(rows, []string{header})
+	for _, id := range ids {
+		rows = append(rows, []string{strconv.FormatUint(uint64(id), 10)})
+	}
+	return rows
+}
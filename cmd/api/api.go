@@ -10,8 +10,12 @@ import (
 	swagger "github.com/arsmn/fiber-swagger/v2"
 	_ "github.com/pangdfg/gopher-social/doc"
 	"github.com/pangdfg/gopher-social/internal/env"
+	"github.com/pangdfg/gopher-social/internal/media"
 )
 
+// mediaPath is where files of the local blob store are served.
+const mediaPath = "/media"
+
 
 func mount(c *fiber.App, app *application) {
 
@@ -21,9 +25,9 @@ func mount(c *fiber.App, app *application) {
 	c.Use(logger.New())      
 	c.Use(cors.New(cors.Config{
 		AllowOrigins:     env.GetString("CORS_ALLOWED_ORIGIN", "http://localhost:5174"),
-		AllowMethods:     "GET,POST,PUT,DELETE,OPTIONS",
-		AllowHeaders:     "Accept,Authorization,Content-Type,X-CSRF-Token",
-		ExposeHeaders:    "Link",
+		AllowMethods:     "GET,POST,PUT,PATCH,DELETE,OPTIONS",
+		AllowHeaders:     "Accept,Authorization,Content-Type,X-CSRF-Token,If-Match",
+		ExposeHeaders:    "Link,ETag",
 		AllowCredentials: false,
 		MaxAge:           300,
 	}))
@@ -32,6 +36,20 @@ func mount(c *fiber.App, app *application) {
 		c.Use(app.RateLimiterMiddleware)
 	}
 
+	//Public signing keys for services verifying our tokens
+	c.Get("/.well-known/jwks.json", app.jwksHandler)
+
+	//Uploaded media when stored on local disk
+	if local, ok := app.blobs.(*media.LocalStore); ok {
+		c.Static(mediaPath, local.Root(), fiber.Static{
+			MaxAge: 31536000,
+			ModifyResponse: func(c *fiber.Ctx) error {
+				c.Set(fiber.HeaderXContentTypeOptions, "nosniff")
+				return nil
+			},
+		})
+	}
+
 	//API v1 routes
 	v1 := c.Group("/v1")
 
@@ -42,47 +60,112 @@ func mount(c *fiber.App, app *application) {
 	v1.Get("/swagger/*", swagger.New())
 
 	//feed
-	v1.Get("/feed", app.getFeedHandler)
+	v1.Get("/feed", app.optionalAuth, app.getFeedHandler)
+	v1.Get("/feed/timeline", app.AuthTokenMiddleware, app.requireScope(scopeRead), app.getTimelineHandler)
 
 	//tag
 	tag := v1.Group("/tags")
 
 	tag.Get("/", app.getTagTitleHandler)
-	tag.Get("/:tagID", app.getTagHandler)
-
-	tag.Delete("/:tagID",app.AuthTokenMiddleware, app.deleteTagHandler)
+	tag.Get("/trending", app.getTrendingTagsHandler)
+	tag.Get("/following", app.AuthTokenMiddleware, app.requireScope(scopeRead), app.getFollowedTagsHandler)
+	tag.Get("/following/feed", app.AuthTokenMiddleware, app.requireScope(scopeRead), app.getFollowedTagsFeedHandler)
+	tag.Get("/:tagID", app.optionalAuth, app.getTagHandler)
+	tag.Put("/:tagID/follow", app.AuthTokenMiddleware, app.requireScope(scopeUsersWrite), app.followTagHandler)
+	tag.Put("/:tagID/unfollow", app.AuthTokenMiddleware, app.requireScope(scopeUsersWrite), app.unfollowTagHandler)
+
+	//Tag administration
+	tagAdmin := tag.Group("/:tagID", app.AuthTokenMiddleware, app.requireScope(scopeTagsWrite), app.requireRole("admin"))
+
+	tagAdmin.Patch("/", app.updateTagHandler)
+	tagAdmin.Delete("/", app.deleteTagHandler)
+	tagAdmin.Post("/merge", app.mergeTagHandler)
+	tagAdmin.Post("/aliases", app.createTagAliasHandler)
+	tagAdmin.Delete("/aliases/:aliasID", app.deleteTagAliasHandler)
 
 	//Users routes
 	users := v1.Group("/users")
 	
 	users.Put("/activate/:token", app.activateUserHandler)
+	users.Put("/confirm-email/:token", app.ConfirmEmailHandler)
 
 	users.Use(app.AuthTokenMiddleware)
-	users.Put("/update-username", app.updateUsernameHandler)
-	users.Put("/change-password", app.ChangePasswordHandler)
+	users.Put("/update-username", app.requireScope(scopeUsersWrite), app.updateUsernameHandler)
+	users.Put("/change-password", app.requireSession, app.ChangePasswordHandler)
+	users.Post("/change-email", app.requireSession, app.ChangeEmailHandler)
+
+	users.Post("/2fa/enroll", app.requireSession, app.enrollTwoFactorHandler)
+	users.Post("/2fa/confirm", app.requireSession, app.confirmTwoFactorHandler)
+	users.Post("/2fa/disable", app.requireSession, app.disableTwoFactorHandler)
+	users.Post("/2fa/recovery-codes", app.requireSession, app.regenerateRecoveryCodesHandler)
+
+	users.Get("/identities", app.requireScope(scopeRead), app.getIdentitiesHandler)
+
+	users.Patch("/me/profile", app.requireScope(scopeUsersWrite), app.UpdateProfileHandler)
+	users.Delete("/me", app.requireSession, app.DeleteAccountHandler)
+	users.Post("/me/cancel-deletion", app.requireSession, app.CancelAccountDeletionHandler)
+	users.Get("/me/export", app.requireSession, app.ExportAccountHandler)
+
+	tokens := users.Group("/tokens", app.requireSession)
+	tokens.Post("/", app.createAccessTokenHandler)
+	tokens.Get("/", app.getAccessTokensHandler)
+	tokens.Delete("/:tokenID", app.deleteAccessTokenHandler)
 	
 	user := users.Group("/:userID")
 
-	user.Get("/", app.getUserHandler)
-	//user.Put("/follow", app.followUserHandler)
-	//user.Put("/unfollow", app.unfollowUserHandler)
+	user.Get("/", app.requireScope(scopeRead), app.getUserHandler)
+	user.Put("/follow", app.requireScope(scopeUsersWrite), app.followUserHandler)
+	user.Put("/unfollow", app.requireScope(scopeUsersWrite), app.unfollowUserHandler)
 	
 
 	//Auth routes
 	auth := v1.Group("/auth")
 	auth.Post("/user", app.registerUserHandler)
 	auth.Post("/token", app.createTokenHandler)
+	auth.Post("/token/2fa", app.verifyTwoFactorHandler)
+	auth.Get("/oidc/:provider/login", app.oidcLoginHandler)
+	auth.Get("/oidc/:provider/callback", app.oidcCallbackHandler)
+
+	//Media routes
+	v1.Post("/media", app.AuthTokenMiddleware, app.uploadMediaHandler)
+
+	//Bookmarks routes
+	bookmarks := v1.Group("/bookmarks", app.AuthTokenMiddleware)
+
+	bookmarks.Get("/", app.requireScope(scopeRead), app.getBookmarksHandler)
+	bookmarks.Get("/collections", app.requireScope(scopeRead), app.getCollectionsHandler)
+	bookmarks.Post("/collections", app.requireScope(scopeUsersWrite), app.createCollectionHandler)
+	bookmarks.Patch("/collections/:collectionID", app.requireScope(scopeUsersWrite), app.renameCollectionHandler)
+	bookmarks.Delete("/collections/:collectionID", app.requireScope(scopeUsersWrite), app.deleteCollectionHandler)
+
+	//Trash routes
+	trash := v1.Group("/trash", app.AuthTokenMiddleware)
+	trash.Get("/posts", app.requireScope(scopeRead), app.getTrashedPostsHandler)
+	trash.Post("/posts/:postID/restore", app.requireScope(scopePostsWrite), app.restorePostHandler)
+	trash.Get("/comments", app.requireScope(scopeRead), app.getTrashedCommentsHandler)
+	trash.Post("/comments/:commentID/restore", app.requireScope(scopeCommentsWrite), app.restoreCommentHandler)
 
 	//Posts routes
+	v1.Get("/posts/trending", app.optionalAuth, app.getTrendingPostsHandler)
+
 	posts := v1.Group("/posts", app.AuthTokenMiddleware)
 
-	posts.Post("/", app.createPostHandler)
+	posts.Post("/", app.requireScope(scopePostsWrite), app.createPostHandler)
+	posts.Get("/drafts", app.requireScope(scopeRead), app.getDraftsHandler)
 
 	post := posts.Group("/:postID", app.postsContextMiddleware)
 
-	post.Get("/", app.getPostHandler)
+	post.Get("/", app.requireScope(scopeRead), app.getPostHandler)
 	
-	post.Post("/", app.createCommentHandler)
-	post.Patch("/", app.checkPostOwnership(), app.updatePostHandler)
-	post.Delete("/", app.checkPostOwnership(), app.deletePostHandler)
+	post.Post("/", app.requireScope(scopeCommentsWrite), app.createCommentHandler)
+	post.Delete("/comments/:commentID", app.requireScope(scopeCommentsWrite), app.deleteCommentHandler)
+	post.Post("/repost", app.requireScope(scopePostsWrite), app.repostHandler)
+	post.Delete("/repost", app.requireScope(scopePostsWrite), app.unrepostHandler)
+	post.Put("/bookmark", app.requireScope(scopeUsersWrite), app.bookmarkPostHandler)
+	post.Delete("/bookmark", app.requireScope(scopeUsersWrite), app.unbookmarkPostHandler)
+	post.Patch("/", app.requireScope(scopePostsWrite), app.checkPostOwnership("moderator"), app.updatePostHandler)
+	post.Delete("/", app.requireScope(scopePostsWrite), app.checkPostOwnership("admin"), app.deletePostHandler)
+	post.Get("/revisions", app.requireScope(scopeRead), app.getRevisionsHandler)
+	post.Get("/revisions/:version", app.requireScope(scopeRead), app.getRevisionHandler)
+	post.Post("/revisions/:version/restore", app.requireScope(scopePostsWrite), app.checkPostOwnership("moderator"), app.restoreRevisionHandler)
 }
\ No newline at end of file
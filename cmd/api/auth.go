@@ -138,7 +138,8 @@ type CreateUserTokenPayload struct {
 //	@Accept			json
 //	@Produce		json
 //	@Param			payload	body		CreateUserTokenPayload	true	"User credentials"
-//	@Success		200		{string}	string					"Token"
+//	@Success		201		{string}	string					"Token"
+//	@Success		202		{object}	TwoFactorChallengeResponse	"Second factor required"
 //	@Failure		400		{object}	error
 //	@Failure		401		{object}	error
 //	@Failure		500		{object}	error
@@ -163,6 +164,27 @@ func (app *application) createTokenHandler(c *fiber.Ctx) error {
 		return app.unauthorizedErrorResponse(c, err)
 	}
 
+	if user.TOTPEnabled {
+		challenge, err := app.issueTwoFactorChallenge(user)
+		if err != nil {
+			return app.internalServerError(c, err)
+		}
+
+		return c.Status(fiber.StatusAccepted).JSON(TwoFactorChallengeResponse{
+			TwoFactorRequired: true,
+			ChallengeToken:    challenge,
+		})
+	}
+
+	token, err := app.issueAccessToken(user)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(token)
+}
+
+func (app *application) issueAccessToken(user *store.User) (string, error) {
 	claims := jwt.MapClaims{
 		"sub": user.ID,
 		"role": user.Role.Name,
@@ -173,12 +195,7 @@ func (app *application) createTokenHandler(c *fiber.Ctx) error {
 		"aud": app.config.auth.token.iss,
 	}
 
-	token, err := app.authenticator.GenerateToken(claims)
-	if err != nil {
-		return app.internalServerError(c, err)
-	}
-
-	return c.Status(fiber.StatusCreated).JSON(token)
+	return app.authenticator.GenerateToken(claims)
 }
 
 type PasswordPayload struct {
@@ -209,7 +226,7 @@ func (app *application) ChangePasswordHandler(c *fiber.Ctx) error {
 		return app.badRequestResponse(c, err)
 	}
 
-	user, err := app.store.Users.GetByID(c.Context(), authUser.ID)
+	user, err := app.store.Users.GetCredentials(c.Context(), authUser.ID)
 	if err != nil {
 		switch err {
 		case store.ErrNotFound:
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/pangdfg/gopher-social/internal/store"
+)
+
+type BookmarkPayload struct {
+	CollectionID *uint `json:"collection_id"`
+}
+
+type CollectionPayload struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+type CollectionResponse struct {
+	ID             uint   `json:"id"`
+	Name           string `json:"name"`
+	BookmarksCount int64  `json:"bookmarks_count"`
+}
+
+// bookmarkPostHandler godoc
+//
+//	@Summary		Bookmarks a post
+//	@Description	Saves a post for the authenticated user, optionally into one of their collections. Bookmarking again moves the bookmark to the given collection, or out of any.
+//	@Tags			bookmarks
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int				true	"Post ID"
+//	@Param			payload	body		BookmarkPayload	false	"Collection"
+//	@Success		204		{string}	string			"Post bookmarked"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error	"Post or collection not found"
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/bookmark [put]
+func (app *application) bookmarkPostHandler(c *fiber.Ctx) error {
+	post := c.Locals("post").(*store.Post)
+	user := getUserFromContext(c)
+
+	if post.Status != store.PostStatusPublished {
+		return app.badRequestResponse(c, errors.New("unpublished posts can't be bookmarked"))
+	}
+
+	var payload BookmarkPayload
+	if len(c.Body()) > 0 {
+		if err := readJSON(c, &payload); err != nil {
+			return app.badRequestResponse(c, err)
+		}
+	}
+
+	b := &store.Bookmark{UserID: user.ID, PostID: post.ID, CollectionID: payload.CollectionID}
+	if err := app.store.Bookmarks.Add(c.Context(), b); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			return app.notFoundResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// unbookmarkPostHandler godoc
+//
+//	@Summary		Removes a bookmark
+//	@Description	Removes the authenticated user's bookmark of a post
+//	@Tags			bookmarks
+//	@Param			postID	path		int		true	"Post ID"
+//	@Success		204		{string}	string	"Bookmark removed"
+//	@Failure		404		{object}	error	"Post not bookmarked"
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/bookmark [delete]
+func (app *application) unbookmarkPostHandler(c *fiber.Ctx) error {
+	post := c.Locals("post").(*store.Post)
+	user := getUserFromContext(c)
+
+	if err := app.store.Bookmarks.Remove(c.Context(), user.ID, post.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			return app.notFoundResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// getBookmarksHandler godoc
+//
+//	@Summary		Lists bookmarked posts
+//	@Description	Lists the authenticated user's bookmarks, most recently saved first
+//	@Tags			bookmarks
+//	@Produce		json
+//	@Param			collection_id	query		int		false	"Only bookmarks in this collection"
+//	@Param			search			query		string	false	"Search"
+//	@Param			limit			query		int		false	"Limit"
+//	@Param			offset			query		int		false	"Offset"
+//	@Param			sort			query		string	false	"Sort by bookmark time (asc|desc)"
+//	@Success		200				{object}	FeedResponse
+//	@Failure		400				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/bookmarks [get]
+func (app *application) getBookmarksHandler(c *fiber.Ctx) error {
+	fq := store.PaginatedFeedQuery{
+		Limit:  20,
+		Offset: 0,
+		Sort:   "desc",
+		Tags:   []string{},
+	}
+
+	fq, err := fq.Parse(c)
+	if err != nil {
+		return app.badRequestResponse(c, err)
+	}
+	if err := Validate.Struct(fq); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	var collectionID *uint
+	if v := c.Query("collection_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return app.badRequestResponse(c, errors.New("invalid collection_id"))
+		}
+		cid := uint(id)
+		collectionID = &cid
+	}
+
+	user := getUserFromContext(c)
+	posts, err := app.store.Bookmarks.GetPosts(c.Context(), user.ID, collectionID, fq)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	response := NewPostListResponse(posts, fq.Limit, fq.Offset)
+	for i := range response.Posts {
+		response.Posts[i].Bookmarked = true
+	}
+
+	return app.jsonResponse(c, fiber.StatusOK, response)
+}
+
+// getCollectionsHandler godoc
+//
+//	@Summary		Lists bookmark collections
+//	@Description	Lists the authenticated user's bookmark collections by name
+//	@Tags			bookmarks
+//	@Produce		json
+//	@Success		200	{array}		CollectionResponse
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/bookmarks/collections [get]
+func (app *application) getCollectionsHandler(c *fiber.Ctx) error {
+	user := getUserFromContext(c)
+
+	cols, err := app.store.Bookmarks.GetCollections(c.Context(), user.ID)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	res := make([]CollectionResponse, 0, len(cols))
+	for _, col := range cols {
+		res = append(res, CollectionResponse{ID: col.ID, Name: col.Name, BookmarksCount: col.BookmarksCount})
+	}
+
+	return app.jsonResponse(c, fiber.StatusOK, res)
+}
+
+// createCollectionHandler godoc
+//
+//	@Summary		Creates a bookmark collection
+//	@Tags			bookmarks
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CollectionPayload	true	"Collection"
+//	@Success		201		{object}	CollectionResponse
+//	@Failure		400		{object}	error
+//	@Failure		409		{object}	error	"Name already used"
+//	@Security		ApiKeyAuth
+//	@Router			/bookmarks/collections [post]
+func (app *application) createCollectionHandler(c *fiber.Ctx) error {
+	var payload CollectionPayload
+	if err := readJSON(c, &payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+	if err := Validate.Struct(payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	user := getUserFromContext(c)
+	col := &store.BookmarkCollection{UserID: user.ID, Name: payload.Name}
+	if err := app.store.Bookmarks.CreateCollection(c.Context(), col); err != nil {
+		switch {
+		case errors.Is(err, store.ErrConflict):
+			return app.conflictResponse(c, errors.New("a collection with this name already exists"))
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	return app.jsonResponse(c, fiber.StatusCreated, CollectionResponse{ID: col.ID, Name: col.Name})
+}
+
+// renameCollectionHandler godoc
+//
+//	@Summary		Renames a bookmark collection
+//	@Tags			bookmarks
+//	@Accept			json
+//	@Param			collectionID	path	int					true	"Collection ID"
+//	@Param			payload			body	CollectionPayload	true	"Collection"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		409	{object}	error	"Name already used"
+//	@Security		ApiKeyAuth
+//	@Router			/bookmarks/collections/{collectionID} [patch]
+func (app *application) renameCollectionHandler(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("collectionID"), 10, 64)
+	if err != nil {
+		return app.badRequestResponse(c, errors.New("invalid collection ID"))
+	}
+
+	var payload CollectionPayload
+	if err := readJSON(c, &payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+	if err := Validate.Struct(payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	user := getUserFromContext(c)
+	col := &store.BookmarkCollection{ID: uint(id), UserID: user.ID, Name: payload.Name}
+	if err := app.store.Bookmarks.RenameCollection(c.Context(), col); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			return app.notFoundResponse(c, err)
+		case errors.Is(err, store.ErrConflict):
+			return app.conflictResponse(c, errors.New("a collection with this name already exists"))
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// deleteCollectionHandler godoc
+//
+//	@Summary		Deletes a bookmark collection
+//	@Description	Deletes a collection; its bookmarks are kept outside any collection
+//	@Tags			bookmarks
+//	@Param			collectionID	path	int	true	"Collection ID"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/bookmarks/collections/{collectionID} [delete]
+func (app *application) deleteCollectionHandler(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("collectionID"), 10, 64)
+	if err != nil {
+		return app.badRequestResponse(c, errors.New("invalid collection ID"))
+	}
+
+	user := getUserFromContext(c)
+	if err := app.store.Bookmarks.DeleteCollection(c.Context(), user.ID, uint(id)); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			return app.notFoundResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// markBookmarked sets PostMini.Bookmarked for the viewer; anonymous viewers
+// have no bookmarks.
+func (app *application) markBookmarked(ctx context.Context, viewer *store.User, posts []PostMini) error {
+	if viewer.ID == 0 || len(posts) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, 0, len(posts))
+	for _, p := range posts {
+		ids = append(ids, p.ID)
+	}
+
+	saved, err := app.store.Bookmarks.GetBookmarked(ctx, viewer.ID, ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range posts {
+		posts[i].Bookmarked = saved[posts[i].ID]
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/pangdfg/gopher-social/internal/store"
+)
+
+// deleteCommentHandler godoc
+//
+//	@Summary		Deletes a comment
+//	@Description	Moves a comment to the trash. Comment authors can delete their own comments, moderators any.
+//	@Tags			comments
+//	@Param			postID		path		int		true	"Post ID"
+//	@Param			commentID	path		int		true	"Comment ID"
+//	@Success		204			{string}	string	"Comment deleted"
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/comments/{commentID} [delete]
+func (app *application) deleteCommentHandler(c *fiber.Ctx) error {
+	post := c.Locals("post").(*store.Post)
+	user := getUserFromContext(c)
+	ctx := c.Context()
+
+	commentID, err := strconv.ParseUint(c.Params("commentID"), 10, 64)
+	if err != nil {
+		return app.badRequestResponse(c, errors.New("invalid comment ID"))
+	}
+
+	comment, err := app.store.Comments.GetByID(ctx, uint(commentID))
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			return app.notFoundResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+	if comment.PostID != post.ID {
+		return app.notFoundResponse(c, store.ErrNotFound)
+	}
+
+	if comment.UserID != user.ID {
+		allowed, err := app.checkRolePrecedence(c, user, "moderator")
+		if err != nil {
+			return app.internalServerError(c, err)
+		}
+		if !allowed {
+			return app.forbiddenResponse(c)
+		}
+	}
+
+	if err := app.store.Comments.Delete(ctx, comment.ID, user.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			return app.notFoundResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
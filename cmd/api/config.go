@@ -7,6 +7,7 @@ import (
 
 	"github.com/pangdfg/gopher-social/internal/auth"
 	"github.com/pangdfg/gopher-social/internal/mailer"
+	"github.com/pangdfg/gopher-social/internal/media"
 	"github.com/pangdfg/gopher-social/internal/ratelimiter"
 	"github.com/pangdfg/gopher-social/internal/store"
 	"github.com/pangdfg/gopher-social/internal/store/cache"
@@ -16,10 +17,15 @@ type application struct {
 	config        config
 	store         store.Storage
 	cacheStorage  cache.Storage
+	memoryCache   *cache.LRU
 	logger        *zap.SugaredLogger
 	mailer        mailer.Client
 	authenticator auth.Authenticator
 	rateLimiter   ratelimiter.Limiter
+	oidcProviders map[string]*auth.OIDCProvider
+	blobs         media.BlobStore
+	mediaQueue    chan uint
+	timelineQueue chan uint
 }
 
 type config struct {
@@ -32,6 +38,44 @@ type config struct {
 	auth        authConfig
 	redisCfg    redisConfig
 	rateLimiter ratelimiter.Config
+	accounts    accountsConfig
+	media       mediaConfig
+	trending    trendingConfig
+	trash       trashConfig
+	timeline    timelineConfig
+}
+
+type trendingConfig struct {
+	windows    []trendingWindow
+	refresh    time.Duration
+	minAuthors int
+	size       int
+}
+
+type mediaConfig struct {
+	backend       string
+	localDir      string
+	maxUploadSize int64
+	workers       int
+	s3            media.S3Config
+}
+
+// timelineConfig shapes the Redis home timelines. Posts by users with at
+// least fanoutMaxFollowers followers aren't pushed to each follower but
+// merged in when a timeline is read.
+type timelineConfig struct {
+	size               int
+	ttl                time.Duration
+	fanoutMaxFollowers int64
+	workers            int
+}
+
+type trashConfig struct {
+	retention time.Duration
+}
+
+type accountsConfig struct {
+	deletionGrace time.Duration
 }
 
 type redisConfig struct {
@@ -39,17 +83,35 @@ type redisConfig struct {
 	pw      string
 	db      int
 	enabled bool
+
+	// memorySize bounds the entries cached in process when redis is disabled
+	memorySize int
 }
 
 type authConfig struct {
-	basic basicConfig
-	token tokenConfig
+	basic     basicConfig
+	token     tokenConfig
+	twoFactor twoFactorConfig
+	oidc      auth.OIDCConfig
+}
+
+type twoFactorConfig struct {
+	issuer       string
+	challengeExp time.Duration
+
+	// maxAttempts wrong codes within lockout lock the second factor until
+	// lockout has passed; lockout outlives challengeExp, so it also spends
+	// the challenge the guesses came in on
+	maxAttempts int
+	lockout     time.Duration
 }
 
 type tokenConfig struct {
-	secret string
-	exp    time.Duration
-	iss    string
+	secret    string
+	exp       time.Duration
+	iss       string
+	keysDir   string
+	activeKID string
 }
 
 type basicConfig struct {
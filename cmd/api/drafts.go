@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/pangdfg/gopher-social/internal/store"
+)
+
+const scheduledPublishBatch = 100
+
+// resolvePostStatus checks a requested status and publish time. An empty
+// status means published, or scheduled when publishAt is given. Only
+// scheduled posts keep a publish time, and it must be in the future.
+func resolvePostStatus(status string, publishAt *time.Time, now time.Time) (string, *time.Time, error) {
+	if status == "" {
+		status = store.PostStatusPublished
+		if publishAt != nil {
+			status = store.PostStatusScheduled
+		}
+	}
+
+	switch status {
+	case store.PostStatusScheduled:
+		if publishAt == nil {
+			return "", nil, errors.New("scheduled posts need a publish_at")
+		}
+		if !publishAt.After(now) {
+			return "", nil, errors.New("publish_at must be in the future")
+		}
+		at := publishAt.UTC()
+		return status, &at, nil
+	case store.PostStatusDraft, store.PostStatusPublished:
+		if publishAt != nil {
+			return "", nil, errors.New("publish_at is only allowed for scheduled posts")
+		}
+		return status, nil, nil
+	default:
+		return "", nil, errors.New("invalid status")
+	}
+}
+
+// visibleTo reports whether user may see post: unpublished posts are
+// their author's alone.
+func visibleTo(post *store.Post, user *store.User) bool {
+	return post.Status == store.PostStatusPublished || post.UserID == user.ID
+}
+
+// getDraftsHandler godoc
+//
+//	@Summary		Lists my drafts
+//	@Description	Lists the authenticated user's drafts and scheduled posts, most recently edited first
+//	@Tags			posts
+//	@Produce		json
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Param			sort	query		string	false	"Sort by last edit (asc|desc)"
+//	@Param			search	query		string	false	"Search"
+//	@Success		200		{object}	FeedResponse
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/drafts [get]
+func (app *application) getDraftsHandler(c *fiber.Ctx) error {
+	fq := store.PaginatedFeedQuery{
+		Limit:  20,
+		Offset: 0,
+		Sort:   "desc",
+		Tags:   []string{},
+	}
+
+	fq, err := fq.Parse(c)
+	if err != nil {
+		return app.badRequestResponse(c, err)
+	}
+	if err := Validate.Struct(fq); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	user := getUserFromContext(c)
+	posts, err := app.store.Posts.GetDrafts(c.Context(), fq, user.ID)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	response := NewPostListResponse(posts, fq.Limit, fq.Offset)
+	if err := app.markBookmarked(c.Context(), user, response.Posts); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return app.jsonResponse(c, fiber.StatusOK, response)
+}
+
+// publishScheduledPosts publishes the posts whose publish time has passed,
+// then notifies the users they mention and puts them on timelines, all held
+// back until then. Those steps run after the publish committed, so a post
+// stays pending until they went through and is retried on the next run if
+// they failed or the process stopped in between.
+func (app *application) publishScheduledPosts(ctx context.Context) error {
+	ids, err := app.store.Posts.PublishDue(ctx, time.Now(), scheduledPublishBatch)
+	if err != nil {
+		return err
+	}
+	if len(ids) > 0 {
+		app.logger.Infow("published scheduled posts", "count", len(ids))
+	}
+
+	pending, err := app.store.Posts.GetUnannounced(ctx, scheduledPublishBatch)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range pending {
+		if err := app.announcePost(ctx, id); err != nil {
+			app.logger.Errorw("announcing scheduled post failed", "postID", id, "error", err.Error())
+		}
+	}
+	return nil
+}
+
+// announcePost notifies the users a published post mentions and fans it
+// out. Both are safe to repeat: mentions only notify users who weren't
+// mentioned already and timelines are sets.
+func (app *application) announcePost(ctx context.Context, id uint) error {
+	post, err := app.store.Posts.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if _, err := app.syncMentions(ctx, &post.User, mentionSource{postID: post.ID, text: post.Content}); err != nil {
+		return err
+	}
+
+	if app.cacheStorage.Timelines != nil {
+		if err := app.fanoutPost(ctx, post.ID); err != nil {
+			return err
+		}
+	}
+
+	return app.store.Posts.MarkAnnounced(ctx, post.ID)
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap"
+
+	"github.com/pangdfg/gopher-social/internal/store"
+)
+
+// editedPost keeps a single post in memory and applies updates to it the
+// way PostStore.Update does.
+type editedPost struct {
+	store.Posts
+	post store.Post
+}
+
+func (f *editedPost) Update(_ context.Context, post *store.Post) error {
+	if post.Version != f.post.Version {
+		return store.ErrConflict
+	}
+	post.Version++
+	f.post = *post
+	return nil
+}
+
+func (f *editedPost) SetTags(_ context.Context, _ uint, tags []store.Tag) error {
+	f.post.Tags = tags
+	return nil
+}
+
+func (f *editedPost) GetByID(_ context.Context, _ uint) (*store.Post, error) {
+	post := f.post
+	return &post, nil
+}
+
+// scheduledPosts publishes its due posts once and keeps them pending
+// announcement the way PostStore does.
+type scheduledPosts struct {
+	store.Posts
+	due     []uint
+	pending map[uint]bool
+}
+
+func (f *scheduledPosts) PublishDue(context.Context, time.Time, int) ([]uint, error) {
+	ids := f.due
+	f.due = nil
+	for _, id := range ids {
+		f.pending[id] = true
+	}
+	return ids, nil
+}
+
+func (f *scheduledPosts) GetUnannounced(context.Context, int) ([]uint, error) {
+	var ids []uint
+	for id := range f.pending {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func (f *scheduledPosts) MarkAnnounced(_ context.Context, id uint) error {
+	delete(f.pending, id)
+	return nil
+}
+
+func (f *scheduledPosts) GetByID(_ context.Context, id uint) (*store.Post, error) {
+	return &store.Post{ID: id, UserID: 7, Content: "later", Status: store.PostStatusPublished}, nil
+}
+
+// flakyMentions fails to store mentions while err is set.
+type flakyMentions struct {
+	postMentions
+	err error
+}
+
+func (f *flakyMentions) ReplaceForPost(ctx context.Context, postID uint, mentions []store.Mention) ([]uint, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.postMentions.ReplaceForPost(ctx, postID, mentions)
+}
+
+var _ = Describe("Post status", func() {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+	later := now.Add(time.Hour)
+	earlier := now.Add(-time.Minute)
+
+	DescribeTable("resolvePostStatus",
+		func(status string, publishAt *time.Time, want string, wantAt *time.Time) {
+			got, at, err := resolvePostStatus(status, publishAt, now)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(got).To(Equal(want))
+			if wantAt == nil {
+				Expect(at).To(BeNil())
+			} else {
+				Expect(*at).To(BeTemporally("==", *wantAt))
+				Expect(at.Location()).To(Equal(time.UTC))
+			}
+		},
+		Entry("published by default", "", nil, store.PostStatusPublished, nil),
+		Entry("scheduled when only publish_at is given", "", &later, store.PostStatusScheduled, &later),
+		Entry("a draft", store.PostStatusDraft, nil, store.PostStatusDraft, nil),
+		Entry("scheduled", store.PostStatusScheduled, &later, store.PostStatusScheduled, &later),
+	)
+
+	DescribeTable("resolvePostStatus rejects",
+		func(status string, publishAt *time.Time) {
+			_, _, err := resolvePostStatus(status, publishAt, now)
+			Expect(err).To(HaveOccurred())
+		},
+		Entry("a publish_at in the past", "", &earlier),
+		Entry("a publish_at of right now", store.PostStatusScheduled, &now),
+		Entry("scheduling without publish_at", store.PostStatusScheduled, nil),
+		Entry("a draft with publish_at", store.PostStatusDraft, &later),
+		Entry("publishing with publish_at", store.PostStatusPublished, &later),
+		Entry("an unknown status", "hidden", nil),
+	)
+
+	Describe("updating a draft", func() {
+		var posts *editedPost
+
+		BeforeEach(func() {
+			posts = &editedPost{post: store.Post{
+				ID:      1,
+				UserID:  7,
+				Title:   "draft",
+				Content: "not yet",
+				Kind:    store.PostKindPost,
+				Status:  store.PostStatusDraft,
+				Version: 1,
+			}}
+		})
+
+		patch := func(body string) int {
+			app := &application{
+				logger: zap.NewNop().Sugar(),
+				store: store.Storage{
+					Posts:    posts,
+					Mentions: &postMentions{byPost: map[uint][]uint{}},
+				},
+			}
+			f := fiber.New()
+			f.Use(func(c *fiber.Ctx) error {
+				post := posts.post
+				c.Locals("user", &store.User{ID: 7})
+				c.Locals("post", &post)
+				return c.Next()
+			})
+			f.Patch("/posts/1", app.updatePostHandler)
+
+			req := httptest.NewRequest(fiber.MethodPatch, "/posts/1", strings.NewReader(body))
+			req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+			req.Header.Set(fiber.HeaderIfMatch, versionETag(posts.post.Version))
+			resp, err := f.Test(req, -1)
+			Expect(err).NotTo(HaveOccurred())
+			return resp.StatusCode
+		}
+
+		It("schedules it, then publishes it", func() {
+			publishAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+			Expect(patch(`{"publish_at": "` + publishAt + `"}`)).To(Equal(fiber.StatusOK))
+			Expect(posts.post.Status).To(Equal(store.PostStatusScheduled))
+			Expect(posts.post.PublishAt).NotTo(BeNil())
+
+			Expect(patch(`{"status": "published"}`)).To(Equal(fiber.StatusOK))
+			Expect(posts.post.Status).To(Equal(store.PostStatusPublished))
+			Expect(posts.post.PublishAt).To(BeNil())
+			Expect(posts.post.CreatedAt).To(BeTemporally("~", time.Now(), time.Minute))
+		})
+
+		It("won't schedule it in the past", func() {
+			publishAt := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+			Expect(patch(`{"status": "scheduled", "publish_at": "` + publishAt + `"}`)).To(Equal(fiber.StatusBadRequest))
+			Expect(posts.post.Status).To(Equal(store.PostStatusDraft))
+		})
+
+		It("won't take a published post back", func() {
+			Expect(patch(`{"status": "published"}`)).To(Equal(fiber.StatusOK))
+
+			Expect(patch(`{"status": "draft"}`)).To(Equal(fiber.StatusBadRequest))
+			Expect(patch(`{"publish_at": "` + later.Format(time.RFC3339) + `"}`)).To(Equal(fiber.StatusBadRequest))
+			Expect(posts.post.Status).To(Equal(store.PostStatusPublished))
+		})
+	})
+
+	Describe("publishing scheduled posts", func() {
+		var (
+			app      *application
+			posts    *scheduledPosts
+			mentions *flakyMentions
+		)
+
+		BeforeEach(func() {
+			posts = &scheduledPosts{due: []uint{1, 2}, pending: map[uint]bool{}}
+			mentions = &flakyMentions{postMentions: postMentions{byPost: map[uint][]uint{}}}
+			app = &application{
+				logger: zap.NewNop().Sugar(),
+				store: store.Storage{
+					Posts:    posts,
+					Users:    &namedUsers{ids: map[string]uint{}},
+					Mentions: mentions,
+				},
+			}
+		})
+
+		It("announces the posts it publishes", func() {
+			Expect(app.publishScheduledPosts(context.Background())).To(Succeed())
+			Expect(posts.pending).To(BeEmpty())
+		})
+
+		It("keeps a post pending until announcing it went through", func() {
+			mentions.err = errors.New("database is down")
+			Expect(app.publishScheduledPosts(context.Background())).To(Succeed())
+			Expect(posts.pending).To(HaveLen(2))
+
+			mentions.err = nil
+			Expect(app.publishScheduledPosts(context.Background())).To(Succeed())
+			Expect(posts.pending).To(BeEmpty())
+		})
+	})
+})
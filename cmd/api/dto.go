@@ -13,19 +13,87 @@ type PostResponse struct {
 	Author        UserMini  `json:"author"`
 	Tags          []TagsMini`json:"tags"`
 	Comments      []CommentMini `json:"commnts"`
+	Attachments   []AttachmentMini `json:"attachments"`
+	Mentions      []MentionMini `json:"mentions"`
+	Kind          string    `json:"kind"`
+	RepostOf      *PostMini `json:"repost_of,omitempty"`
+	QuoteOf       *QuotedPost `json:"quote_of,omitempty"`
 	CommentsCount int       `json:"comments_count"`
+	RepostsCount  int64     `json:"reposts_count"`
+	QuotesCount   int64     `json:"quotes_count"`
+	// Status is draft, scheduled or published; PublishAt is set while
+	// scheduled
+	Status        string     `json:"status"`
+	PublishAt     *time.Time `json:"publish_at,omitempty"`
+	// Edited is set once the post was changed after publishing, last at
+	// EditedAt
+	Edited        bool       `json:"edited"`
+	EditedAt      *time.Time `json:"edited_at,omitempty"`
 	CreatedAt     time.Time `json:"created_at"`
 }
 
-type UserMini struct {
-	ID       uint   `json:"id"`
+// QuotedPost is the post a quote refers to. Once the original is deleted
+// only Unavailable is set.
+type QuotedPost struct {
+	ID          uint       `json:"id,omitempty"`
+	Title       string     `json:"title,omitempty"`
+	Content     string     `json:"content,omitempty"`
+	Author      *UserMini  `json:"author,omitempty"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
+	Unavailable bool       `json:"unavailable"`
+}
+
+// MentionMini locates a mention in the text; Start and End are rune
+// offsets, End exclusive.
+type MentionMini struct {
+	UserID   uint   `json:"user_id"`
 	Username string `json:"username"`
-	Role     string `json:"role"`
+	Start    int    `json:"start"`
+	End      int    `json:"end"`
+}
+
+type AttachmentMini struct {
+	ID          uint          `json:"id"`
+	URL         string        `json:"url"`
+	ContentType string        `json:"content_type"`
+	AltText     string        `json:"alt_text"`
+	Status      string        `json:"status"`
+	Width       int           `json:"width"`
+	Height      int           `json:"height"`
+	Blurhash    string        `json:"blurhash"`
+	Variants    []VariantMini `json:"variants"`
+}
+
+type VariantMini struct {
+	Name        string `json:"name"`
+	URL         string `json:"url"`
+	ContentType string `json:"content_type"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+}
+
+type UserMini struct {
+	ID          uint   `json:"id"`
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+	AvatarURL   string `json:"avatar_url"`
+	Role        string `json:"role"`
+}
+
+type UserProfile struct {
+	DisplayName string   `json:"display_name"`
+	Bio         string   `json:"bio"`
+	Location    string   `json:"location"`
+	Links       []string `json:"links"`
+	AvatarURL   string   `json:"avatar_url"`
+	BannerURL   string   `json:"banner_url"`
+	Language    string   `json:"language"`
 }
 
 type TagsMini struct {
 	ID    uint   `json:"id"`
 	Title string `json:"title"`
+	Name  string `json:"name"`
 }
 
 type FeedResponse struct {
@@ -41,18 +109,53 @@ type PostMini struct {
 	Content       string    `json:"content"`
 	Author        UserMini  `json:"author"`
 	Tags          []TagsMini`json:"tags"`
-	CommentsCount int       `json:"comments_count"`
+	Attachments   []AttachmentMini `json:"attachments"`
+	Mentions      []MentionMini `json:"mentions"`
+	// Kind is post, repost or quote. A repost's Author and CreatedAt are
+	// the reposter's and the reshared post is in RepostOf.
+	Kind          string    `json:"kind"`
+	RepostOf      *PostMini `json:"repost_of,omitempty"`
+	QuoteOf       *QuotedPost `json:"quote_of,omitempty"`
+	CommentsCount int64     `json:"comments_count"`
+	RepostsCount  int64     `json:"reposts_count"`
+	QuotesCount   int64     `json:"quotes_count"`
+	Status        string     `json:"status"`
+	PublishAt     *time.Time `json:"publish_at,omitempty"`
+	Edited        bool       `json:"edited"`
+	EditedAt      *time.Time `json:"edited_at,omitempty"`
+	// Bookmarked is whether the viewer saved the post
+	Bookmarked    bool      `json:"bookmarked"`
 	CreatedAt     time.Time `json:"created_at"`
 }
 
+// TagSummary is a tag in a tag listing.
+type TagSummary struct {
+	TagsMini
+	Description string `json:"description"`
+	PostsCount  int64  `json:"posts_count"`
+}
+
+// TagDetail is a tag on its own page.
+type TagDetail struct {
+	TagSummary
+	Aliases []TagAliasMini `json:"aliases"`
+}
+
+type TagAliasMini struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
 type TagsListResponse struct {
-	Tags   []TagsMini `json:"tags"`
+	Tags   []TagSummary `json:"tags"`
 	Limit  int        `json:"limit"`
 	Offset int        `json:"offset"`
 }
 
 type TagsResponse struct {
-	Tag   TagsMini     `json:"tag"`
+	Tag   TagDetail    `json:"tag"`
+	FollowersCount int64 `json:"followers_count"`
+	IsFollowing    bool  `json:"is_following"`
 	Posts FeedResponse `json:"posts"`
 	Limit  int          `json:"limit"`
 	Offset int          `json:"offset"`
@@ -62,6 +165,7 @@ type CommentMini struct {
 	ID uint                 `json:"id"`
 	Content       string    `json:"content"`
 	Author        UserMini  `json:"author"`
+	Mentions      []MentionMini `json:"mentions"`
 	CreatedAt     time.Time `json:"created_at"`
 }
 type UserResponse struct {
@@ -69,34 +173,137 @@ type UserResponse struct {
 	Email string       `json:"email"`
 	Username string    `json:"username"`
 	Role string        `json:"role"`
+	UserProfile
 	Posts FeedResponse `json:"posts"`
 }
 
-func NewPostListResponse(posts []store.Post, limit, offset int) FeedResponse {
-	res := make([]PostMini, 0, len(posts))
-	for _, p := range posts {
-		tags := make([]TagsMini, 0, len(p.Tags))
-		for _, t := range p.Tags {
-			tags = append(tags, TagsMini{
-				ID:    t.ID,
-				Title: t.Title,
+func newUserMini(u *store.User) UserMini {
+	return UserMini{
+		ID:          u.ID,
+		Username:    u.Username,
+		DisplayName: u.DisplayName,
+		AvatarURL:   u.AvatarURL,
+		Role:        u.Role.Name,
+	}
+}
+
+func newUserProfile(u *store.User) UserProfile {
+	links := []string(u.Links)
+	if links == nil {
+		links = []string{}
+	}
+
+	return UserProfile{
+		DisplayName: u.DisplayName,
+		Bio:         u.Bio,
+		Location:    u.Location,
+		Links:       links,
+		AvatarURL:   u.AvatarURL,
+		BannerURL:   u.BannerURL,
+		Language:    u.Language,
+	}
+}
+
+func newMentionsMini(mentions []store.Mention) []MentionMini {
+	res := make([]MentionMini, 0, len(mentions))
+	for _, m := range mentions {
+		res = append(res, MentionMini{
+			UserID:   m.UserID,
+			Username: m.User.Username,
+			Start:    m.Start,
+			End:      m.End,
+		})
+	}
+	return res
+}
+
+func newAttachmentsMini(media []store.MediaAttachment) []AttachmentMini {
+	res := make([]AttachmentMini, 0, len(media))
+	for _, m := range media {
+		variants := make([]VariantMini, 0, len(m.Variants))
+		for _, v := range m.Variants {
+			variants = append(variants, VariantMini{
+				Name:        v.Name,
+				URL:         v.URL,
+				ContentType: v.ContentType,
+				Width:       v.Width,
+				Height:      v.Height,
 			})
 		}
-		res = append(res, PostMini{
-			ID: p.ID,
-			Title: p.Title,
-			Content: p.Content,
-			Author: UserMini{
-				ID:       p.User.ID,
-				Username: p.User.Username,
-				Role:     p.User.Role.Name,
-			},
-			Tags: tags,
-			CommentsCount: len(p.Comments),
-			CreatedAt: p.CreatedAt,
 
+		res = append(res, AttachmentMini{
+			ID:          m.ID,
+			URL:         m.URL,
+			ContentType: m.ContentType,
+			AltText:     m.AltText,
+			Status:      m.Status,
+			Width:       m.Width,
+			Height:      m.Height,
+			Blurhash:    m.Blurhash,
+			Variants:    variants,
 		})
 	}
+	return res
+}
+
+func newPostMini(p *store.Post) PostMini {
+	tags := make([]TagsMini, 0, len(p.Tags))
+	for _, t := range p.Tags {
+		tags = append(tags, TagsMini{
+			ID:    t.ID,
+			Title: t.Title,
+			Name:  t.Name,
+		})
+	}
+	mini := PostMini{
+		ID: p.ID,
+		Title: p.Title,
+		Content: p.Content,
+		Author: newUserMini(&p.User),
+		Tags: tags,
+		Attachments: newAttachmentsMini(p.Attachments),
+		Mentions: newMentionsMini(p.Mentions),
+		Kind: p.Kind,
+		QuoteOf: newQuotedPost(p),
+		CommentsCount: p.CommentsCount,
+		RepostsCount: p.RepostsCount,
+		QuotesCount: p.QuotesCount,
+		Status: p.Status,
+		PublishAt: p.PublishAt,
+		Edited: p.EditedAt != nil,
+		EditedAt: p.EditedAt,
+		CreatedAt: p.CreatedAt,
+	}
+	if p.RepostOf != nil {
+		original := newPostMini(p.RepostOf)
+		mini.RepostOf = &original
+	}
+	return mini
+}
+
+func newQuotedPost(p *store.Post) *QuotedPost {
+	if p.Kind != store.PostKindQuote {
+		return nil
+	}
+	if p.QuoteOf == nil {
+		return &QuotedPost{Unavailable: true}
+	}
+
+	author := newUserMini(&p.QuoteOf.User)
+	return &QuotedPost{
+		ID:        p.QuoteOf.ID,
+		Title:     p.QuoteOf.Title,
+		Content:   p.QuoteOf.Content,
+		Author:    &author,
+		CreatedAt: &p.QuoteOf.CreatedAt,
+	}
+}
+
+func NewPostListResponse(posts []store.Post, limit, offset int) FeedResponse {
+	res := make([]PostMini, 0, len(posts))
+	for i := range posts {
+		res = append(res, newPostMini(&posts[i]))
+	}
 
 	return FeedResponse{
 		Posts:  res,
@@ -112,6 +319,7 @@ func NewPostResponse(post *store.Post) PostResponse {
 		tags = append(tags, TagsMini{
 			ID:    t.ID,
 			Title: t.Title,
+			Name:  t.Name,
 		})
 	}
 
@@ -120,55 +328,45 @@ func NewPostResponse(post *store.Post) PostResponse {
 		comments = append(comments, CommentMini{
 			ID:    c.ID,
 			Content: c.Content,
-			Author: UserMini{
-				ID:       c.User.ID,
-				Username: c.User.Username,
-				Role:     c.User.Role.Name,
-			},
+			Author: newUserMini(&c.User),
+			Mentions: newMentionsMini(c.Mentions),
 			CreatedAt: c.CreatedAt,
 		})
 	}
 
+	var repostOf *PostMini
+	if post.RepostOf != nil {
+		original := newPostMini(post.RepostOf)
+		repostOf = &original
+	}
+
 	return PostResponse{
 		ID:            post.ID,
 		Title:         post.Title,
 		Content:       post.Content,
-		Author: UserMini{
-			ID:       post.User.ID,
-			Username: post.User.Username,
-			Role:     post.User.Role.Name,
-		},
+		Author: newUserMini(&post.User),
 		Tags:          tags,
 		Comments:      comments,
+		Attachments:   newAttachmentsMini(post.Attachments),
+		Mentions:      newMentionsMini(post.Mentions),
+		Kind:          post.Kind,
+		RepostOf:      repostOf,
+		QuoteOf:       newQuotedPost(post),
 		CommentsCount: len(comments),
+		RepostsCount:  post.RepostsCount,
+		QuotesCount:   post.QuotesCount,
+		Status:        post.Status,
+		PublishAt:     post.PublishAt,
+		Edited:        post.EditedAt != nil,
+		EditedAt:      post.EditedAt,
 		CreatedAt:     post.CreatedAt,
 	}
 }
 
 func NewUserResponse(user *userWithPosts, limit int, offset int) UserResponse {
 	post := make([]PostMini, 0, len(user.Posts))
-	for _, p := range user.Posts{
-		tags := make([]TagsMini, 0, len(p.Tags))
-		for _, t := range p.Tags {
-			tags = append(tags, TagsMini{
-				ID:    t.ID,
-				Title: t.Title,
-			})
-		}
-		post = append(post, PostMini{
-			ID: p.ID,
-			Title: p.Title,
-			Content: p.Content,
-			Author: UserMini{
-				ID:       p.User.ID,
-				Username: p.User.Username,
-				Role:     p.User.Role.Name,
-			},
-			Tags: tags,
-			CommentsCount: len(p.Comments),
-			CreatedAt: p.CreatedAt,
-
-		})
+	for i := range user.Posts {
+		post = append(post, newPostMini(&user.Posts[i]))
 	}
 
 
@@ -177,6 +375,7 @@ func NewUserResponse(user *userWithPosts, limit int, offset int) UserResponse {
 		Username: user.Username,
 		Email: user.Email,
 		Role: user.Role.Name,
+		UserProfile: newUserProfile(user.User),
 		Posts:  FeedResponse{
 			Posts:  post,
 			PostsCount: len(post),
@@ -186,13 +385,26 @@ func NewUserResponse(user *userWithPosts, limit int, offset int) UserResponse {
 	}
 }
 
+func newTagSummary(t *store.Tag) TagSummary {
+	return TagSummary{
+		TagsMini:    TagsMini{ID: t.ID, Title: t.Title, Name: t.Name},
+		Description: t.Description,
+		PostsCount:  t.PostsCount,
+	}
+}
+
+func newTagDetail(t *store.Tag) TagDetail {
+	aliases := make([]TagAliasMini, 0, len(t.Aliases))
+	for _, a := range t.Aliases {
+		aliases = append(aliases, TagAliasMini{ID: a.ID, Name: a.Name})
+	}
+	return TagDetail{TagSummary: newTagSummary(t), Aliases: aliases}
+}
+
 func NewTagsListResponse(tags []store.Tag, limit int, offset int) TagsListResponse {
-	tag := make([]TagsMini, 0, len(tags))
-		for _, t := range tags {
-			tag = append(tag, TagsMini{
-				ID:    t.ID,
-				Title: t.Title,
-			})
+	tag := make([]TagSummary, 0, len(tags))
+		for i := range tags {
+			tag = append(tag, newTagSummary(&tags[i]))
 		}
 
 	return  TagsListResponse{
@@ -205,10 +417,7 @@ func NewTagsListResponse(tags []store.Tag, limit int, offset int) TagsListRespon
 func NewTagPostListResponse(posts []store.Post, tag *store.Tag, limit int, offset int) TagsResponse {
 	res := NewPostListResponse(posts, limit, offset)
 	return TagsResponse{
-		Tag: TagsMini{
-			ID: tag.ID,
-			Title: tag.Title,
-		},
+		Tag: newTagDetail(tag),
 		Posts:  res,
 		Limit:  limit,
 		Offset: offset,
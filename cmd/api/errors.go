@@ -102,3 +102,25 @@ func (app *application) rateLimitExceededResponse(c *fiber.Ctx, retryAfter strin
 		"error": "rate limit exceeded, retry after: " + retryAfter,
 	})
 }
+
+func (app *application) preconditionRequiredResponse(c *fiber.Ctx) error {
+	app.logger.Warnw("precondition required",
+		"method", c.Method(),
+		"path", c.Path(),
+	)
+
+	return c.Status(fiber.StatusPreconditionRequired).JSON(fiber.Map{
+		"error": "this request needs an If-Match header with the resource's ETag",
+	})
+}
+
+func (app *application) preconditionFailedResponse(c *fiber.Ctx) error {
+	app.logger.Warnw("precondition failed",
+		"method", c.Method(),
+		"path", c.Path(),
+	)
+
+	return c.Status(fiber.StatusPreconditionFailed).JSON(fiber.Map{
+		"error": "the resource was changed since it was fetched",
+	})
+}
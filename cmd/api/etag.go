@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var (
+	errPreconditionRequired = errors.New("if-match required")
+	errPreconditionFailed   = errors.New("if-match mismatch")
+)
+
+// versionETag is the ETag of a resource at a version. Writes to posts and
+// profiles bump their version, so it changes whenever they do.
+func versionETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// checkIfMatch requires the request's If-Match to name the version the
+// client is changing. Tags are compared strongly as RFC 9110 requires, so
+// a weak tag never matches, and "*" matches anything.
+func checkIfMatch(c *fiber.Ctx, version int) error {
+	header := strings.TrimSpace(c.Get(fiber.HeaderIfMatch))
+	if header == "" {
+		return errPreconditionRequired
+	}
+	if header == "*" {
+		return nil
+	}
+
+	current := versionETag(version)
+	for _, tag := range strings.Split(header, ",") {
+		if strings.TrimSpace(tag) == current {
+			return nil
+		}
+	}
+	return errPreconditionFailed
+}
+
+// ifMatchResponse writes the response for a failed checkIfMatch.
+func (app *application) ifMatchResponse(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, errPreconditionRequired):
+		return app.preconditionRequiredResponse(c)
+	default:
+		return app.preconditionFailedResponse(c)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http/httptest"
+
+	"github.com/gofiber/fiber/v2"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("If-Match", func() {
+	var app *fiber.App
+
+	BeforeEach(func() {
+		app = fiber.New()
+		app.Patch("/", func(c *fiber.Ctx) error {
+			switch err := checkIfMatch(c, 3); err {
+			case nil:
+				return c.SendStatus(fiber.StatusNoContent)
+			case errPreconditionRequired:
+				return c.SendStatus(fiber.StatusPreconditionRequired)
+			default:
+				return c.SendStatus(fiber.StatusPreconditionFailed)
+			}
+		})
+	})
+
+	status := func(ifMatch string) int {
+		req := httptest.NewRequest("PATCH", "/", nil)
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		resp, err := app.Test(req, -1)
+		Expect(err).To(BeNil())
+		return resp.StatusCode
+	}
+
+	It("requires the header", func() {
+		Expect(status("")).To(Equal(fiber.StatusPreconditionRequired))
+	})
+
+	It("accepts the current version", func() {
+		Expect(status(versionETag(3))).To(Equal(fiber.StatusNoContent))
+		Expect(status(`"1", "3"`)).To(Equal(fiber.StatusNoContent))
+		Expect(status("*")).To(Equal(fiber.StatusNoContent))
+	})
+
+	It("rejects other versions", func() {
+		Expect(status(`"2"`)).To(Equal(fiber.StatusPreconditionFailed))
+		Expect(status("3")).To(Equal(fiber.StatusPreconditionFailed))
+	})
+
+	It("doesn't match weak tags", func() {
+		Expect(status(`W/"3"`)).To(Equal(fiber.StatusPreconditionFailed))
+		Expect(status(`W/"1", W/"3"`)).To(Equal(fiber.StatusPreconditionFailed))
+		Expect(status(`W/"3", "3"`)).To(Equal(fiber.StatusNoContent))
+	})
+})
@@ -65,6 +65,9 @@ func (app *application) getFeedHandler(c *fiber.Ctx) error {
 		fq.Limit,
 		fq.Offset,
 	)
+	if err := app.markBookmarked(c.Context(), getUserFromContext(c), response.Posts); err != nil {
+		return app.internalServerError(c, err)
+	}
 
 	return app.jsonResponse(c, fiber.StatusOK, response)
 }
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pangdfg/gopher-social/internal/richtext"
+	"github.com/pangdfg/gopher-social/internal/store"
+)
+
+// maxPostTags caps the tags of a post, explicit and inline together.
+const maxPostTags = 10
+
+// errInvalidTag is returned for explicit tags that don't normalize.
+type errInvalidTag string
+
+func (e errInvalidTag) Error() string {
+	return fmt.Sprintf("invalid tag %q: tags are up to %d letters, digits or underscores", string(e), richtext.MaxTagRunes)
+}
+
+// resolveTags merges explicit tags with #hashtags in content, creating tags
+// that don't exist yet. Invalid explicit tags are an error; invalid inline
+// ones are left as plain text. Names that resolve to the same tag, e.g. an
+// alias and its target, count once. Tags beyond maxPostTags are dropped.
+func (app *application) resolveTags(ctx context.Context, explicit []string, content string) ([]store.Tag, error) {
+	var titles []string
+	titles = append(titles, explicit...)
+	for _, e := range richtext.Hashtags(content) {
+		titles = append(titles, e.Value)
+	}
+
+	seen := make(map[string]bool, len(titles))
+	seenIDs := make(map[uint]bool, len(titles))
+	tags := make([]store.Tag, 0, len(titles))
+	for i, title := range titles {
+		name, ok := richtext.NormalizeTag(title)
+		if !ok {
+			if i < len(explicit) {
+				return nil, errInvalidTag(title)
+			}
+			continue
+		}
+		if seen[name] || len(tags) == maxPostTags {
+			continue
+		}
+		seen[name] = true
+
+		tag := store.Tag{Title: richtext.DisplayTag(title), Name: name}
+		if err := app.store.Tags.Create(ctx, &tag); err != nil {
+			return nil, err
+		}
+		if seenIDs[tag.ID] {
+			continue
+		}
+		seenIDs[tag.ID] = true
+		tags = append(tags, tag)
+	}
+
+	return tags, nil
+}
+
+// explicitTags are the tags of a post that its content doesn't account for,
+// so an edit keeps them when the payload doesn't set tags.
+func explicitTags(post *store.Post) []string {
+	inline := make(map[string]bool)
+	for _, e := range richtext.Hashtags(post.Content) {
+		if name, ok := richtext.NormalizeTag(e.Value); ok {
+			inline[name] = true
+		}
+	}
+
+	var out []string
+	for _, t := range post.Tags {
+		if !inline[t.Name] {
+			out = append(out, t.Name)
+		}
+	}
+	return out
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap"
+
+	"github.com/pangdfg/gopher-social/internal/store"
+)
+
+// aliasedTags creates tags by name and resolves aliases to their tag the
+// way TagStore.Create does.
+type aliasedTags struct {
+	store.Tags
+	ids     map[string]uint
+	aliases map[string]string
+}
+
+func (f *aliasedTags) Create(_ context.Context, tag *store.Tag) error {
+	if target, ok := f.aliases[tag.Name]; ok {
+		tag.Name, tag.Title = target, target
+	}
+	if _, ok := f.ids[tag.Name]; !ok {
+		f.ids[tag.Name] = uint(len(f.ids) + 1)
+	}
+	tag.ID = f.ids[tag.Name]
+	return nil
+}
+
+var _ = Describe("resolveTags", func() {
+	var app *application
+
+	BeforeEach(func() {
+		app = &application{
+			logger: zap.NewNop().Sugar(),
+			store: store.Storage{
+				Tags: &aliasedTags{ids: map[string]uint{}, aliases: map[string]string{"golang": "go"}},
+			},
+		}
+	})
+
+	names := func(tags []store.Tag) []string {
+		out := make([]string, 0, len(tags))
+		for _, t := range tags {
+			out = append(out, t.Name)
+		}
+		return out
+	}
+
+	It("merges explicit and inline tags by name", func() {
+		tags, err := app.resolveTags(context.Background(), []string{"Gophers"}, "hello #gophers #rust")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names(tags)).To(Equal([]string{"gophers", "rust"}))
+	})
+
+	It("attaches a tag once when an alias names it too", func() {
+		tags, err := app.resolveTags(context.Background(), nil, "#go #golang #rust")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names(tags)).To(Equal([]string{"go", "rust"}))
+	})
+
+	It("rejects an invalid explicit tag", func() {
+		_, err := app.resolveTags(context.Background(), []string{"two words"}, "")
+		Expect(err).To(MatchError(errInvalidTag("two words")))
+	})
+})
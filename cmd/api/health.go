@@ -7,7 +7,7 @@ import (
 // healthcheckHandler godoc
 //
 //	@Summary		Healthcheck
-//	@Description	Healthcheck endpoint
+//	@Description	Healthcheck endpoint, with the in-process cache's hit, miss and eviction counts when Redis is disabled
 //	@Tags			ops
 //	@Produce		json
 //	@Success		200	{object}	string	"ok"
@@ -18,6 +18,9 @@ func (app *application) healthCheckHandler(c *fiber.Ctx) error {
 		"env":     app.config.env,
 		"version": version,
 	}
+	if app.memoryCache != nil {
+		data["cache"] = app.memoryCache.Stats()
+	}
 
 	return c.Status(fiber.StatusOK).JSON(data)
 }
\ No newline at end of file
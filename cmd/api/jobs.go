@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+const accountPurgeBatch = 100
+
+// startBackgroundJobs launches the periodic maintenance jobs. They stop
+// when ctx is cancelled.
+func (app *application) startBackgroundJobs(ctx context.Context) {
+	go app.runPeriodic(ctx, "purge-deleted-accounts", time.Hour, app.purgeDeletedAccounts)
+	go app.runPeriodic(ctx, "purge-orphaned-media", time.Hour, app.purgeOrphanedMedia)
+	go app.runPeriodic(ctx, "purge-trash", time.Hour, app.purgeTrash)
+	go app.runPeriodic(ctx, "sweep-unprocessed-media", time.Minute, app.sweepUnprocessedMedia)
+	go app.runPeriodic(ctx, "refresh-trending", app.config.trending.refresh, app.refreshTrending)
+	go app.runPeriodic(ctx, "publish-scheduled-posts", 30*time.Second, app.publishScheduledPosts)
+
+	for i := 0; i < app.config.media.workers; i++ {
+		go app.mediaWorker(ctx)
+	}
+
+	if app.cacheStorage.Timelines != nil {
+		for i := 0; i < app.config.timeline.workers; i++ {
+			go app.timelineWorker(ctx)
+		}
+	}
+}
+
+// runPeriodic calls fn right away and then every interval until ctx is done.
+func (app *application) runPeriodic(ctx context.Context, name string, interval time.Duration, fn func(context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := fn(ctx); err != nil {
+			app.logger.Errorw("background job failed", "job", name, "error", err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purgeDeletedAccounts deletes accounts whose deletion grace period is over.
+func (app *application) purgeDeletedAccounts(ctx context.Context) error {
+	cutoff := time.Now().Add(-app.config.accounts.deletionGrace)
+
+	ids, err := app.store.Users.GetDueForDeletion(ctx, cutoff, accountPurgeBatch)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		uploads, err := app.store.Media.GetByUserID(ctx, id)
+		if err != nil {
+			app.logger.Errorw("account purge failed", "userID", id, "error", err.Error())
+			continue
+		}
+
+		if err := app.store.Users.Delete(ctx, id); err != nil {
+			app.logger.Errorw("account purge failed", "userID", id, "error", err.Error())
+			continue
+		}
+
+		// the rows went with the user; the files have to go too
+		for i := range uploads {
+			app.deleteMediaBlobs(ctx, &uploads[i])
+		}
+
+		app.logger.Infow("account deleted", "userID", id)
+	}
+
+	return nil
+}
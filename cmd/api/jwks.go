@@ -0,0 +1,25 @@
+package main
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/pangdfg/gopher-social/internal/auth"
+)
+
+// jwksHandler godoc
+//
+//	@Summary		Token verification keys
+//	@Description	Publishes the public keys used to sign access tokens as a JWK set. Empty when tokens use a shared secret.
+//	@Tags			ops
+//	@Produce		json
+//	@Success		200	{object}	auth.JWKSet
+//	@Router			/.well-known/jwks.json [get]
+func (app *application) jwksHandler(c *fiber.Ctx) error {
+	set := auth.JWKSet{Keys: []auth.JWK{}}
+	if p, ok := app.authenticator.(auth.KeyPublisher); ok {
+		set = p.JWKS()
+	}
+
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.Status(fiber.StatusOK).JSON(set)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http/httptest"
+
+	"github.com/gofiber/fiber/v2"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/pangdfg/gopher-social/internal/auth"
+)
+
+var _ = Describe("JWKS", func() {
+	get := func(authenticator auth.Authenticator) auth.JWKSet {
+		app := &application{authenticator: authenticator}
+		f := fiber.New()
+		f.Get("/.well-known/jwks.json", app.jwksHandler)
+
+		resp, err := f.Test(httptest.NewRequest(fiber.MethodGet, "/.well-known/jwks.json", nil), -1)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(fiber.StatusOK))
+
+		var set auth.JWKSet
+		Expect(json.NewDecoder(resp.Body).Decode(&set)).To(Succeed())
+		return set
+	}
+
+	It("publishes nothing for a shared secret", func() {
+		set := get(auth.NewJWTAuthenticator("secret", "gophersocial", "gophersocial"))
+		Expect(set.Keys).To(BeEmpty())
+	})
+
+	It("publishes the key set's public keys", func() {
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		der, err := x509.MarshalPKCS8PrivateKey(private)
+		Expect(err).NotTo(HaveOccurred())
+		key, err := auth.ParsePEMKey("2026-10", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+		Expect(err).NotTo(HaveOccurred())
+
+		keySet, err := auth.NewKeySetAuthenticator([]*auth.SigningKey{key}, "2026-10", "gophersocial", "gophersocial")
+		Expect(err).NotTo(HaveOccurred())
+
+		set := get(keySet)
+		Expect(set.Keys).To(HaveLen(1))
+		Expect(set.Keys[0].Kid).To(Equal("2026-10"))
+	})
+})
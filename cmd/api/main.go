@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"os"
 	"time"
@@ -14,6 +16,7 @@ import (
 	"github.com/pangdfg/gopher-social/internal/db"
 	"github.com/pangdfg/gopher-social/internal/env"
 	"github.com/pangdfg/gopher-social/internal/mailer"
+	"github.com/pangdfg/gopher-social/internal/media"
 	"github.com/pangdfg/gopher-social/internal/ratelimiter"
 	"github.com/pangdfg/gopher-social/internal/store"
 	"github.com/pangdfg/gopher-social/internal/store/cache"
@@ -33,10 +36,11 @@ func main() {
 			maxIdleTime:  env.GetString("DB_MAX_IDLE_TIME", "15m"),
 		},
 		redisCfg: redisConfig{
-			addr:    env.GetString("REDIS_ADDR", "localhost:6379"),
-			pw:      env.GetString("REDIS_PW", ""),
-			db:      env.GetInt("REDIS_DB", 0),
-			enabled: env.GetBool("REDIS_ENABLED", false),
+			addr:       env.GetString("REDIS_ADDR", "localhost:6379"),
+			pw:         env.GetString("REDIS_PW", ""),
+			db:         env.GetInt("REDIS_DB", 0),
+			enabled:    env.GetBool("REDIS_ENABLED", false),
+			memorySize: env.GetInt("CACHE_MEMORY_SIZE", 10000),
 		},
 		env: env.GetString("ENV", "development"),
 		mail: mailConfig{
@@ -59,6 +63,21 @@ func main() {
 				secret: env.GetString("AUTH_TOKEN_SECRET", "example"),
 				exp:    time.Hour * 24 * 3, // 3 days
 				iss:    "gophersocial",
+				keysDir:   env.GetString("AUTH_TOKEN_KEYS_DIR", ""),
+				activeKID: env.GetString("AUTH_TOKEN_ACTIVE_KID", ""),
+			},
+			twoFactor: twoFactorConfig{
+				issuer:       env.GetString("AUTH_TOTP_ISSUER", "GopherSocial"),
+				challengeExp: time.Minute * 5,
+				maxAttempts:  5,
+				lockout:      time.Minute * 15,
+			},
+			oidc: auth.OIDCConfig{
+				Name:         env.GetString("OIDC_PROVIDER", "oidc"),
+				Issuer:       env.GetString("OIDC_ISSUER", ""),
+				ClientID:     env.GetString("OIDC_CLIENT_ID", ""),
+				ClientSecret: env.GetString("OIDC_CLIENT_SECRET", ""),
+				RedirectURL:  env.GetString("OIDC_REDIRECT_URL", "http://localhost:8080/v1/auth/oidc/oidc/callback"),
 			},
 		},
 		rateLimiter: ratelimiter.Config{
@@ -66,11 +85,54 @@ func main() {
 			TimeFrame:            time.Second * 5,
 			Enabled:              env.GetBool("RATE_LIMITER_ENABLED", true),
 		},
+		accounts: accountsConfig{
+			deletionGrace: time.Hour * 24 * time.Duration(env.GetInt("ACCOUNT_DELETION_GRACE_DAYS", 14)),
+		},
+		timeline: timelineConfig{
+			size:               env.GetInt("TIMELINE_SIZE", 800),
+			ttl:                time.Hour * time.Duration(env.GetInt("TIMELINE_TTL_HOURS", 24)),
+			fanoutMaxFollowers: int64(env.GetInt("TIMELINE_FANOUT_MAX_FOLLOWERS", 10000)),
+			workers:            env.GetInt("TIMELINE_WORKERS", 2),
+		},
+		trash: trashConfig{
+			retention: time.Hour * 24 * time.Duration(env.GetInt("TRASH_RETENTION_DAYS", 30)),
+		},
+		media: mediaConfig{
+			backend:       env.GetString("MEDIA_BACKEND", "local"),
+			localDir:      env.GetString("MEDIA_LOCAL_DIR", "./uploads"),
+			maxUploadSize: int64(env.GetInt("MEDIA_MAX_UPLOAD_MB", 10)) << 20,
+			workers:       env.GetInt("MEDIA_WORKERS", 2),
+			s3: media.S3Config{
+				Endpoint:  env.GetString("MEDIA_S3_ENDPOINT", "localhost:9000"),
+				Region:    env.GetString("MEDIA_S3_REGION", ""),
+				AccessKey: env.GetString("MEDIA_S3_ACCESS_KEY", ""),
+				SecretKey: env.GetString("MEDIA_S3_SECRET_KEY", ""),
+				Bucket:    env.GetString("MEDIA_S3_BUCKET", "gophersocial"),
+				UseSSL:    env.GetBool("MEDIA_S3_USE_SSL", false),
+				PublicURL: env.GetString("MEDIA_S3_PUBLIC_URL", ""),
+			},
+		},
+		trending: trendingConfig{
+			refresh:    time.Second * time.Duration(env.GetInt("TRENDING_REFRESH_SECONDS", 300)),
+			minAuthors: env.GetInt("TRENDING_MIN_AUTHORS", 3),
+			size:       env.GetInt("TRENDING_SIZE", 100),
+		},
 	}
 
 	logger := zap.Must(zap.NewProduction()).Sugar()
 	defer logger.Sync()
 
+	trendingWindows, err := parseTrendingWindows(env.GetString("TRENDING_WINDOWS", "1h,24h,7d"))
+	if err != nil {
+		logger.Fatal(err)
+	}
+	cfg.trending.windows = trendingWindows
+
+	// the refresh job's ticker can't run on a zero or negative interval
+	if cfg.trending.refresh <= 0 {
+		logger.Fatal("TRENDING_REFRESH_SECONDS must be positive")
+	}
+
 	DB, err := db.NewGorm(
 		cfg.db.addr,
 		cfg.db.maxOpenConns,
@@ -88,11 +150,40 @@ func main() {
 		cfg.rateLimiter.TimeFrame,
 	)
 
-	jwtAuthenticator := auth.NewJWTAuthenticator(
-		cfg.auth.token.secret,
-		cfg.auth.token.iss,
-		cfg.auth.token.iss,
-	)
+	var jwtAuthenticator auth.Authenticator
+	if cfg.auth.token.keysDir != "" {
+		keys, err := auth.LoadKeyDir(cfg.auth.token.keysDir)
+		if err != nil {
+			logger.Fatal("Failed to load token signing keys:", err)
+		}
+
+		jwtAuthenticator, err = auth.NewKeySetAuthenticator(
+			keys,
+			cfg.auth.token.activeKID,
+			cfg.auth.token.iss,
+			cfg.auth.token.iss,
+		)
+		if err != nil {
+			logger.Fatal("Failed to configure token signing:", err)
+		}
+		logger.Infow("asymmetric token signing enabled", "kid", cfg.auth.token.activeKID, "keys", len(keys))
+	} else {
+		if cfg.env == "production" && cfg.auth.token.secret == "example" {
+			logger.Fatal("AUTH_TOKEN_SECRET must be changed from its default in production")
+		}
+
+		jwtAuthenticator = auth.NewJWTAuthenticator(
+			cfg.auth.token.secret,
+			cfg.auth.token.iss,
+			cfg.auth.token.iss,
+		)
+	}
+
+	oidcProviders := map[string]*auth.OIDCProvider{}
+	if cfg.auth.oidc.Issuer != "" {
+		oidcProviders[cfg.auth.oidc.Name] = auth.NewOIDCProvider(cfg.auth.oidc, nil)
+		logger.Infow("oidc login enabled", "provider", cfg.auth.oidc.Name, "issuer", cfg.auth.oidc.Issuer)
+	}
 
 	var rdb *redis.Client
 	if cfg.redisCfg.enabled {
@@ -112,17 +203,45 @@ func main() {
 		}
 	}	
 
+	var blobs media.BlobStore
+	switch cfg.media.backend {
+	case "s3":
+		blobs, err = media.NewS3Store(context.Background(), cfg.media.s3)
+	case "local":
+		blobs, err = media.NewLocalStore(cfg.media.localDir, cfg.apiURL+mediaPath)
+	default:
+		err = fmt.Errorf("unknown MEDIA_BACKEND %q", cfg.media.backend)
+	}
+	if err != nil {
+		logger.Fatal("Failed to configure media storage:", err)
+	}
+
 	store := store.NewStorage(DB)
-	cacheStorage := cache.NewRedisStorage(rdb)
+	var cacheStorage cache.Storage
+	var memoryCache *cache.LRU
+	if cfg.redisCfg.enabled {
+		cacheStorage = cache.NewRedisStorage(rdb)
+		cacheStorage.Timelines = cache.NewTimelineStore(rdb, cfg.timeline.size, cfg.timeline.ttl)
+	} else {
+		memoryCache = cache.NewLRU(cfg.redisCfg.memorySize)
+		cacheStorage = cache.NewMemoryStorage(memoryCache)
+	}
+	// posts and users are read through the cache, and writes evict them
+	store = cache.NewCachingStorage(store, cacheStorage)
 
 	c := &application{
 		config:        cfg,
 		store:         store,
 		cacheStorage:  cacheStorage,
+		memoryCache:   memoryCache,
 		logger:        logger,
 		mailer:        mailerClient,
 		authenticator: jwtAuthenticator,
 		rateLimiter:   rateLimiter,
+		oidcProviders: oidcProviders,
+		blobs:         blobs,
+		mediaQueue:    make(chan uint, mediaQueueSize),
+		timelineQueue: make(chan uint, timelineQueueSize),
 	}
 	
 	if len(os.Args) > 1 && os.Args[1] == "migrate" {
@@ -131,7 +250,7 @@ func main() {
 		if action == "force" && len(os.Args) > 3 {
 			versionDB = os.Args[3]
 		}
-		RunMigrations(c, versionDB, action)
+		RunMigrations(c, DB, versionDB, action)
 		return
 	}
 	
@@ -140,6 +259,8 @@ func main() {
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 30 * time.Second,
 		IdleTimeout:  60 * time.Second,
+		// leave room for the multipart envelope around an upload
+		BodyLimit: int(cfg.media.maxUploadSize) + 1<<20,
 		ErrorHandler: func(c *fiber.Ctx, err error) error {
 			return c.Status(500).JSON(fiber.Map{
 			"error": err.Error(),
@@ -157,6 +278,10 @@ func main() {
 	)
 
 
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	c.startBackgroundJobs(jobsCtx)
+
 	mount(app ,c)
 	log.Fatal(app.Listen(cfg.addr))
 }
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/pangdfg/gopher-social/internal/media"
+	"github.com/pangdfg/gopher-social/internal/store"
+)
+
+const (
+	mediaPurgeBatch = 100
+	// mediaOrphanGrace gives clients time to create the post an upload is for.
+	mediaOrphanGrace = 24 * time.Hour
+)
+
+type MediaResponse struct {
+	ID          uint      `json:"id"`
+	Kind        string    `json:"kind"`
+	URL         string    `json:"url"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	Status      string    `json:"status"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// uploadMediaHandler godoc
+//
+//	@Summary		Uploads an image
+//	@Description	Uploads a JPEG, PNG or WebP image for a post or as the user's avatar. The type is detected from the file contents and EXIF/XMP metadata is stripped, apart from a JPEG's orientation. Resized variants and a blurhash are generated in the background; the upload is pending until then. Post media must be referenced by a post within a day or it is deleted.
+//	@Tags			media
+//	@Accept			multipart/form-data
+//	@Produce		json
+//	@Param			file	formData	file	true	"Image file"
+//	@Param			kind	formData	string	false	"post (default) or avatar"
+//	@Success		201		{object}	MediaResponse
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		413		{object}	error	"File too large"
+//	@Failure		415		{object}	error	"Unsupported media type"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/media [post]
+func (app *application) uploadMediaHandler(c *fiber.Ctx) error {
+	kind := c.FormValue("kind", store.MediaKindPost)
+	switch kind {
+	case store.MediaKindPost:
+		if !hasScope(c, scopePostsWrite) {
+			return app.forbiddenResponse(c)
+		}
+	case store.MediaKindAvatar:
+		if !hasScope(c, scopeUsersWrite) {
+			return app.forbiddenResponse(c)
+		}
+	default:
+		return app.badRequestResponse(c, errors.New("kind must be post or avatar"))
+	}
+
+	fh, err := c.FormFile("file")
+	if err != nil {
+		return app.badRequestResponse(c, errors.New("file is required"))
+	}
+	if fh.Size > app.config.media.maxUploadSize {
+		return writeJSONError(c, fiber.StatusRequestEntityTooLarge,
+			fmt.Sprintf("file exceeds the %d byte limit", app.config.media.maxUploadSize))
+	}
+
+	f, err := fh.Open()
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, app.config.media.maxUploadSize+1))
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+	if int64(len(data)) > app.config.media.maxUploadSize {
+		return writeJSONError(c, fiber.StatusRequestEntityTooLarge,
+			fmt.Sprintf("file exceeds the %d byte limit", app.config.media.maxUploadSize))
+	}
+
+	contentType, ext, err := media.Sniff(data)
+	if err != nil {
+		return writeJSONError(c, fiber.StatusUnsupportedMediaType, "only JPEG, PNG and WebP images are accepted")
+	}
+
+	// variants are encoded without EXIF, so they are turned upright from this
+	orientation := media.Orientation(contentType, data)
+
+	data, err = media.StripMetadata(contentType, data)
+	if err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	ctx := c.Context()
+	user := getUserFromContext(c)
+	key := fmt.Sprintf("%ss/%s/%s%s", kind, time.Now().UTC().Format("2006/01"), uuid.NewString(), ext)
+
+	if err := app.blobs.Put(ctx, key, bytes.NewReader(data), int64(len(data)), contentType); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	m := &store.MediaAttachment{
+		UserID:      user.ID,
+		Kind:        kind,
+		StorageKey:  key,
+		URL:         app.blobs.URL(key),
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		Status:      store.MediaStatusPending,
+		Orientation: orientation,
+	}
+	if err := app.store.Media.Create(ctx, m); err != nil {
+		app.deleteBlob(ctx, key)
+		return app.internalServerError(c, err)
+	}
+	app.enqueueMedia(m.ID)
+
+	if kind == store.MediaKindAvatar {
+		if err := app.setAvatar(ctx, user.ID, m.URL); err != nil {
+			return app.internalServerError(c, err)
+		}
+	}
+
+	return app.jsonResponse(c, fiber.StatusCreated, MediaResponse{
+		ID:          m.ID,
+		Kind:        m.Kind,
+		URL:         m.URL,
+		ContentType: m.ContentType,
+		Size:        m.Size,
+		Status:      m.Status,
+		CreatedAt:   m.CreatedAt,
+	})
+}
+
+// setAvatar points the user's avatar at an upload. The previous avatar
+// upload is left for the orphan purge.
+func (app *application) setAvatar(ctx context.Context, userID uint, url string) error {
+	user, err := app.store.Users.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	user.AvatarURL = url
+	if err := app.store.Users.UpdateProfile(ctx, user); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// purgeOrphanedMedia deletes uploads nothing references any more, blob first
+// so a failure leaves the row to retry.
+func (app *application) purgeOrphanedMedia(ctx context.Context) error {
+	orphans, err := app.store.Media.GetOrphaned(ctx, time.Now().Add(-mediaOrphanGrace), mediaPurgeBatch)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range orphans {
+		if err := app.blobs.Delete(ctx, m.StorageKey); err != nil {
+			app.logger.Errorw("media purge failed", "mediaID", m.ID, "error", err.Error())
+			continue
+		}
+		for _, v := range m.Variants {
+			app.deleteBlob(ctx, v.StorageKey)
+		}
+		if err := app.store.Media.Delete(ctx, m.ID); err != nil && err != store.ErrNotFound {
+			app.logger.Errorw("media purge failed", "mediaID", m.ID, "error", err.Error())
+		}
+	}
+
+	return nil
+}
+
+// deleteMediaBlobs removes an upload's files once its row is gone.
+func (app *application) deleteMediaBlobs(ctx context.Context, m *store.MediaAttachment) {
+	app.deleteBlob(ctx, m.StorageKey)
+	for _, v := range m.Variants {
+		app.deleteBlob(ctx, v.StorageKey)
+	}
+}
+
+func (app *application) deleteBlob(ctx context.Context, key string) {
+	if err := app.blobs.Delete(ctx, key); err != nil {
+		app.logger.Warnw("blob delete failed", "key", key, "error", err.Error())
+	}
+}
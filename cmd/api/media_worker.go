@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pangdfg/gopher-social/internal/media"
+	"github.com/pangdfg/gopher-social/internal/store"
+)
+
+const (
+	mediaQueueSize = 256
+	// mediaClaimTimeout after which a processing upload is assumed abandoned.
+	mediaClaimTimeout = 10 * time.Minute
+	mediaSweepBatch   = 100
+)
+
+// enqueueMedia hands an upload to the workers without blocking the request.
+// When the queue is full the periodic sweep picks it up instead.
+func (app *application) enqueueMedia(id uint) {
+	select {
+	case app.mediaQueue <- id:
+	default:
+	}
+}
+
+func (app *application) mediaWorker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case id := <-app.mediaQueue:
+			if err := app.processMedia(ctx, id); err != nil {
+				app.logger.Errorw("media processing failed", "mediaID", id, "error", err.Error())
+			}
+		}
+	}
+}
+
+// sweepUnprocessedMedia requeues uploads that missed the queue or whose
+// worker died mid-way.
+func (app *application) sweepUnprocessedMedia(ctx context.Context) error {
+	ids, err := app.store.Media.GetUnprocessedIDs(ctx, time.Now().Add(-mediaClaimTimeout), mediaSweepBatch)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		app.enqueueMedia(id)
+	}
+	return nil
+}
+
+// processMedia renders the variants and blurhash of an upload.
+func (app *application) processMedia(ctx context.Context, id uint) error {
+	m, err := app.store.Media.Claim(ctx, id, time.Now().Add(-mediaClaimTimeout))
+	if err == store.ErrNotFound {
+		// already processed, or claimed by another instance
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	variants, err := app.renderMedia(ctx, m)
+	if err != nil {
+		if markErr := app.store.Media.MarkFailed(ctx, m.ID); markErr != nil {
+			app.logger.Errorw("media mark failed", "mediaID", m.ID, "error", markErr.Error())
+		}
+		return err
+	}
+
+	if err := app.store.Media.SaveProcessed(ctx, m, variants); err != nil {
+		for _, v := range variants {
+			app.deleteBlob(ctx, v.StorageKey)
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (app *application) renderMedia(ctx context.Context, m *store.MediaAttachment) ([]store.MediaVariant, error) {
+	r, err := app.blobs.Get(ctx, m.StorageKey)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := media.Process(data, m.Orientation, media.DefaultVariants)
+	if err != nil {
+		return nil, err
+	}
+	m.Width, m.Height, m.Blurhash = res.Width, res.Height, res.Blurhash
+
+	base := strings.TrimSuffix(m.StorageKey, path.Ext(m.StorageKey))
+	variants := make([]store.MediaVariant, 0, len(res.Variants))
+	for _, v := range res.Variants {
+		key := fmt.Sprintf("%s_%s.jpg", base, v.Name)
+		if err := app.blobs.Put(ctx, key, bytes.NewReader(v.Data), int64(len(v.Data)), media.VariantContentType); err != nil {
+			for _, done := range variants {
+				app.deleteBlob(ctx, done.StorageKey)
+			}
+			return nil, err
+		}
+
+		variants = append(variants, store.MediaVariant{
+			Name:        v.Name,
+			StorageKey:  key,
+			URL:         app.blobs.URL(key),
+			ContentType: media.VariantContentType,
+			Width:       v.Width,
+			Height:      v.Height,
+			Size:        int64(len(v.Data)),
+		})
+	}
+
+	return variants, nil
+}
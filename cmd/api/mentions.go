@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"html"
+
+	"github.com/pangdfg/gopher-social/internal/mailer"
+	"github.com/pangdfg/gopher-social/internal/richtext"
+	"github.com/pangdfg/gopher-social/internal/store"
+)
+
+const (
+	// maxMentionedUsers caps notifications a single post or comment can send.
+	maxMentionedUsers = 20
+	mentionExcerptLen = 280
+)
+
+// mentionSource is the post or comment whose text is being synced.
+type mentionSource struct {
+	postID    uint
+	commentID uint // zero for a post
+	text      string
+}
+
+// resolveMentions turns @username spans into mentions of active users.
+// Unknown, inactive and excess usernames are left as plain text.
+func (app *application) resolveMentions(ctx context.Context, text string) ([]store.Mention, error) {
+	entities := richtext.Mentions(text)
+	if len(entities) == 0 {
+		return nil, nil
+	}
+
+	names := make([]string, 0, len(entities))
+	seen := make(map[string]bool, len(entities))
+	for _, e := range entities {
+		if !seen[e.Value] && len(names) < maxMentionedUsers {
+			seen[e.Value] = true
+			names = append(names, e.Value)
+		}
+	}
+
+	users, err := app.store.Users.GetByUsernames(ctx, names)
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]store.User, len(users))
+	for _, u := range users {
+		byName[u.Username] = u
+	}
+
+	mentions := make([]store.Mention, 0, len(entities))
+	for _, e := range entities {
+		u, ok := byName[e.Value]
+		if !ok {
+			continue
+		}
+		mentions = append(mentions, store.Mention{
+			UserID: u.ID,
+			User:   u,
+			Start:  e.Start,
+			End:    e.End,
+		})
+	}
+
+	return mentions, nil
+}
+
+// syncMentions stores the mentions in src and emails users who were not
+// mentioned in it before. Returned mentions carry their users for the
+// response.
+func (app *application) syncMentions(ctx context.Context, author *store.User, src mentionSource) ([]store.Mention, error) {
+	mentions, err := app.resolveMentions(ctx, src.text)
+	if err != nil {
+		return nil, err
+	}
+
+	var added []uint
+	if src.commentID != 0 {
+		added, err = app.store.Mentions.ReplaceForComment(ctx, src.commentID, mentions)
+	} else {
+		added, err = app.store.Mentions.ReplaceForPost(ctx, src.postID, mentions)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	notify := make(map[uint]bool, len(added))
+	for _, id := range added {
+		if id != author.ID {
+			notify[id] = true
+		}
+	}
+
+	var recipients []store.User
+	for _, m := range mentions {
+		if notify[m.UserID] {
+			delete(notify, m.UserID)
+			recipients = append(recipients, m.User)
+		}
+	}
+
+	if len(recipients) > 0 {
+		go app.sendMentionEmails(author.Username, src, recipients)
+	}
+
+	return mentions, nil
+}
+
+// sendMentionEmails runs off the request path; failures are only logged.
+func (app *application) sendMentionEmails(authorUsername string, src mentionSource, recipients []store.User) {
+	kind := "post"
+	if src.commentID != 0 {
+		kind = "comment"
+	}
+
+	excerpt := []rune(src.text)
+	if len(excerpt) > mentionExcerptLen {
+		excerpt = append(excerpt[:mentionExcerptLen], '…')
+	}
+
+	isProdEnv := app.config.env == "production"
+	for _, u := range recipients {
+		// the mailer renders with text/template, so user text is escaped here
+		mailVars := struct {
+			Username       string
+			AuthorUsername string
+			Kind           string
+			Excerpt        string
+			PostURL        string
+		}{
+			Username:       html.EscapeString(u.Username),
+			AuthorUsername: html.EscapeString(authorUsername),
+			Kind:           kind,
+			Excerpt:        html.EscapeString(string(excerpt)),
+			PostURL:        fmt.Sprintf("%s/posts/%d", app.config.frontendURL, src.postID),
+		}
+
+		if _, err := app.mailer.Send(mailer.UserMentionTemplate, u.Username, u.Email, mailVars, !isProdEnv); err != nil {
+			app.logger.Errorw("error sending mention email", "userID", u.ID, "error", err.Error())
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap"
+
+	"github.com/pangdfg/gopher-social/internal/store"
+)
+
+// namedUsers has an active user for every username, numbered in the order
+// they are first asked for.
+type namedUsers struct {
+	store.Users
+	ids    map[string]uint
+	lookup []string
+}
+
+func (f *namedUsers) GetByUsernames(_ context.Context, usernames []string) ([]store.User, error) {
+	f.lookup = usernames
+	users := make([]store.User, 0, len(usernames))
+	for _, name := range usernames {
+		if _, ok := f.ids[name]; !ok {
+			f.ids[name] = uint(len(f.ids) + 1)
+		}
+		users = append(users, store.User{ID: f.ids[name], Username: name, Email: name + "@example.com"})
+	}
+	return users, nil
+}
+
+// postMentions reports newly mentioned users the way MentionStore does.
+type postMentions struct {
+	store.Mentions
+	byPost map[uint][]uint
+}
+
+func (f *postMentions) ReplaceForPost(_ context.Context, postID uint, mentions []store.Mention) ([]uint, error) {
+	previous := map[uint]bool{}
+	for _, id := range f.byPost[postID] {
+		previous[id] = true
+	}
+
+	var added, ids []uint
+	for _, m := range mentions {
+		ids = append(ids, m.UserID)
+		if !previous[m.UserID] {
+			previous[m.UserID] = true
+			added = append(added, m.UserID)
+		}
+	}
+	f.byPost[postID] = ids
+	return added, nil
+}
+
+type recordingMailer struct {
+	mu   sync.Mutex
+	sent []string
+}
+
+func (m *recordingMailer) Send(_, username, _ string, _ any, _ bool) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, username)
+	return 200, nil
+}
+
+func (m *recordingMailer) recipients() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string{}, m.sent...)
+}
+
+func (m *recordingMailer) reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = nil
+}
+
+var _ = Describe("Mentions", func() {
+	var (
+		ctx    context.Context
+		app    *application
+		users  *namedUsers
+		mailer *recordingMailer
+		author *store.User
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		users = &namedUsers{ids: map[string]uint{}}
+		mailer = &recordingMailer{}
+		app = &application{
+			logger: zap.NewNop().Sugar(),
+			mailer: mailer,
+			store: store.Storage{
+				Users:    users,
+				Mentions: &postMentions{byPost: map[uint][]uint{}},
+			},
+		}
+
+		found, err := users.GetByUsernames(ctx, []string{"gopher"})
+		Expect(err).NotTo(HaveOccurred())
+		author = &found[0]
+	})
+
+	mention := func(text string) []store.Mention {
+		mentions, err := app.syncMentions(ctx, author, mentionSource{postID: 1, text: text})
+		Expect(err).NotTo(HaveOccurred())
+		return mentions
+	}
+
+	It("notifies only users who weren't mentioned before", func() {
+		mention("hi @ana and @bob")
+		Eventually(mailer.recipients).Should(ConsistOf("ana", "bob"))
+		mailer.reset()
+
+		mention("hi @ana, @bob and @cy")
+		Eventually(mailer.recipients).Should(ConsistOf("cy"))
+	})
+
+	It("keeps but doesn't notify a self-mention", func() {
+		mentions := mention("@gopher and @ana")
+
+		Expect(mentions).To(HaveLen(2))
+		Eventually(mailer.recipients).Should(ConsistOf("ana"))
+	})
+
+	It("notifies a user mentioned twice once", func() {
+		mentions := mention("@ana, again @ana")
+
+		Expect(mentions).To(HaveLen(2))
+		Eventually(mailer.recipients).Should(ConsistOf("ana"))
+	})
+
+	It("mentions at most maxMentionedUsers users", func() {
+		names := make([]string, 0, maxMentionedUsers+5)
+		for i := range maxMentionedUsers + 5 {
+			names = append(names, fmt.Sprintf("@user%d", i))
+		}
+
+		mentions := mention(strings.Join(names, " "))
+
+		Expect(users.lookup).To(HaveLen(maxMentionedUsers))
+		Expect(mentions).To(HaveLen(maxMentionedUsers))
+		Eventually(mailer.recipients).Should(HaveLen(maxMentionedUsers))
+	})
+})
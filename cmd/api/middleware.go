@@ -1,7 +1,6 @@
 package main
 
 import (
-	"context"
 	"fmt"
 	"strconv"
 	"strings"
@@ -9,6 +8,7 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
 
+	"github.com/pangdfg/gopher-social/internal/auth"
 	"github.com/pangdfg/gopher-social/internal/store"
 )
 
@@ -24,6 +24,10 @@ func (app *application) AuthTokenMiddleware(c *fiber.Ctx) error {
 	}
 
 	token := parts[1]
+	if strings.HasPrefix(token, auth.AccessTokenPrefix) {
+		return app.authenticateAccessToken(c, token)
+	}
+
 	jwtToken, err := app.authenticator.ValidateToken(token)
 	if err != nil {
 		return app.unauthorizedError(c, err)
@@ -31,12 +35,17 @@ func (app *application) AuthTokenMiddleware(c *fiber.Ctx) error {
 
 	claims := jwtToken.Claims.(jwt.MapClaims)
 
-	userID, err := strconv.ParseInt(fmt.Sprintf("%.f", claims["sub"]), 10, 64)
+	// activation and 2FA challenge tokens carry a type and are never access tokens
+	if _, ok := claims["type"]; ok {
+		return app.unauthorizedError(c, fmt.Errorf("token cannot be used for authentication"))
+	}
+
+	userID, err := subjectFromClaims(claims)
 	if err != nil {
 		return app.unauthorizedError(c, err)
 	}
 
-	user, err := app.getUser(c.Context(), uint(userID))
+	user, err := app.store.Users.GetByID(c.Context(), userID)
 	if err != nil {
 		return app.unauthorizedError(c, err)
 	}
@@ -45,30 +54,142 @@ func (app *application) AuthTokenMiddleware(c *fiber.Ctx) error {
 	return c.Next()
 }
 
+// optionalAuth authenticates the request when it carries credentials and
+// lets anonymous requests through, for public routes that show more to a
+// signed in viewer. Invalid credentials are still rejected.
+func (app *application) optionalAuth(c *fiber.Ctx) error {
+	if c.Get("Authorization") == "" {
+		return c.Next()
+	}
+	return app.AuthTokenMiddleware(c)
+}
+
+// authenticateAccessToken resolves a personal access token and stores its
+// scopes alongside the user so requireScope can enforce them.
+func (app *application) authenticateAccessToken(c *fiber.Ctx, plain string) error {
+	ctx := c.Context()
+
+	token, err := app.store.AccessTokens.GetByToken(ctx, plain)
+	if err != nil {
+		if err == store.ErrNotFound {
+			return app.unauthorizedError(c, fmt.Errorf("access token is invalid or expired"))
+		}
+		return app.internalServerError(c, err)
+	}
+
+	user, err := app.store.Users.GetByID(ctx, token.UserID)
+	if err != nil {
+		return app.unauthorizedError(c, err)
+	}
+
+	if err := app.store.AccessTokens.Touch(ctx, token); err != nil {
+		app.logger.Warnw("access token touch failed", "tokenID", token.ID, "error", err.Error())
+	}
+
+	c.Locals("user", user)
+	c.Locals("scopes", []string(token.Scopes))
+	return c.Next()
+}
+
+// requireScope rejects personal access tokens that weren't granted scope.
+func (app *application) requireScope(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !hasScope(c, scope) {
+			return app.forbiddenResponse(c)
+		}
+		return c.Next()
+	}
+}
+
+// hasScope is for handlers whose required scope depends on the request.
+func hasScope(c *fiber.Ctx, scope string) bool {
+	scopes, ok := c.Locals("scopes").([]string)
+	if !ok {
+		return true
+	}
+
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// requireSession limits a route to interactive JWT logins, keeping account
+// security settings out of reach of personal access tokens.
+func (app *application) requireSession(c *fiber.Ctx) error {
+	if _, ok := c.Locals("scopes").([]string); ok {
+		return app.forbiddenResponse(c)
+	}
+	return c.Next()
+}
+
+func subjectFromClaims(claims jwt.MapClaims) (uint, error) {
+	userID, err := strconv.ParseInt(fmt.Sprintf("%.f", claims["sub"]), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return uint(userID), nil
+}
+
 func (app *application) AuthActive(c *fiber.Ctx, token string)  (Email string, err error) {
 
-	jwtToken, err := app.authenticator.ValidateTokenAuth(token)
+	claims, err := app.parseActionToken(token, "activation")
 	if err != nil {
 		return "", app.unauthorizedError(c, err)
 	}
 
-	claims := jwtToken.Claims.(jwt.MapClaims)
-
-	Email = claims["email"].(string)
+	Email, ok := claims["email"].(string)
+	if !ok {
+		return "", app.unauthorizedError(c, fmt.Errorf("token has no email"))
+	}
 
 	return Email, err
 }
 
+// parseActionToken validates a single-purpose emailed token (activation,
+// email change) and checks it was issued for tokenType.
+func (app *application) parseActionToken(token, tokenType string) (jwt.MapClaims, error) {
+	jwtToken, err := app.authenticator.ValidateTokenAuth(token)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := jwtToken.Claims.(jwt.MapClaims)
+	if claims["type"] != tokenType {
+		return nil, fmt.Errorf("token is not a %s token", tokenType)
+	}
+
+	return claims, nil
+}
+
 func (app *application) checkRolePrecedence(c *fiber.Ctx, user *store.User, roleName string) (bool, error) {
 	role, err := app.store.Roles.GetByName(c.Context(), roleName)
 	if err != nil {
 		return false, err
 	}
 
-	return user.Role.Level <= role.Level, nil
+	return user.Role.Level >= role.Level, nil
+}
+
+// requireRole limits a route to users whose role is at least roleName.
+func (app *application) requireRole(roleName string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		allowed, err := app.checkRolePrecedence(c, getUserFromContext(c), roleName)
+		if err != nil {
+			return app.internalServerError(c, err)
+		}
+		if !allowed {
+			return app.forbiddenResponse(c)
+		}
+		return c.Next()
+	}
 }
 
-func (app *application) checkPostOwnership() fiber.Handler {
+// checkPostOwnership lets the author through, and others whose role is at
+// least roleName.
+func (app *application) checkPostOwnership(roleName string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		user := c.Locals("user").(*store.User)
 		post := c.Locals("post").(*store.Post)
@@ -77,7 +198,7 @@ func (app *application) checkPostOwnership() fiber.Handler {
 			return c.Next()
 		}
 
-		allowed, err := app.checkRolePrecedence(c , user, "user")
+		allowed, err := app.checkRolePrecedence(c , user, roleName)
 		if err != nil {
 			return app.internalServerError(c, err)
 		}
@@ -103,25 +224,3 @@ func (app *application) RateLimiterMiddleware(c *fiber.Ctx) error {
 	return c.Next()
 }
 
-func (app *application) getUser(ctx context.Context, id uint) (*store.User, error) {
-	if !app.config.redisCfg.enabled {
-		return app.store.Users.GetByID(ctx, id)
-	}
-	user, err := app.cacheStorage.UserCache.Get(ctx, id)
-	if err != nil {
-		if err != store.ErrNotFound {
-			return nil, err
-		}
-
-		user, err = app.store.Users.GetByID(ctx, id)
-		if err != nil {
-			return nil, err
-		}
-		err = app.cacheStorage.UserCache.Set(ctx, user)
-		if err != nil {
-			return nil, err
-		}
-	}
-	return user, nil
-}
-
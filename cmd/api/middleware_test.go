@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap"
+
+	"github.com/pangdfg/gopher-social/internal/auth"
+	"github.com/pangdfg/gopher-social/internal/store"
+)
+
+// fakeRoles has the levels the roles migration seeds.
+type fakeRoles struct{}
+
+func (fakeRoles) GetByName(_ context.Context, name string) (*store.Role, error) {
+	levels := map[string]int{"user": 1, "moderator": 2, "admin": 3}
+	level, ok := levels[name]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return &store.Role{Name: name, Level: level}, nil
+}
+
+// liveTokens holds the personal access tokens that are neither expired
+// nor revoked, by plain text value.
+type liveTokens struct {
+	store.AccessTokens
+	tokens map[string]*store.AccessToken
+}
+
+func (f *liveTokens) GetByToken(_ context.Context, plain string) (*store.AccessToken, error) {
+	token, ok := f.tokens[plain]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return token, nil
+}
+
+func (f *liveTokens) Touch(context.Context, *store.AccessToken) error {
+	return nil
+}
+
+type idUsers struct {
+	store.Users
+}
+
+func (idUsers) GetByID(_ context.Context, id uint) (*store.User, error) {
+	return &store.User{ID: id}, nil
+}
+
+var _ = Describe("Authentication", func() {
+	const readToken = auth.AccessTokenPrefix + "read"
+
+	var (
+		app *application
+		f   *fiber.App
+	)
+
+	BeforeEach(func() {
+		app = &application{
+			logger:        zap.NewNop().Sugar(),
+			authenticator: auth.NewJWTAuthenticator("secret", "gophersocial", "gophersocial"),
+			store: store.Storage{
+				Users: idUsers{},
+				AccessTokens: &liveTokens{tokens: map[string]*store.AccessToken{
+					readToken: {ID: 1, UserID: 2, Scopes: []string{scopeRead}},
+				}},
+			},
+		}
+
+		ok := func(c *fiber.Ctx) error {
+			return c.SendStatus(fiber.StatusOK)
+		}
+		f = fiber.New()
+		f.Get("/posts", app.AuthTokenMiddleware, app.requireScope(scopeRead), ok)
+		f.Post("/posts", app.AuthTokenMiddleware, app.requireScope(scopePostsWrite), ok)
+		f.Get("/tokens", app.AuthTokenMiddleware, app.requireSession, ok)
+	})
+
+	request := func(method, path, token string) int {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err := f.Test(req, -1)
+		Expect(err).NotTo(HaveOccurred())
+		return resp.StatusCode
+	}
+
+	Context("with a personal access token", func() {
+		It("allows routes within its scopes", func() {
+			Expect(request(fiber.MethodGet, "/posts", readToken)).To(Equal(fiber.StatusOK))
+		})
+
+		It("refuses routes needing a scope it wasn't granted", func() {
+			Expect(request(fiber.MethodPost, "/posts", readToken)).To(Equal(fiber.StatusForbidden))
+		})
+
+		It("refuses session only routes", func() {
+			Expect(request(fiber.MethodGet, "/tokens", readToken)).To(Equal(fiber.StatusForbidden))
+		})
+
+		It("rejects an expired or revoked token", func() {
+			Expect(request(fiber.MethodGet, "/posts", auth.AccessTokenPrefix+"revoked")).To(Equal(fiber.StatusUnauthorized))
+		})
+	})
+
+	Context("with a JWT", func() {
+		var token string
+
+		BeforeEach(func() {
+			var err error
+			token, err = app.authenticator.GenerateToken(jwt.MapClaims{
+				"sub": 2,
+				"aud": "gophersocial",
+				"iss": "gophersocial",
+				"exp": time.Now().Add(time.Hour).Unix(),
+			})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("passes every scope and session check", func() {
+			Expect(request(fiber.MethodGet, "/posts", token)).To(Equal(fiber.StatusOK))
+			Expect(request(fiber.MethodPost, "/posts", token)).To(Equal(fiber.StatusOK))
+			Expect(request(fiber.MethodGet, "/tokens", token)).To(Equal(fiber.StatusOK))
+		})
+
+		It("rejects single purpose tokens", func() {
+			challenge, err := app.authenticator.GenerateToken(jwt.MapClaims{
+				"sub":  2,
+				"type": twoFactorChallengeType,
+				"aud":  "gophersocial",
+				"iss":  "gophersocial",
+				"exp":  time.Now().Add(time.Hour).Unix(),
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(request(fiber.MethodGet, "/posts", challenge)).To(Equal(fiber.StatusUnauthorized))
+		})
+	})
+})
+
+var _ = Describe("Role checks", func() {
+	const authorID = 1
+
+	var app *application
+
+	BeforeEach(func() {
+		app = &application{
+			logger: zap.NewNop().Sugar(),
+			store:  store.Storage{Roles: fakeRoles{}},
+		}
+	})
+
+	// serve runs a request as a user with role through the handlers.
+	serve := func(method string, userID uint, role string, handlers ...fiber.Handler) int {
+		withUser := func(c *fiber.Ctx) error {
+			r, _ := fakeRoles{}.GetByName(c.Context(), role)
+			c.Locals("user", &store.User{ID: userID, Role: *r})
+			c.Locals("post", &store.Post{ID: 7, UserID: authorID})
+			return c.Next()
+		}
+		ok := func(c *fiber.Ctx) error {
+			return c.SendStatus(fiber.StatusOK)
+		}
+
+		f := fiber.New()
+		f.Add(method, "/", append(append([]fiber.Handler{withUser}, handlers...), ok)...)
+
+		resp, err := f.Test(httptest.NewRequest(method, "/", nil), -1)
+		Expect(err).NotTo(HaveOccurred())
+		return resp.StatusCode
+	}
+
+	DescribeTable("requireRole",
+		func(role string, status int) {
+			Expect(serve(fiber.MethodPatch, 2, role, app.requireRole("admin"))).To(Equal(status))
+		},
+		Entry("refuses a user", "user", fiber.StatusForbidden),
+		Entry("refuses a moderator", "moderator", fiber.StatusForbidden),
+		Entry("lets an admin through", "admin", fiber.StatusOK),
+	)
+
+	DescribeTable("checkPostOwnership on post edits",
+		func(role string, status int) {
+			Expect(serve(fiber.MethodPatch, 2, role, app.checkPostOwnership("moderator"))).To(Equal(status))
+		},
+		Entry("refuses another user", "user", fiber.StatusForbidden),
+		Entry("lets a moderator through", "moderator", fiber.StatusOK),
+		Entry("lets an admin through", "admin", fiber.StatusOK),
+	)
+
+	DescribeTable("checkPostOwnership on post deletes",
+		func(role string, status int) {
+			Expect(serve(fiber.MethodDelete, 2, role, app.checkPostOwnership("admin"))).To(Equal(status))
+		},
+		Entry("refuses another user", "user", fiber.StatusForbidden),
+		Entry("refuses a moderator", "moderator", fiber.StatusForbidden),
+		Entry("lets an admin through", "admin", fiber.StatusOK),
+	)
+
+	It("lets the author through whatever their role", func() {
+		Expect(serve(fiber.MethodDelete, authorID, "user", app.checkPostOwnership("admin"))).To(Equal(fiber.StatusOK))
+	})
+})
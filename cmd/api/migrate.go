@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -15,6 +16,9 @@ import (
 	"github.com/golang-migrate/migrate/v4/database/postgres"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 	_ "github.com/lib/pq"
+	"gorm.io/gorm"
+
+	"github.com/pangdfg/gopher-social/internal/store"
 )
 
 func CreateMigration(name string) error {
@@ -42,7 +46,7 @@ func CreateMigration(name string) error {
 	return nil
 }
 
-func RunMigrations(app *application, versionArg string, action string) {
+func RunMigrations(app *application, gormDB *gorm.DB, versionArg string, action string) {
 
 	dbURL := app.config.db.addr
 
@@ -81,6 +85,15 @@ func RunMigrations(app *application, versionArg string, action string) {
 				log.Fatal("Migration up error:", err)
 			}
 			fmt.Println("Migrations applied successfully.")
+
+			// data the SQL can't derive the way the API does
+			named, err := store.NewTagStore(gormDB).BackfillNames(context.Background())
+			if err != nil {
+				log.Fatal("Tag name backfill error:", err)
+			}
+			if named > 0 {
+				fmt.Println("Named", named, "tags.")
+			}
 		
 		case "down":
 			fmt.Println("Running migrations DOWN...")
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap"
+
+	"github.com/pangdfg/gopher-social/internal/auth"
+	"github.com/pangdfg/gopher-social/internal/store"
+)
+
+// emailUsers holds users by email and by ID.
+type emailUsers struct {
+	store.Users
+	byEmail map[string]*store.User
+}
+
+func (f *emailUsers) GetByEmail(_ context.Context, email string) (*store.User, error) {
+	if u, ok := f.byEmail[email]; ok {
+		return u, nil
+	}
+	return nil, store.ErrNotFound
+}
+
+func (f *emailUsers) GetByID(_ context.Context, id uint) (*store.User, error) {
+	for _, u := range f.byEmail {
+		if u.ID == id {
+			return u, nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+func (f *emailUsers) Activate(context.Context, uint) error {
+	Fail("an unactivated account must not be activated by a sign-in")
+	return nil
+}
+
+// linkedIdentities records the identities it links and provisions.
+type linkedIdentities struct {
+	store.Identities
+	linked      []store.UserIdentity
+	provisioned []*store.User
+}
+
+func (f *linkedIdentities) GetByProviderSubject(context.Context, string, string) (*store.UserIdentity, error) {
+	return nil, store.ErrNotFound
+}
+
+func (f *linkedIdentities) Create(_ context.Context, identity *store.UserIdentity) error {
+	f.linked = append(f.linked, *identity)
+	return nil
+}
+
+func (f *linkedIdentities) Provision(_ context.Context, user *store.User, _ string, identity *store.UserIdentity) error {
+	user.ID = 100
+	identity.UserID = user.ID
+	f.provisioned = append(f.provisioned, user)
+	return nil
+}
+
+var _ = Describe("OIDC sign-in", func() {
+	var (
+		ctx        context.Context
+		app        *application
+		users      *emailUsers
+		identities *linkedIdentities
+		id         *auth.OIDCIdentity
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		users = &emailUsers{byEmail: map[string]*store.User{}}
+		identities = &linkedIdentities{}
+		app = &application{
+			logger: zap.NewNop().Sugar(),
+			store: store.Storage{
+				Users:      users,
+				Identities: identities,
+				Roles:      fakeRoles{},
+			},
+		}
+		id = &auth.OIDCIdentity{Subject: "sub-1", Email: "gopher@example.com", EmailVerified: true}
+	})
+
+	It("links an active account with the same email", func() {
+		users.byEmail[id.Email] = &store.User{ID: 1, Email: id.Email, IsActive: true}
+
+		user, err := app.resolveOIDCUser(ctx, "oidc", id)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(user.ID).To(Equal(uint(1)))
+		Expect(identities.linked).To(ConsistOf(HaveField("UserID", uint(1))))
+		Expect(identities.provisioned).To(BeEmpty())
+	})
+
+	It("provisions a fresh account instead of taking over an unactivated one", func() {
+		users.byEmail[id.Email] = &store.User{ID: 1, Email: id.Email}
+
+		user, err := app.resolveOIDCUser(ctx, "oidc", id)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(user.ID).To(Equal(uint(100)))
+		Expect(identities.linked).To(BeEmpty())
+		Expect(identities.provisioned).To(HaveLen(1))
+	})
+})
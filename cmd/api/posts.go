@@ -3,6 +3,7 @@ package main
 import (
 	"errors"
 	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/pangdfg/gopher-social/internal/store"
@@ -14,9 +15,21 @@ type CreateCommentPayload struct {
 }
 
 type CreatePostPayload struct {
-	Title   string   `json:"title" validate:"required,max=100"`
-	Content string   `json:"content" validate:"required,max=1000"`
-	Tags    []string `json:"tags"`
+	Title       string              `json:"title" validate:"required,max=100"`
+	Content     string              `json:"content" validate:"required,max=1000"`
+	Tags        []string            `json:"tags" validate:"omitempty,max=10"`
+	Attachments []AttachmentPayload `json:"attachments" validate:"omitempty,max=4,dive"`
+	// QuoteOfID makes the post a quote of another post
+	QuoteOfID   *uint               `json:"quote_of_id"`
+	// Status defaults to published, or to scheduled when PublishAt is set
+	Status      string              `json:"status" validate:"omitempty,oneof=draft scheduled published"`
+	PublishAt   *time.Time          `json:"publish_at"`
+}
+
+// AttachmentPayload references an upload from POST /media.
+type AttachmentPayload struct {
+	ID      uint   `json:"id" validate:"required"`
+	AltText string `json:"alt_text" validate:"max=1000"`
 }
 
 func (app *application) postsContextMiddleware(c *fiber.Ctx) error {
@@ -25,37 +38,21 @@ func (app *application) postsContextMiddleware(c *fiber.Ctx) error {
 	if err != nil {
 		return app.badRequestResponse(c, err)
 	}
-	var post *store.Post
 
-	if app.config.redisCfg.enabled {
-		post, err = app.cacheStorage.PostCache.Get(c.Context(), uint(id))
-		if err != nil && err != store.ErrNotFound {
-			app.logger.Warnw("cache get failed", "postID", id, "error", err.Error())
-			post = nil
+	post, err := app.store.Posts.GetByID(c.Context(), uint(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			return app.notFoundResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
 		}
 	}
 
-	if post == nil {
-		post, err = app.store.Posts.GetByID(c.Context(), uint(id))
-		if err != nil {
-			switch {
-			case errors.Is(err, store.ErrNotFound):
-				return app.notFoundResponse(c, err)
-			default:
-				return app.internalServerError(c, err)
-			}
-		}
-
-		c.Locals("post", post)
-
-		if app.config.redisCfg.enabled {
-			if err := app.cacheStorage.PostCache.Set(c.Context(), post); err != nil {
-				app.logger.Warnw("cache set failed", "postID", post.ID, "error", err.Error())
-			}
-		}
-	} else {
-		c.Locals("post", post)
+	if !visibleTo(post, getUserFromContext(c)) {
+		return app.notFoundResponse(c, store.ErrNotFound)
 	}
+	c.Locals("post", post)
 
 	return c.Next()
 }
@@ -63,7 +60,7 @@ func (app *application) postsContextMiddleware(c *fiber.Ctx) error {
 // CreatePost godoc
 //
 //	@Summary		Creates a post
-//	@Description	Creates a post
+//	@Description	Creates a post, or a quote post when quote_of_id is set. Posts can be kept as drafts or scheduled with publish_at.
 //	@Tags			posts
 //	@Accept			json
 //	@Produce		json
@@ -79,28 +76,90 @@ func (app *application) createPostHandler(c *fiber.Ctx) error {
 	if err := readJSON(c, &payload); err != nil {
 		return writeJSONError(c, fiber.StatusBadRequest, "invalid JSON body")
 	}
+	if err := Validate.Struct(payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
 
 	ctx := c.Context()
 	user := c.Locals("user").(*store.User)
 
-	var tags []store.Tag
-	for _, t := range payload.Tags {
-		tag := store.Tag{Title: t}
-		if err := app.store.Tags.Create(ctx, &tag); err != nil {
+	status, publishAt, err := resolvePostStatus(payload.Status, payload.PublishAt, time.Now())
+	if err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	kind := store.PostKindPost
+	var quoteOfID *uint
+	if payload.QuoteOfID != nil {
+		quoted, err := app.store.Posts.GetByID(ctx, *payload.QuoteOfID)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				return app.badRequestResponse(c, errors.New("quoted post not found"))
+			default:
+				return app.internalServerError(c, err)
+			}
+		}
+		if quoted.Status != store.PostStatusPublished {
+			return app.badRequestResponse(c, errors.New("quoted post not found"))
+		}
+		target := repostTarget(quoted)
+		kind, quoteOfID = store.PostKindQuote, &target
+	}
+
+	tags, err := app.resolveTags(ctx, payload.Tags, payload.Content)
+	if err != nil {
+		var invalid errInvalidTag
+		switch {
+		case errors.As(err, &invalid):
+			return app.badRequestResponse(c, err)
+		default:
 			return app.internalServerError(c, err)
-		}	
-		tags = append(tags, tag)
+		}
 	}
 	post := &store.Post{
 		Title:   payload.Title,
 		Content: payload.Content,
 		UserID:  user.ID,
 		Tags: tags,
+		Kind:      kind,
+		QuoteOfID: quoteOfID,
+		Status:    status,
+		PublishAt: publishAt,
 	}
 
 	if err := app.store.Posts.Create(ctx, post); err != nil {
 		return app.internalServerError(c, err)
 	}	
+
+	if len(payload.Attachments) > 0 {
+		items := make([]store.MediaAttachment, 0, len(payload.Attachments))
+		for _, a := range payload.Attachments {
+			items = append(items, store.MediaAttachment{ID: a.ID, AltText: a.AltText})
+		}
+
+		if err := app.store.Media.AttachToPost(ctx, user.ID, post.ID, items); err != nil {
+			// don't leave a post behind without the media it was created with
+			if delErr := app.store.Posts.Purge(ctx, post.ID); delErr != nil {
+				app.logger.Errorw("post rollback failed", "postID", post.ID, "error", delErr.Error())
+			}
+
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				return app.badRequestResponse(c, errors.New("attachments must be your own unused post uploads"))
+			default:
+				return app.internalServerError(c, err)
+			}
+		}
+	}
+
+	// mentioned users hear of drafts once they are published
+	if status == store.PostStatusPublished {
+		if _, err := app.syncMentions(ctx, user, mentionSource{postID: post.ID, text: post.Content}); err != nil {
+			return app.internalServerError(c, err)
+		}
+		app.enqueueFanout(post.ID)
+	}
 	
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
 		"data": payload,
@@ -110,7 +169,7 @@ func (app *application) createPostHandler(c *fiber.Ctx) error {
 // GetPost godoc
 //
 //	@Summary		Fetches a post
-//	@Description	Fetches a post by ID
+//	@Description	Fetches a post by ID. The ETag header is what PATCH and DELETE need in If-Match.
 //	@Tags			posts
 //	@Accept			json
 //	@Produce		json
@@ -132,19 +191,24 @@ func (app *application) getPostHandler(c *fiber.Ctx) error {
 	response := NewPostResponse(
 		post,
 	)
+	c.Set(fiber.HeaderETag, versionETag(post.Version))
 	return c.Status(fiber.StatusOK).JSON(response)
 }
 
 // DeletePost godoc
 //
 //	@Summary		Deletes a post
-//	@Description	Delete a post by ID
+//	@Description	Moves a post to the trash. Admins can delete other users' posts. Its author or an admin can restore it until the trash retention period is over.
 //	@Tags			posts
 //	@Accept			json
 //	@Produce		json
-//	@Param			id	path		int	true	"Post ID"
+//	@Param			id			path		int		true	"Post ID"
+//	@Param			If-Match	header		string	true	"ETag of the post"
 //	@Success		204	{object} string
+//	@Failure		403	{object}	error
 //	@Failure		404	{object}	error
+//	@Failure		412	{object}	error	"Post changed since it was fetched"
+//	@Failure		428	{object}	error	"If-Match missing"
 //	@Failure		500	{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/posts/{id} [delete]
@@ -154,16 +218,19 @@ func (app *application) deletePostHandler(c *fiber.Ctx) error {
 		return app.internalServerError(c, errors.New("post context missing"))
 	}
 
+	// checkPostOwnership let the author or an admin through
 	authUser := getUserFromContext(c)
-	isOwner := post.UserID == authUser.ID
-	if !isOwner {
-		return app.forbiddenResponse(c)
+
+	if err := checkIfMatch(c, post.Version); err != nil {
+		return app.ifMatchResponse(c, err)
 	}
 
-	if err := app.store.Posts.Delete(c.Context(), uint(post.ID)); err != nil {
+	if err := app.store.Posts.Delete(c.Context(), uint(post.ID), post.Version, authUser.ID); err != nil {
 		switch {
 		case errors.Is(err, store.ErrNotFound):
 			return app.notFoundResponse(c, err)
+		case errors.Is(err, store.ErrConflict):
+			return app.preconditionFailedResponse(c)
 		default:
 			return app.internalServerError(c, err)
 		}
@@ -175,22 +242,31 @@ func (app *application) deletePostHandler(c *fiber.Ctx) error {
 // UpdatePost godoc
 //
 //	@Summary		Updates a post
-//	@Description	Updates a post by ID
+//	@Description	Updates a post by ID. Moderators and admins can update other users' posts. Drafts and scheduled posts can be rescheduled or published; published posts stay published.
 //	@Tags			posts
 //	@Accept			json
 //	@Produce		json
-//	@Param			id		path		int					true	"Post ID"
-//	@Param			payload	body		UpdatePostPayload	true	"Post payload"
+//	@Param			id			path		int					true	"Post ID"
+//	@Param			If-Match	header		string				true	"ETag of the post"
+//	@Param			payload		body		UpdatePostPayload	true	"Post payload"
 //	@Success		200		{object}	store.Post
 //	@Failure		400		{object}	error
 //	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
 //	@Failure		404		{object}	error
+//	@Failure		412		{object}	error	"Post changed since it was fetched"
+//	@Failure		428		{object}	error	"If-Match missing"
 //	@Failure		500		{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/posts/{id} [patch]
 type UpdatePostPayload struct {
 	Title   *string `json:"title" validate:"omitempty,max=100"`
 	Content *string `json:"content" validate:"omitempty,max=1000"`
+	// Tags replaces the explicit tags; #hashtags in the content are always added
+	Tags *[]string `json:"tags" validate:"omitempty,max=10"`
+	// Status and PublishAt only apply to drafts and scheduled posts
+	Status    *string    `json:"status" validate:"omitempty,oneof=draft scheduled published"`
+	PublishAt *time.Time `json:"publish_at"`
 }
 
 func (app *application) updatePostHandler(c *fiber.Ctx) error {
@@ -199,10 +275,12 @@ func (app *application) updatePostHandler(c *fiber.Ctx) error {
 		return app.internalServerError(c, errors.New("post context missing"))
 	}
 
-	authUser := getUserFromContext(c)
-	isOwner := post.UserID == authUser.ID
-	if !isOwner {
-		return app.forbiddenResponse(c)
+	if post.Kind == store.PostKindRepost {
+		return app.badRequestResponse(c, errors.New("reposts can't be edited"))
+	}
+
+	if err := checkIfMatch(c, post.Version); err != nil {
+		return app.ifMatchResponse(c, err)
 	}
 
 	var payload UpdatePostPayload
@@ -214,6 +292,44 @@ func (app *application) updatePostHandler(c *fiber.Ctx) error {
 		return app.badRequestResponse(c, err)
 	}
 
+	wasPublished := post.Status == store.PostStatusPublished
+	if payload.Status != nil || payload.PublishAt != nil {
+		if wasPublished {
+			if payload.PublishAt != nil || *payload.Status != store.PostStatusPublished {
+				return app.badRequestResponse(c, errors.New("published posts can't be unpublished or rescheduled"))
+			}
+		} else {
+			status, publishAt := post.Status, post.PublishAt
+			if payload.Status != nil {
+				status = *payload.Status
+				if status != store.PostStatusScheduled {
+					publishAt = nil
+				}
+			} else {
+				status = store.PostStatusScheduled
+			}
+			if payload.PublishAt != nil {
+				publishAt = payload.PublishAt
+			}
+
+			var err error
+			post.Status, post.PublishAt, err = resolvePostStatus(status, publishAt, time.Now())
+			if err != nil {
+				return app.badRequestResponse(c, err)
+			}
+		}
+	}
+	publishing := !wasPublished && post.Status == store.PostStatusPublished
+	if publishing {
+		// a post's time is when it went public
+		post.CreatedAt = time.Now()
+	}
+
+	contentChanged := payload.Content != nil && *payload.Content != post.Content
+	explicit := explicitTags(post)
+	if payload.Tags != nil {
+		explicit = *payload.Tags
+	}
 	if payload.Content != nil {
 		post.Content = *payload.Content
 	}
@@ -221,14 +337,47 @@ func (app *application) updatePostHandler(c *fiber.Ctx) error {
 		post.Title = *payload.Title
 	}
 
+	var tags []store.Tag
+	retag := contentChanged || payload.Tags != nil
+	if retag {
+		var err error
+		tags, err = app.resolveTags(c.Context(), explicit, post.Content)
+		if err != nil {
+			var invalid errInvalidTag
+			switch {
+			case errors.As(err, &invalid):
+				return app.badRequestResponse(c, err)
+			default:
+				return app.internalServerError(c, err)
+			}
+		}
+	}
+
 	if err := app.store.Posts.Update(c.Context(), post); err != nil {
 	switch {
 	case errors.Is(err, store.ErrConflict):
-		return app.conflictResponse(c, err)
+		return app.preconditionFailedResponse(c)
 	default:
 		return app.internalServerError(c, err)
 	}
 	}
+
+	if retag {
+		if err := app.store.Posts.SetTags(c.Context(), post.ID, tags); err != nil {
+			return app.internalServerError(c, err)
+		}
+	}
+
+	if publishing || (contentChanged && post.Status == store.PostStatusPublished) {
+		if _, err := app.syncMentions(c.Context(), &post.User, mentionSource{postID: post.ID, text: post.Content}); err != nil {
+			return app.internalServerError(c, err)
+		}
+	}
+
+	if publishing {
+		app.enqueueFanout(post.ID)
+	}
+
 	updatedPost, err := app.store.Posts.GetByID(c.Context(), post.ID)
 	if err != nil {
 		return app.internalServerError(c, err)
@@ -237,6 +386,7 @@ func (app *application) updatePostHandler(c *fiber.Ctx) error {
 	response := NewPostResponse(
 		updatedPost,
 	)
+	c.Set(fiber.HeaderETag, versionETag(updatedPost.Version))
 
 	return app.jsonResponse(c, fiber.StatusOK, response)
 }
@@ -268,6 +418,10 @@ func (app *application) createCommentHandler(c *fiber.Ctx) error {
 		return app.internalServerError(c, err)
 	}
 
+	if post := c.Locals("post").(*store.Post); post.Status != store.PostStatusPublished {
+		return app.badRequestResponse(c, errors.New("unpublished posts can't be commented on"))
+	}
+
 	user := c.Locals("user").(*store.User)
 	comment := &store.Comment{
 		PostID:  uint(id),
@@ -280,6 +434,16 @@ func (app *application) createCommentHandler(c *fiber.Ctx) error {
 	if err := app.store.Comments.Create(ctx, comment); err != nil {
 		return app.internalServerError(c, err)
 	}	
+
+	mentions, err := app.syncMentions(ctx, user, mentionSource{
+		postID:    comment.PostID,
+		commentID: comment.ID,
+		text:      comment.Content,
+	})
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+	comment.Mentions = mentions
 	
 	return app.jsonResponse(c, fiber.StatusCreated, fiber.Map{
 		"data": comment,
@@ -289,12 +453,12 @@ func (app *application) createCommentHandler(c *fiber.Ctx) error {
 // getTagHandler godoc
 //
 // @Summary      Get tag by ID
-// @Description  Retrieve a single tag by its ID including related posts.
+// @Description  Retrieve a single tag by its ID including related posts, its follower count and whether the viewer follows it.
 // @Tags         tags
 // @Accept       json
 // @Produce      json
 // @Param        tagID   path      int  true  "Tag ID"
-// @Success      200     {object}  store.Tag
+// @Success      200     {object}  TagsResponse
 // @Failure      400     {object}  ErrorResponse  "Invalid tag ID"
 // @Failure      404     {object}  ErrorResponse  "Tag not found"
 // @Failure      500     {object}  ErrorResponse  "Internal server error"
@@ -334,12 +498,23 @@ func (app *application) getTagHandler(c *fiber.Ctx) error {
 		}
 	}
 
+	viewer := getUserFromContext(c)
+	followers, following, err := app.store.TagFollowers.GetStats(c.Context(), tag.ID, viewer.ID)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
 	response := NewTagPostListResponse(
 		post,
 		tag,
 		fq.Limit,
 		fq.Offset,
 	)
+	response.FollowersCount = followers
+	response.IsFollowing = following
+	if err := app.markBookmarked(c.Context(), viewer, response.Posts.Posts); err != nil {
+		return app.internalServerError(c, err)
+	}
 
 	return app.jsonResponse(c, fiber.StatusOK, response)
 }
@@ -391,12 +566,13 @@ func (app *application) getTagTitleHandler(c *fiber.Ctx) error {
 // DeleteTag godoc
 //
 //	@Summary		Deletes a tag
-//	@Description	Delete a tag by ID
+//	@Description	Delete a tag by ID. Admin only.
 //	@Tags			tag
 //	@Accept			json
 //	@Produce		json
 //	@Param			id	path		int	true	"Tag ID"
 //	@Success		204	{object} string
+//	@Failure		403	{object}	error
 //	@Failure		404	{object}	error
 //	@Failure		500	{object}	error
 //	@Security		ApiKeyAuth
@@ -418,4 +594,4 @@ func (app *application) deleteTagHandler(c *fiber.Ctx) error {
 	}
 
 	return c.SendStatus(fiber.StatusNoContent)
-}
\ No newline at end of file
+}
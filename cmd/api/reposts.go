@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/pangdfg/gopher-social/internal/store"
+)
+
+// repostHandler godoc
+//
+//	@Summary		Reposts a post
+//	@Description	Reshares a post to the authenticated user's followers. Reposting a repost reshares its original.
+//	@Tags			posts
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Success		201		{object}	PostMini
+//	@Failure		400		{object}	error	"Post not published"
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error	"Already reposted"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/repost [post]
+func (app *application) repostHandler(c *fiber.Ctx) error {
+	post := c.Locals("post").(*store.Post)
+	if post.Status != store.PostStatusPublished {
+		return app.badRequestResponse(c, errors.New("unpublished posts can't be reposted"))
+	}
+
+	original := repostTarget(post)
+	user := getUserFromContext(c)
+	ctx := c.Context()
+
+	repost, err := app.store.Posts.Repost(ctx, user.ID, original)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrConflict):
+			return app.conflictResponse(c, errors.New("post already reposted"))
+		case errors.Is(err, store.ErrNotFound):
+			return app.notFoundResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	app.enqueueFanout(repost.ID)
+
+	created, err := app.store.Posts.GetByID(ctx, repost.ID)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return app.jsonResponse(c, fiber.StatusCreated, newPostMini(created))
+}
+
+// unrepostHandler godoc
+//
+//	@Summary		Undoes a repost
+//	@Description	Removes the authenticated user's repost of a post
+//	@Tags			posts
+//	@Param			postID	path		int		true	"Post ID"
+//	@Success		204		{string}	string	"Repost removed"
+//	@Failure		404		{object}	error	"Post not reposted"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/repost [delete]
+func (app *application) unrepostHandler(c *fiber.Ctx) error {
+	original := repostTarget(c.Locals("post").(*store.Post))
+	user := getUserFromContext(c)
+	ctx := c.Context()
+
+	if err := app.store.Posts.Unrepost(ctx, user.ID, original); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			return app.notFoundResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// getTimelineHandler godoc
+//
+//	@Summary		Fetches the personal timeline
+//	@Description	Posts, quotes and reposts by the users the authenticated user follows, and their own. Unfiltered newest first pages are served from Redis when it is enabled.
+//	@Tags			feed
+//	@Produce		json
+//	@Param			since	query		string	false	"Since"
+//	@Param			until	query		string	false	"Until"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Param			sort	query		string	false	"Sort"
+//	@Param			tags	query		string	false	"Tags"
+//	@Param			search	query		string	false	"Search"
+//	@Success		200		{object}	FeedResponse
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/feed/timeline [get]
+func (app *application) getTimelineHandler(c *fiber.Ctx) error {
+	fq := store.PaginatedFeedQuery{
+		Limit:  20,
+		Offset: 0,
+		Sort:   "desc",
+		Tags:   []string{},
+	}
+
+	fq, err := fq.Parse(c)
+	if err != nil {
+		return app.badRequestResponse(c, err)
+	}
+	if err := Validate.Struct(fq); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	user := getUserFromContext(c)
+	posts, err := app.homeTimeline(c.Context(), fq, user.ID)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	response := NewPostListResponse(posts, fq.Limit, fq.Offset)
+	if err := app.markBookmarked(c.Context(), user, response.Posts); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return app.jsonResponse(c, fiber.StatusOK, response)
+}
+
+// repostTarget is the post a repost or quote of p reshares: reposts stand
+// for their original.
+func repostTarget(p *store.Post) uint {
+	if p.Kind == store.PostKindRepost && p.RepostOfID != nil {
+		return *p.RepostOfID
+	}
+	return p.ID
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+
+	"github.com/gofiber/fiber/v2"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap"
+
+	"github.com/pangdfg/gopher-social/internal/store"
+)
+
+// repostedPosts records which posts are reposted, failing with err when
+// it is set.
+type repostedPosts struct {
+	store.Posts
+	err        error
+	reposted   []uint
+	unreposted []uint
+}
+
+func (f *repostedPosts) Repost(_ context.Context, userID, postID uint) (*store.Post, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.reposted = append(f.reposted, postID)
+	return &store.Post{ID: 100, UserID: userID, Kind: store.PostKindRepost, RepostOfID: &postID}, nil
+}
+
+func (f *repostedPosts) Unrepost(_ context.Context, _, postID uint) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.unreposted = append(f.unreposted, postID)
+	return nil
+}
+
+func (f *repostedPosts) GetByID(_ context.Context, id uint) (*store.Post, error) {
+	return &store.Post{ID: id, Kind: store.PostKindRepost, Status: store.PostStatusPublished}, nil
+}
+
+var _ = Describe("Reposts", func() {
+	var posts *repostedPosts
+
+	originalID := uint(1)
+	original := &store.Post{ID: originalID, Kind: store.PostKindPost, Status: store.PostStatusPublished}
+	repost := &store.Post{ID: 2, Kind: store.PostKindRepost, RepostOfID: &originalID, Status: store.PostStatusPublished}
+
+	BeforeEach(func() {
+		posts = &repostedPosts{}
+	})
+
+	serve := func(method string, post *store.Post) int {
+		app := &application{logger: zap.NewNop().Sugar(), store: store.Storage{Posts: posts}}
+		f := fiber.New()
+		f.Use(func(c *fiber.Ctx) error {
+			c.Locals("user", &store.User{ID: 7})
+			c.Locals("post", post)
+			return c.Next()
+		})
+		f.Post("/repost", app.repostHandler)
+		f.Delete("/repost", app.unrepostHandler)
+
+		resp, err := f.Test(httptest.NewRequest(method, "/repost", nil), -1)
+		Expect(err).NotTo(HaveOccurred())
+		return resp.StatusCode
+	}
+
+	It("reposts a post", func() {
+		Expect(serve(fiber.MethodPost, original)).To(Equal(fiber.StatusCreated))
+		Expect(posts.reposted).To(Equal([]uint{originalID}))
+	})
+
+	It("reshares the original when reposting a repost", func() {
+		Expect(serve(fiber.MethodPost, repost)).To(Equal(fiber.StatusCreated))
+		Expect(posts.reposted).To(Equal([]uint{originalID}))
+	})
+
+	It("won't repost a draft", func() {
+		draft := &store.Post{ID: 3, Kind: store.PostKindPost, Status: store.PostStatusDraft}
+		Expect(serve(fiber.MethodPost, draft)).To(Equal(fiber.StatusBadRequest))
+		Expect(posts.reposted).To(BeEmpty())
+	})
+
+	DescribeTable("maps store errors",
+		func(method string, err error, status int) {
+			posts.err = err
+			Expect(serve(method, repost)).To(Equal(status))
+		},
+		Entry("reposted twice", fiber.MethodPost, store.ErrConflict, fiber.StatusConflict),
+		Entry("original in the trash", fiber.MethodPost, store.ErrNotFound, fiber.StatusNotFound),
+		Entry("not reposted", fiber.MethodDelete, store.ErrNotFound, fiber.StatusNotFound),
+	)
+
+	It("undoes the repost of the original", func() {
+		Expect(serve(fiber.MethodDelete, repost)).To(Equal(fiber.StatusNoContent))
+		Expect(posts.unreposted).To(Equal([]uint{originalID}))
+	})
+})
+
+var _ = Describe("Quoted posts", func() {
+	It("shows a tombstone once the quoted post is gone", func() {
+		quote := &store.Post{ID: 5, Kind: store.PostKindQuote}
+		Expect(newPostMini(quote).QuoteOf).To(Equal(&QuotedPost{Unavailable: true}))
+	})
+
+	It("shows the quoted post", func() {
+		quote := &store.Post{ID: 5, Kind: store.PostKindQuote, QuoteOf: &store.Post{ID: 1, Title: "original"}}
+		quoted := newPostMini(quote).QuoteOf
+		Expect(quoted.Unavailable).To(BeFalse())
+		Expect(quoted.ID).To(Equal(uint(1)))
+		Expect(quoted.Title).To(Equal("original"))
+	})
+
+	It("leaves QuoteOf out of plain posts", func() {
+		Expect(newPostMini(&store.Post{ID: 5, Kind: store.PostKindPost}).QuoteOf).To(BeNil())
+	})
+})
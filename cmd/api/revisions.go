@@ -0,0 +1,227 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/pmezard/go-difflib/difflib"
+
+	"github.com/pangdfg/gopher-social/internal/store"
+)
+
+type RevisionMini struct {
+	Version   int       `json:"version"`
+	Title     string    `json:"title"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type RevisionsResponse struct {
+	Revisions []RevisionMini `json:"revisions"`
+	Limit     int            `json:"limit"`
+	Offset    int            `json:"offset"`
+}
+
+// RevisionResponse is an old revision of a post. Diff is a unified diff
+// from it to the current title and content.
+type RevisionResponse struct {
+	Version   int       `json:"version"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	Diff      string    `json:"diff"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// getRevisionsHandler godoc
+//
+//	@Summary		Lists post revisions
+//	@Description	Lists the earlier versions of a published post's title and content, newest first. Edits made before publishing keep no revisions.
+//	@Tags			posts
+//	@Produce		json
+//	@Param			postID	path		int		true	"Post ID"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Param			sort	query		string	false	"Sort by version (asc|desc)"
+//	@Success		200		{object}	RevisionsResponse
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/revisions [get]
+func (app *application) getRevisionsHandler(c *fiber.Ctx) error {
+	post := c.Locals("post").(*store.Post)
+
+	fq := store.PaginatedFeedQuery{
+		Limit:  20,
+		Offset: 0,
+		Sort:   "desc",
+		Tags:   []string{},
+	}
+
+	fq, err := fq.Parse(c)
+	if err != nil {
+		return app.badRequestResponse(c, err)
+	}
+	if err := Validate.Struct(fq); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	revisions, err := app.store.PostRevisions.GetByPostID(c.Context(), post.ID, fq)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	res := make([]RevisionMini, 0, len(revisions))
+	for _, r := range revisions {
+		res = append(res, RevisionMini{Version: r.Version, Title: r.Title, CreatedAt: r.CreatedAt})
+	}
+
+	return app.jsonResponse(c, fiber.StatusOK, RevisionsResponse{Revisions: res, Limit: fq.Limit, Offset: fq.Offset})
+}
+
+// getRevisionHandler godoc
+//
+//	@Summary		Fetches a post revision
+//	@Description	Fetches an earlier version of a post with a unified diff against the current text
+//	@Tags			posts
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Param			version	path		int	true	"Revision version"
+//	@Success		200		{object}	RevisionResponse
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/revisions/{version} [get]
+func (app *application) getRevisionHandler(c *fiber.Ctx) error {
+	post := c.Locals("post").(*store.Post)
+	ctx := c.Context()
+
+	version, err := revisionVersion(c)
+	if err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	revision, err := app.store.PostRevisions.Get(ctx, post.ID, version)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			return app.notFoundResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	diff, err := revisionDiff(revision, post)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return app.jsonResponse(c, fiber.StatusOK, RevisionResponse{
+		Version:   revision.Version,
+		Title:     revision.Title,
+		Content:   revision.Content,
+		Diff:      diff,
+		CreatedAt: revision.CreatedAt,
+	})
+}
+
+// restoreRevisionHandler godoc
+//
+//	@Summary		Restores a post revision
+//	@Description	Brings back an earlier title and content as a new edit; the replaced text becomes a revision in turn
+//	@Tags			posts
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Param			version	path		int	true	"Revision version"
+//	@Success		200		{object}	PostResponse
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/revisions/{version}/restore [post]
+func (app *application) restoreRevisionHandler(c *fiber.Ctx) error {
+	post := c.Locals("post").(*store.Post)
+	ctx := c.Context()
+
+	version, err := revisionVersion(c)
+	if err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	revision, err := app.store.PostRevisions.Get(ctx, post.ID, version)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			return app.notFoundResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	contentChanged := revision.Content != post.Content
+	explicit := explicitTags(post)
+	post.Title, post.Content = revision.Title, revision.Content
+
+	tags, err := app.resolveTags(ctx, explicit, post.Content)
+	if err != nil {
+		var invalid errInvalidTag
+		switch {
+		case errors.As(err, &invalid):
+			return app.badRequestResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	if err := app.store.Posts.Update(ctx, post); err != nil {
+		switch {
+		case errors.Is(err, store.ErrConflict):
+			return app.conflictResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	if err := app.store.Posts.SetTags(ctx, post.ID, tags); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	if contentChanged && post.Status == store.PostStatusPublished {
+		if _, err := app.syncMentions(ctx, &post.User, mentionSource{postID: post.ID, text: post.Content}); err != nil {
+			return app.internalServerError(c, err)
+		}
+	}
+
+	restored, err := app.store.Posts.GetByID(ctx, post.ID)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return app.jsonResponse(c, fiber.StatusOK, NewPostResponse(restored))
+}
+
+func revisionVersion(c *fiber.Ctx) (int, error) {
+	version, err := strconv.Atoi(c.Params("version"))
+	if err != nil || version < 0 {
+		return 0, errors.New("invalid revision version")
+	}
+	return version, nil
+}
+
+// revisionDiff is a unified diff from a revision to the post's current
+// text, the title being the first line of each. SplitLines ends the last
+// line itself.
+func revisionDiff(revision *store.PostRevision, post *store.Post) (string, error) {
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(revision.Title + "\n\n" + revision.Content),
+		B:        difflib.SplitLines(post.Title + "\n\n" + post.Content),
+		FromFile: fmt.Sprintf("version %d", revision.Version),
+		ToFile:   fmt.Sprintf("version %d", post.Version),
+		Context:  3,
+	})
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+
+	"github.com/gofiber/fiber/v2"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap"
+
+	"github.com/pangdfg/gopher-social/internal/store"
+)
+
+// keptRevisions has the revisions of a single post.
+type keptRevisions struct {
+	store.PostRevisions
+	byVersion map[int]store.PostRevision
+}
+
+func (f *keptRevisions) Get(_ context.Context, _ uint, version int) (*store.PostRevision, error) {
+	revision, ok := f.byVersion[version]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return &revision, nil
+}
+
+var _ = Describe("Revisions", func() {
+	first := store.PostRevision{PostID: 1, Version: 1, Title: "Hello", Content: "first line\nsecond line"}
+
+	It("diffs a revision against the current text", func() {
+		post := &store.Post{ID: 1, Version: 3, Title: "Hello", Content: "first line\nsecond line, edited"}
+
+		diff, err := revisionDiff(&first, post)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(diff).To(Equal("--- version 1\n" +
+			"+++ version 3\n" +
+			"@@ -1,4 +1,4 @@\n" +
+			" Hello\n" +
+			" \n" +
+			" first line\n" +
+			"-second line\n" +
+			"+second line, edited\n"))
+	})
+
+	It("has no diff for an unchanged text", func() {
+		post := &store.Post{ID: 1, Version: 2, Title: first.Title, Content: first.Content}
+
+		diff, err := revisionDiff(&first, post)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(diff).To(BeEmpty())
+	})
+
+	Describe("handlers", func() {
+		var (
+			posts *editedPost
+			post  store.Post
+		)
+
+		BeforeEach(func() {
+			post = store.Post{
+				ID:      1,
+				UserID:  7,
+				Title:   "Hello",
+				Content: "current",
+				Kind:    store.PostKindPost,
+				Status:  store.PostStatusPublished,
+				Version: 2,
+			}
+			posts = &editedPost{post: post}
+		})
+
+		serve := func(method, target string) (int, map[string]any) {
+			app := &application{
+				logger: zap.NewNop().Sugar(),
+				store: store.Storage{
+					Posts:         posts,
+					PostRevisions: &keptRevisions{byVersion: map[int]store.PostRevision{1: first}},
+					Mentions:      &postMentions{byPost: map[uint][]uint{}},
+				},
+			}
+			f := fiber.New()
+			f.Use(func(c *fiber.Ctx) error {
+				current := post
+				c.Locals("user", &store.User{ID: 7})
+				c.Locals("post", &current)
+				return c.Next()
+			})
+			f.Get("/revisions/:version", app.getRevisionHandler)
+			f.Post("/revisions/:version/restore", app.restoreRevisionHandler)
+
+			resp, err := f.Test(httptest.NewRequest(method, target, nil), -1)
+			Expect(err).NotTo(HaveOccurred())
+
+			var body map[string]any
+			Expect(json.NewDecoder(resp.Body).Decode(&body)).To(Succeed())
+			return resp.StatusCode, body
+		}
+
+		It("fetches a revision by version", func() {
+			status, body := serve(fiber.MethodGet, "/revisions/1")
+			Expect(status).To(Equal(fiber.StatusOK))
+			Expect(body["data"]).To(HaveKeyWithValue("version", BeNumerically("==", 1)))
+			Expect(body["data"]).To(HaveKeyWithValue("content", first.Content))
+		})
+
+		DescribeTable("refuses versions it can't look up",
+			func(target string, want int) {
+				status, _ := serve(fiber.MethodGet, target)
+				Expect(status).To(Equal(want))
+			},
+			Entry("not a number", "/revisions/latest", fiber.StatusBadRequest),
+			Entry("negative", "/revisions/-1", fiber.StatusBadRequest),
+			Entry("never kept", "/revisions/2", fiber.StatusNotFound),
+		)
+
+		It("restores a revision as a new version", func() {
+			status, _ := serve(fiber.MethodPost, "/revisions/1/restore")
+			Expect(status).To(Equal(fiber.StatusOK))
+			Expect(posts.post.Content).To(Equal(first.Content))
+			Expect(posts.post.Version).To(Equal(3))
+		})
+
+		It("conflicts when the post changed since it was loaded", func() {
+			posts.post.Version = 3
+
+			status, _ := serve(fiber.MethodPost, "/revisions/1/restore")
+			Expect(status).To(Equal(fiber.StatusConflict))
+			Expect(posts.post.Content).To(Equal("current"))
+		})
+	})
+})
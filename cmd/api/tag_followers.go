@@ -0,0 +1,165 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/pangdfg/gopher-social/internal/store"
+)
+
+// followTagHandler godoc
+//
+//	@Summary		Follows a tag
+//	@Description	Follows a tag by ID; its posts show up in the followed tags feed
+//	@Tags			tags
+//	@Produce		json
+//	@Param			tagID	path		int		true	"Tag ID"
+//	@Success		204		{string}	string	"Tag followed"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error	"Tag not found"
+//	@Failure		409		{object}	error	"Already following"
+//	@Security		ApiKeyAuth
+//	@Router			/tags/{tagID}/follow [put]
+func (app *application) followTagHandler(c *fiber.Ctx) error {
+	user := getUserFromContext(c)
+
+	tagID, err := strconv.ParseInt(c.Params("tagID"), 10, 64)
+	if err != nil || tagID < 1 {
+		return app.badRequestResponse(c, errors.New("invalid tag ID"))
+	}
+
+	if err := app.store.TagFollowers.Follow(c.Context(), user.ID, uint(tagID)); err != nil {
+		switch {
+		case errors.Is(err, store.ErrConflict):
+			return app.conflictResponse(c, err)
+		case errors.Is(err, store.ErrNotFound):
+			return app.notFoundResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// unfollowTagHandler godoc
+//
+//	@Summary		Unfollows a tag
+//	@Description	Unfollows a tag by ID
+//	@Tags			tags
+//	@Produce		json
+//	@Param			tagID	path		int		true	"Tag ID"
+//	@Success		204		{string}	string	"Tag unfollowed"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error	"Not following the tag"
+//	@Security		ApiKeyAuth
+//	@Router			/tags/{tagID}/unfollow [put]
+func (app *application) unfollowTagHandler(c *fiber.Ctx) error {
+	user := getUserFromContext(c)
+
+	tagID, err := strconv.ParseInt(c.Params("tagID"), 10, 64)
+	if err != nil || tagID < 1 {
+		return app.badRequestResponse(c, errors.New("invalid tag ID"))
+	}
+
+	if err := app.store.TagFollowers.Unfollow(c.Context(), user.ID, uint(tagID)); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			return app.notFoundResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// getFollowedTagsHandler godoc
+//
+//	@Summary		Lists followed tags
+//	@Description	Lists the tags the authenticated user follows, most recently followed first
+//	@Tags			tags
+//	@Produce		json
+//	@Param			search	query		string	false	"Search by tag title"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Param			sort	query		string	false	"Sort (asc|desc)"
+//	@Success		200		{object}	TagsListResponse
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/tags/following [get]
+func (app *application) getFollowedTagsHandler(c *fiber.Ctx) error {
+	fq := store.PaginatedFeedQuery{
+		Limit:  20,
+		Offset: 0,
+		Sort:   "desc",
+		Tags:   []string{},
+	}
+
+	fq, err := fq.Parse(c)
+	if err != nil {
+		return app.badRequestResponse(c, err)
+	}
+	if err := Validate.Struct(fq); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	user := getUserFromContext(c)
+	tags, err := app.store.TagFollowers.GetFollowedTags(c.Context(), user.ID, fq)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return app.jsonResponse(c, fiber.StatusOK, NewTagsListResponse(tags, fq.Limit, fq.Offset))
+}
+
+// getFollowedTagsFeedHandler godoc
+//
+//	@Summary		Fetches the followed tags feed
+//	@Description	Posts carrying any tag the authenticated user follows, each listed once
+//	@Tags			feed
+//	@Produce		json
+//	@Param			since	query		string	false	"Since"
+//	@Param			until	query		string	false	"Until"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Param			sort	query		string	false	"Sort"
+//	@Param			tags	query		string	false	"Only these of the followed tags"
+//	@Param			search	query		string	false	"Search"
+//	@Success		200		{object}	FeedResponse
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/tags/following/feed [get]
+func (app *application) getFollowedTagsFeedHandler(c *fiber.Ctx) error {
+	fq := store.PaginatedFeedQuery{
+		Limit:  20,
+		Offset: 0,
+		Sort:   "desc",
+		Tags:   []string{},
+	}
+
+	fq, err := fq.Parse(c)
+	if err != nil {
+		return app.badRequestResponse(c, err)
+	}
+	if err := Validate.Struct(fq); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	user := getUserFromContext(c)
+	feed, err := app.store.Posts.GetFollowedTagsFeed(c.Context(), fq, user.ID)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	response := NewPostListResponse(feed, fq.Limit, fq.Offset)
+	if err := app.markBookmarked(c.Context(), user, response.Posts); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return app.jsonResponse(c, fiber.StatusOK, response)
+}
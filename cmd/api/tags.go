@@ -0,0 +1,239 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/pangdfg/gopher-social/internal/richtext"
+	"github.com/pangdfg/gopher-social/internal/store"
+)
+
+type UpdateTagPayload struct {
+	Title       *string `json:"title" validate:"omitempty,max=255"`
+	Description *string `json:"description" validate:"omitempty,max=1000"`
+}
+
+type MergeTagPayload struct {
+	TargetID uint `json:"target_id" validate:"required"`
+}
+
+type CreateTagAliasPayload struct {
+	Name string `json:"name" validate:"required,max=255"`
+}
+
+// updateTagHandler godoc
+//
+//	@Summary		Updates a tag
+//	@Description	Renames a tag or sets its description. The old name becomes an alias. Admin only.
+//	@Tags			tags
+//	@Accept			json
+//	@Produce		json
+//	@Param			tagID	path		int					true	"Tag ID"
+//	@Param			payload	body		UpdateTagPayload	true	"Tag payload"
+//	@Success		200		{object}	TagDetail
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error	"Name taken by another tag or alias"
+//	@Security		ApiKeyAuth
+//	@Router			/tags/{tagID} [patch]
+func (app *application) updateTagHandler(c *fiber.Ctx) error {
+	id, err := parseTagID(c, "tagID")
+	if err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	var payload UpdateTagPayload
+	if err := readJSON(c, &payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+	if err := Validate.Struct(payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	ctx := c.Context()
+	tag, err := app.store.Tags.GetByID(ctx, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			return app.notFoundResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	if payload.Title != nil {
+		name, ok := richtext.NormalizeTag(*payload.Title)
+		if !ok {
+			return app.badRequestResponse(c, errInvalidTag(*payload.Title))
+		}
+		tag.Title = richtext.DisplayTag(*payload.Title)
+		tag.Name = name
+	}
+	if payload.Description != nil {
+		tag.Description = *payload.Description
+	}
+
+	if err := app.store.Tags.Update(ctx, tag); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			return app.notFoundResponse(c, err)
+		case errors.Is(err, store.ErrConflict):
+			return app.conflictResponse(c, errors.New("name is already used by another tag or alias"))
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	updated, err := app.store.Tags.GetByID(ctx, id)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return app.jsonResponse(c, fiber.StatusOK, newTagDetail(updated))
+}
+
+// mergeTagHandler godoc
+//
+//	@Summary		Merges a tag into another
+//	@Description	Moves the posts, followers and aliases of a tag onto the target and deletes it. Its name becomes an alias of the target. Admin only.
+//	@Tags			tags
+//	@Accept			json
+//	@Produce		json
+//	@Param			tagID	path		int				true	"ID of the tag to merge away"
+//	@Param			payload	body		MergeTagPayload	true	"Target tag"
+//	@Success		200		{object}	TagDetail
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/tags/{tagID}/merge [post]
+func (app *application) mergeTagHandler(c *fiber.Ctx) error {
+	id, err := parseTagID(c, "tagID")
+	if err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	var payload MergeTagPayload
+	if err := readJSON(c, &payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+	if err := Validate.Struct(payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+	if payload.TargetID == id {
+		return app.badRequestResponse(c, errors.New("a tag can't be merged into itself"))
+	}
+
+	ctx := c.Context()
+	if err := app.store.Tags.Merge(ctx, id, payload.TargetID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			return app.notFoundResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	target, err := app.store.Tags.GetByID(ctx, payload.TargetID)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return app.jsonResponse(c, fiber.StatusOK, newTagDetail(target))
+}
+
+// createTagAliasHandler godoc
+//
+//	@Summary		Adds a tag alias
+//	@Description	Adds another name for a tag. Posts written with the alias get the tag. Admin only.
+//	@Tags			tags
+//	@Accept			json
+//	@Produce		json
+//	@Param			tagID	path		int						true	"Tag ID"
+//	@Param			payload	body		CreateTagAliasPayload	true	"Alias"
+//	@Success		201		{object}	TagAliasMini
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error	"Name taken by a tag or alias"
+//	@Security		ApiKeyAuth
+//	@Router			/tags/{tagID}/aliases [post]
+func (app *application) createTagAliasHandler(c *fiber.Ctx) error {
+	id, err := parseTagID(c, "tagID")
+	if err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	var payload CreateTagAliasPayload
+	if err := readJSON(c, &payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+	if err := Validate.Struct(payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	name, ok := richtext.NormalizeTag(payload.Name)
+	if !ok {
+		return app.badRequestResponse(c, errInvalidTag(payload.Name))
+	}
+
+	alias := &store.TagAlias{TagID: id, Name: name}
+	if err := app.store.Tags.AddAlias(c.Context(), alias); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			return app.notFoundResponse(c, err)
+		case errors.Is(err, store.ErrConflict):
+			return app.conflictResponse(c, errors.New("name is already used by a tag or alias"))
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	return app.jsonResponse(c, fiber.StatusCreated, TagAliasMini{ID: alias.ID, Name: alias.Name})
+}
+
+// deleteTagAliasHandler godoc
+//
+//	@Summary		Removes a tag alias
+//	@Description	Removes an alias; posts already tagged keep the tag. Admin only.
+//	@Tags			tags
+//	@Param			tagID	path	int	true	"Tag ID"
+//	@Param			aliasID	path	int	true	"Alias ID"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/tags/{tagID}/aliases/{aliasID} [delete]
+func (app *application) deleteTagAliasHandler(c *fiber.Ctx) error {
+	id, err := parseTagID(c, "tagID")
+	if err != nil {
+		return app.badRequestResponse(c, err)
+	}
+	aliasID, err := parseTagID(c, "aliasID")
+	if err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	if err := app.store.Tags.DeleteAlias(c.Context(), id, aliasID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			return app.notFoundResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func parseTagID(c *fiber.Ctx, param string) (uint, error) {
+	id, err := strconv.ParseUint(c.Params(param), 10, 64)
+	if err != nil || id == 0 {
+		return 0, errors.New("invalid " + param)
+	}
+	return uint(id), nil
+}
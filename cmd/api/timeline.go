@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"github.com/pangdfg/gopher-social/internal/store"
+)
+
+const (
+	timelineQueueSize   = 1024
+	timelineFanoutBatch = 1000
+	// timelineRefills bounds how often a page with posts gone is filled up
+	timelineRefills = 3
+)
+
+// enqueueFanout hands a post that just went public to the timeline
+// workers. A post that misses a full queue shows up once the timelines
+// are rebuilt.
+func (app *application) enqueueFanout(postID uint) {
+	if app.cacheStorage.Timelines == nil {
+		return
+	}
+
+	select {
+	case app.timelineQueue <- postID:
+	default:
+		app.logger.Warnw("timeline queue full", "postID", postID)
+	}
+}
+
+func (app *application) timelineWorker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case id := <-app.timelineQueue:
+			if err := app.fanoutPost(ctx, id); err != nil {
+				app.logger.Errorw("timeline fan-out failed", "postID", id, "error", err.Error())
+			}
+		}
+	}
+}
+
+// fanoutPost pushes a post onto its author's timeline and their
+// followers'. Posts by popular users only go onto the author's; their
+// followers' timelines merge them in when read.
+func (app *application) fanoutPost(ctx context.Context, postID uint) error {
+	post, err := app.store.Posts.GetByID(ctx, postID)
+	if errors.Is(err, store.ErrNotFound) {
+		// deleted before it got here
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if post.Status != store.PostStatusPublished {
+		return nil
+	}
+
+	timelines := app.cacheStorage.Timelines
+	entry := store.TimelineEntry{ID: post.ID, CreatedAt: post.CreatedAt}
+	if err := timelines.Push(ctx, []uint{post.UserID}, entry); err != nil {
+		return err
+	}
+
+	followers, err := app.store.Followers.CountFollowers(ctx, post.UserID)
+	if err != nil {
+		return err
+	}
+	if followers >= app.config.timeline.fanoutMaxFollowers {
+		return nil
+	}
+
+	var after uint
+	for {
+		ids, err := app.store.Followers.GetFollowerIDs(ctx, post.UserID, after, timelineFanoutBatch)
+		if err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		if err := timelines.Push(ctx, ids, entry); err != nil {
+			return err
+		}
+		after = ids[len(ids)-1]
+	}
+}
+
+// homeTimeline is the timeline GetTimeline returns, served from Redis
+// when the query is a plain newest first page within the cached length.
+// Redis failing falls back to Postgres.
+func (app *application) homeTimeline(ctx context.Context, fq store.PaginatedFeedQuery, userID uint) ([]store.Post, error) {
+	if app.cacheStorage.Timelines == nil || !cachedTimelineQuery(fq, app.config.timeline.size) {
+		return app.store.Posts.GetTimeline(ctx, fq, userID)
+	}
+
+	posts, err := app.cachedTimeline(ctx, fq, userID)
+	if err != nil {
+		app.logger.Warnw("cached timeline failed", "userID", userID, "error", err.Error())
+		return app.store.Posts.GetTimeline(ctx, fq, userID)
+	}
+	return posts, nil
+}
+
+// cachedTimelineQuery reports whether the cached timelines can answer fq;
+// filters and other orders go to Postgres.
+func cachedTimelineQuery(fq store.PaginatedFeedQuery, size int) bool {
+	return fq.Search == "" && len(fq.Tags) == 0 && fq.Since == "" && fq.Until == "" &&
+		fq.Sort == "desc" && fq.Offset+fq.Limit <= size
+}
+
+// cachedTimeline pages through the cached entries. Entries whose post is
+// gone are dropped from the timeline and the page is filled up again from
+// the entries after it, a few times at most, so it only comes back short
+// when the timeline runs out.
+func (app *application) cachedTimeline(ctx context.Context, fq store.PaginatedFeedQuery, userID uint) ([]store.Post, error) {
+	popular, err := app.store.Followers.GetPopularFollowed(ctx, userID, app.config.timeline.fanoutMaxFollowers)
+	if err != nil {
+		return nil, err
+	}
+
+	dead := map[uint]bool{}
+	for attempt := 0; ; attempt++ {
+		// dead entries the cache couldn't drop still come back from it
+		entries, err := app.timelineEntries(ctx, userID, popular, fq.Offset+fq.Limit+len(dead))
+		if err != nil {
+			return nil, err
+		}
+
+		live := make([]store.TimelineEntry, 0, len(entries))
+		for _, e := range entries {
+			if !dead[e.ID] {
+				live = append(live, e)
+			}
+		}
+
+		page := live[min(fq.Offset, len(live)):min(fq.Offset+fq.Limit, len(live))]
+		posts, gone, err := app.timelinePosts(ctx, page)
+		if err != nil {
+			return nil, err
+		}
+		if len(gone) == 0 || attempt == timelineRefills {
+			return posts, nil
+		}
+
+		for _, id := range gone {
+			dead[id] = true
+		}
+		if err := app.cacheStorage.Timelines.Remove(ctx, userID, gone); err != nil {
+			app.logger.Warnw("timeline cleanup failed", "userID", userID, "error", err.Error())
+		}
+	}
+}
+
+// timelineEntries returns the newest n entries of the user's timeline,
+// rebuilding it if it isn't cached, merged with the posts of the popular
+// accounts they follow, which are never pushed.
+func (app *application) timelineEntries(ctx context.Context, userID uint, popular []uint, n int) ([]store.TimelineEntry, error) {
+	timelines := app.cacheStorage.Timelines
+
+	entries, ok, err := timelines.Range(ctx, userID, n)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		entries, err = app.store.Posts.GetTimelineEntries(ctx, userID, app.config.timeline.size)
+		if err != nil {
+			return nil, err
+		}
+		if err := timelines.Replace(ctx, userID, entries); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(popular) > 0 {
+		pulled, err := app.store.Posts.GetEntriesByUsers(ctx, popular, n)
+		if err != nil {
+			return nil, err
+		}
+		entries = mergeTimelineEntries(entries, pulled)
+	}
+	return entries, nil
+}
+
+// timelinePosts loads the posts of page in its order and returns the IDs
+// of the ones that are gone: deleted, unpublished or their original
+// trashed since they were pushed.
+func (app *application) timelinePosts(ctx context.Context, page []store.TimelineEntry) ([]store.Post, []uint, error) {
+	ids := make([]uint, 0, len(page))
+	for _, e := range page {
+		ids = append(ids, e.ID)
+	}
+
+	found, err := app.store.Posts.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, nil, err
+	}
+	byID := make(map[uint]*store.Post, len(found))
+	for i := range found {
+		byID[found[i].ID] = &found[i]
+	}
+
+	posts := make([]store.Post, 0, len(ids))
+	var gone []uint
+	for _, id := range ids {
+		p, ok := byID[id]
+		if !ok {
+			gone = append(gone, id)
+			continue
+		}
+		posts = append(posts, *p)
+	}
+	return posts, gone, nil
+}
+
+// mergeTimelineEntries merges two newest first lists of entries into one,
+// dropping duplicates.
+func mergeTimelineEntries(a, b []store.TimelineEntry) []store.TimelineEntry {
+	out := make([]store.TimelineEntry, 0, len(a)+len(b))
+	seen := make(map[uint]bool, len(a)+len(b))
+
+	for len(a) > 0 || len(b) > 0 {
+		var next store.TimelineEntry
+		if len(b) == 0 || (len(a) > 0 && newerEntry(a[0], b[0])) {
+			next, a = a[0], a[1:]
+		} else {
+			next, b = b[0], b[1:]
+		}
+
+		if !seen[next.ID] {
+			seen[next.ID] = true
+			out = append(out, next)
+		}
+	}
+	return out
+}
+
+func newerEntry(a, b store.TimelineEntry) bool {
+	if a.CreatedAt.Equal(b.CreatedAt) {
+		return a.ID > b.ID
+	}
+	return a.CreatedAt.After(b.CreatedAt)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap"
+
+	"github.com/pangdfg/gopher-social/internal/store"
+	"github.com/pangdfg/gopher-social/internal/store/cache"
+)
+
+// cachedTimelines holds one cached timeline, newest first.
+type cachedTimelines struct {
+	cache.Timelines
+	entries []store.TimelineEntry
+}
+
+func (f *cachedTimelines) Range(_ context.Context, _ uint, limit int) ([]store.TimelineEntry, bool, error) {
+	return f.entries[:min(limit, len(f.entries))], true, nil
+}
+
+func (f *cachedTimelines) Remove(_ context.Context, _ uint, postIDs []uint) error {
+	for _, id := range postIDs {
+		for i, e := range f.entries {
+			if e.ID == id {
+				f.entries = append(f.entries[:i:i], f.entries[i+1:]...)
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// timelinePostStore returns the posts that aren't gone.
+type timelinePostStore struct {
+	store.Posts
+	gone map[uint]bool
+}
+
+func (f *timelinePostStore) GetByIDs(_ context.Context, ids []uint) ([]store.Post, error) {
+	var posts []store.Post
+	for _, id := range ids {
+		if !f.gone[id] {
+			posts = append(posts, store.Post{ID: id})
+		}
+	}
+	return posts, nil
+}
+
+type noPopularFollowed struct{ store.Followers }
+
+func (noPopularFollowed) GetPopularFollowed(_ context.Context, _ uint, _ int64) ([]uint, error) {
+	return nil, nil
+}
+
+var _ = Describe("Timelines", func() {
+	at := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	entry := func(id uint, minutesAgo int) store.TimelineEntry {
+		return store.TimelineEntry{ID: id, CreatedAt: at.Add(-time.Duration(minutesAgo) * time.Minute)}
+	}
+	ids := func(entries []store.TimelineEntry) []uint {
+		out := make([]uint, 0, len(entries))
+		for _, e := range entries {
+			out = append(out, e.ID)
+		}
+		return out
+	}
+
+	It("merges pulled posts into the pushed ones newest first", func() {
+		pushed := []store.TimelineEntry{entry(9, 1), entry(7, 5), entry(3, 30)}
+		pulled := []store.TimelineEntry{entry(8, 2), entry(4, 20)}
+
+		Expect(ids(mergeTimelineEntries(pushed, pulled))).To(Equal([]uint{9, 8, 7, 4, 3}))
+	})
+
+	It("keeps one of a post found in both", func() {
+		pushed := []store.TimelineEntry{entry(9, 1), entry(5, 10)}
+		pulled := []store.TimelineEntry{entry(5, 10), entry(2, 40)}
+
+		Expect(ids(mergeTimelineEntries(pushed, pulled))).To(Equal([]uint{9, 5, 2}))
+	})
+
+	It("orders posts from the same moment by ID", func() {
+		pushed := []store.TimelineEntry{entry(6, 0)}
+		pulled := []store.TimelineEntry{entry(7, 0)}
+
+		Expect(ids(mergeTimelineEntries(pushed, pulled))).To(Equal([]uint{7, 6}))
+	})
+
+	DescribeTable("only serve plain newest first pages from the cache",
+		func(fq store.PaginatedFeedQuery, cached bool) {
+			Expect(cachedTimelineQuery(fq, 100)).To(Equal(cached))
+		},
+		Entry("first page", store.PaginatedFeedQuery{Limit: 20, Sort: "desc"}, true),
+		Entry("last cached page", store.PaginatedFeedQuery{Limit: 20, Offset: 80, Sort: "desc"}, true),
+		Entry("past the cached length", store.PaginatedFeedQuery{Limit: 20, Offset: 81, Sort: "desc"}, false),
+		Entry("oldest first", store.PaginatedFeedQuery{Limit: 20, Sort: "asc"}, false),
+		Entry("searching", store.PaginatedFeedQuery{Limit: 20, Sort: "desc", Search: "go"}, false),
+		Entry("by tag", store.PaginatedFeedQuery{Limit: 20, Sort: "desc", Tags: []string{"go"}}, false),
+		Entry("since a date", store.PaginatedFeedQuery{Limit: 20, Sort: "desc", Since: "2026-10-01"}, false),
+	)
+
+	Describe("cachedTimeline", func() {
+		var (
+			app       *application
+			timelines *cachedTimelines
+			posts     *timelinePostStore
+		)
+
+		BeforeEach(func() {
+			timelines = &cachedTimelines{}
+			for i := 0; i < 10; i++ {
+				timelines.entries = append(timelines.entries, entry(uint(10-i), i))
+			}
+			posts = &timelinePostStore{gone: map[uint]bool{}}
+			app = &application{
+				config:       config{timeline: timelineConfig{size: 100}},
+				logger:       zap.NewNop().Sugar(),
+				store:        store.Storage{Posts: posts, Followers: noPopularFollowed{}},
+				cacheStorage: cache.Storage{Timelines: timelines},
+			}
+		})
+
+		postIDs := func(posts []store.Post) []uint {
+			out := make([]uint, 0, len(posts))
+			for _, p := range posts {
+				out = append(out, p.ID)
+			}
+			return out
+		}
+
+		It("fills a page back up after dropping posts that are gone", func() {
+			posts.gone[9] = true
+			posts.gone[7] = true
+
+			page, err := app.cachedTimeline(context.Background(), store.PaginatedFeedQuery{Limit: 3, Offset: 1, Sort: "desc"}, 1)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(postIDs(page)).To(Equal([]uint{8, 6, 5}))
+			Expect(ids(timelines.entries)).NotTo(ContainElements(uint(9), uint(7)))
+		})
+
+		It("comes back short only when the timeline runs out", func() {
+			for id := uint(1); id <= 8; id++ {
+				posts.gone[id] = true
+			}
+
+			page, err := app.cachedTimeline(context.Background(), store.PaginatedFeedQuery{Limit: 5, Sort: "desc"}, 1)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(postIDs(page)).To(Equal([]uint{10, 9}))
+		})
+	})
+})
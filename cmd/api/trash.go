@@ -0,0 +1,336 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/pangdfg/gopher-social/internal/store"
+)
+
+const trashPurgeBatch = 500
+
+type TrashedPost struct {
+	ID          uint      `json:"id"`
+	Title       string    `json:"title"`
+	Content     string    `json:"content"`
+	Kind        string    `json:"kind"`
+	Author      UserMini  `json:"author"`
+	DeletedByID *uint     `json:"deleted_by_id"`
+	DeletedAt   time.Time `json:"deleted_at"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type TrashedComment struct {
+	ID          uint      `json:"id"`
+	PostID      uint      `json:"post_id"`
+	Content     string    `json:"content"`
+	Author      UserMini  `json:"author"`
+	DeletedByID *uint     `json:"deleted_by_id"`
+	DeletedAt   time.Time `json:"deleted_at"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type TrashedPostsResponse struct {
+	Posts  []TrashedPost `json:"posts"`
+	Limit  int           `json:"limit"`
+	Offset int           `json:"offset"`
+}
+
+type TrashedCommentsResponse struct {
+	Comments []TrashedComment `json:"comments"`
+	Limit    int              `json:"limit"`
+	Offset   int              `json:"offset"`
+}
+
+// getTrashedPostsHandler godoc
+//
+//	@Summary		Lists trashed posts
+//	@Description	Lists the posts the authenticated user deleted, most recently deleted first. Admins can pass all=true to list every trashed post.
+//	@Tags			trash
+//	@Produce		json
+//	@Param			all		query		bool	false	"Every trashed post (admins only)"
+//	@Param			search	query		string	false	"Search"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Param			sort	query		string	false	"Sort by deletion time (asc|desc)"
+//	@Success		200		{object}	TrashedPostsResponse
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/trash/posts [get]
+func (app *application) getTrashedPostsHandler(c *fiber.Ctx) error {
+	fq, err := parseTrashQuery(c)
+	if err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	userID, ok, err := app.trashOwner(c)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+	if !ok {
+		return app.forbiddenResponse(c)
+	}
+
+	posts, err := app.store.Posts.GetTrash(c.Context(), fq, userID)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	res := make([]TrashedPost, 0, len(posts))
+	for i := range posts {
+		p := &posts[i]
+		res = append(res, TrashedPost{
+			ID:          p.ID,
+			Title:       p.Title,
+			Content:     p.Content,
+			Kind:        p.Kind,
+			Author:      newUserMini(&p.User),
+			DeletedByID: p.DeletedByID,
+			DeletedAt:   p.DeletedAt.Time,
+			CreatedAt:   p.CreatedAt,
+		})
+	}
+
+	return app.jsonResponse(c, fiber.StatusOK, TrashedPostsResponse{Posts: res, Limit: fq.Limit, Offset: fq.Offset})
+}
+
+// getTrashedCommentsHandler godoc
+//
+//	@Summary		Lists trashed comments
+//	@Description	Lists the comments the authenticated user deleted, most recently deleted first. Admins can pass all=true to list every trashed comment.
+//	@Tags			trash
+//	@Produce		json
+//	@Param			all		query		bool	false	"Every trashed comment (admins only)"
+//	@Param			search	query		string	false	"Search"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Param			sort	query		string	false	"Sort by deletion time (asc|desc)"
+//	@Success		200		{object}	TrashedCommentsResponse
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/trash/comments [get]
+func (app *application) getTrashedCommentsHandler(c *fiber.Ctx) error {
+	fq, err := parseTrashQuery(c)
+	if err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	userID, ok, err := app.trashOwner(c)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+	if !ok {
+		return app.forbiddenResponse(c)
+	}
+
+	comments, err := app.store.Comments.GetTrash(c.Context(), fq, userID)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	res := make([]TrashedComment, 0, len(comments))
+	for i := range comments {
+		cm := &comments[i]
+		res = append(res, TrashedComment{
+			ID:          cm.ID,
+			PostID:      cm.PostID,
+			Content:     cm.Content,
+			Author:      newUserMini(&cm.User),
+			DeletedByID: cm.DeletedByID,
+			DeletedAt:   cm.DeletedAt.Time,
+			CreatedAt:   cm.CreatedAt,
+		})
+	}
+
+	return app.jsonResponse(c, fiber.StatusOK, TrashedCommentsResponse{Comments: res, Limit: fq.Limit, Offset: fq.Offset})
+}
+
+// restorePostHandler godoc
+//
+//	@Summary		Restores a trashed post
+//	@Description	Takes a post out of the trash. Authors can restore posts they deleted themselves, admins any.
+//	@Tags			trash
+//	@Param			postID	path		int		true	"Post ID"
+//	@Success		204		{string}	string	"Post restored"
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error	"Post reposted again since"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/trash/posts/{postID}/restore [post]
+func (app *application) restorePostHandler(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("postID"), 10, 64)
+	if err != nil {
+		return app.badRequestResponse(c, errors.New("invalid post ID"))
+	}
+	ctx := c.Context()
+
+	post, err := app.store.Posts.GetDeleted(ctx, uint(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			return app.notFoundResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	allowed, err := app.canRestore(c, post.UserID, post.DeletedByID)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+	if !allowed {
+		return app.forbiddenResponse(c)
+	}
+
+	if err := app.store.Posts.Restore(ctx, post.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			return app.notFoundResponse(c, err)
+		case errors.Is(err, store.ErrConflict):
+			return app.conflictResponse(c, errors.New("the post was reposted again since"))
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+	// timelines rebuilt while it was in the trash left it out
+	app.enqueueFanout(post.ID)
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// restoreCommentHandler godoc
+//
+//	@Summary		Restores a trashed comment
+//	@Description	Takes a comment out of the trash. Authors can restore comments they deleted themselves, admins any.
+//	@Tags			trash
+//	@Param			commentID	path		int		true	"Comment ID"
+//	@Success		204			{string}	string	"Comment restored"
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/trash/comments/{commentID}/restore [post]
+func (app *application) restoreCommentHandler(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("commentID"), 10, 64)
+	if err != nil {
+		return app.badRequestResponse(c, errors.New("invalid comment ID"))
+	}
+	ctx := c.Context()
+
+	comment, err := app.store.Comments.GetDeleted(ctx, uint(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			return app.notFoundResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	allowed, err := app.canRestore(c, comment.UserID, comment.DeletedByID)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+	if !allowed {
+		return app.forbiddenResponse(c)
+	}
+
+	if err := app.store.Comments.Restore(ctx, comment.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			return app.notFoundResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func parseTrashQuery(c *fiber.Ctx) (store.PaginatedFeedQuery, error) {
+	fq := store.PaginatedFeedQuery{
+		Limit:  20,
+		Offset: 0,
+		Sort:   "desc",
+		Tags:   []string{},
+	}
+
+	fq, err := fq.Parse(c)
+	if err != nil {
+		return fq, err
+	}
+	if err := Validate.Struct(fq); err != nil {
+		return fq, err
+	}
+	return fq, nil
+}
+
+// trashOwner is whose trash to list: the user's own, or everyone's (0)
+// when an admin asks for all. ok is false for anyone else asking for all.
+func (app *application) trashOwner(c *fiber.Ctx) (userID uint, ok bool, err error) {
+	user := getUserFromContext(c)
+	if !c.QueryBool("all") {
+		return user.ID, true, nil
+	}
+
+	allowed, err := app.checkRolePrecedence(c, user, "admin")
+	if err != nil || !allowed {
+		return 0, false, err
+	}
+	return 0, true, nil
+}
+
+// canRestore lets authors restore what they deleted themselves; what a
+// moderator took down only an admin can bring back.
+func (app *application) canRestore(c *fiber.Ctx, ownerID uint, deletedByID *uint) (bool, error) {
+	user := getUserFromContext(c)
+	if ownerID == user.ID && deletedByID != nil && *deletedByID == user.ID {
+		return true, nil
+	}
+	return app.checkRolePrecedence(c, user, "admin")
+}
+
+// purgeTrash deletes posts and comments that have been in the trash for
+// longer than the retention period.
+func (app *application) purgeTrash(ctx context.Context) error {
+	cutoff := time.Now().Add(-app.config.trash.retention)
+
+	for {
+		n, err := app.store.Posts.PurgeDeleted(ctx, cutoff, trashPurgeBatch)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			app.logger.Infow("purged trashed posts", "count", n)
+		}
+		if n < trashPurgeBatch {
+			break
+		}
+	}
+
+	for {
+		n, err := app.store.Comments.PurgeDeleted(ctx, cutoff, trashPurgeBatch)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			app.logger.Infow("purged trashed comments", "count", n)
+		}
+		if n < trashPurgeBatch {
+			break
+		}
+	}
+
+	return nil
+}
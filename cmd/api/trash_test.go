@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap"
+
+	"github.com/pangdfg/gopher-social/internal/store"
+)
+
+// trashedPosts has a single post in the trash and records what is done
+// with the trash.
+type trashedPosts struct {
+	store.Posts
+	post       store.Post
+	restored   []uint
+	trashOf    []uint
+	purgeSizes []int64
+}
+
+func (f *trashedPosts) GetDeleted(_ context.Context, id uint) (*store.Post, error) {
+	if id != f.post.ID {
+		return nil, store.ErrNotFound
+	}
+	post := f.post
+	return &post, nil
+}
+
+func (f *trashedPosts) Restore(_ context.Context, id uint) error {
+	f.restored = append(f.restored, id)
+	return nil
+}
+
+func (f *trashedPosts) GetTrash(_ context.Context, _ store.PaginatedFeedQuery, userID uint) ([]store.Post, error) {
+	f.trashOf = append(f.trashOf, userID)
+	return nil, nil
+}
+
+func (f *trashedPosts) PurgeDeleted(_ context.Context, _ time.Time, _ int) (int64, error) {
+	n := f.purgeSizes[0]
+	f.purgeSizes = f.purgeSizes[1:]
+	return n, nil
+}
+
+type purgedComments struct {
+	store.Comments
+	calls int
+}
+
+func (f *purgedComments) PurgeDeleted(_ context.Context, _ time.Time, _ int) (int64, error) {
+	f.calls++
+	return 0, nil
+}
+
+var _ = Describe("Trash", func() {
+	const (
+		authorID    = uint(1)
+		moderatorID = uint(2)
+		adminID     = uint(3)
+	)
+
+	var (
+		app   *application
+		posts *trashedPosts
+	)
+
+	BeforeEach(func() {
+		posts = &trashedPosts{post: store.Post{ID: 10, UserID: authorID}}
+		app = &application{
+			logger: zap.NewNop().Sugar(),
+			store:  store.Storage{Posts: posts, Roles: fakeRoles{}},
+		}
+	})
+
+	serve := func(method, target string, userID uint, role string) int {
+		f := fiber.New()
+		f.Use(func(c *fiber.Ctx) error {
+			r, _ := fakeRoles{}.GetByName(c.Context(), role)
+			c.Locals("user", &store.User{ID: userID, Role: *r})
+			return c.Next()
+		})
+		f.Get("/trash/posts", app.getTrashedPostsHandler)
+		f.Post("/trash/posts/:postID/restore", app.restorePostHandler)
+
+		resp, err := f.Test(httptest.NewRequest(method, target, nil), -1)
+		Expect(err).NotTo(HaveOccurred())
+		return resp.StatusCode
+	}
+
+	restore := func(userID uint, role string) int {
+		return serve(fiber.MethodPost, "/trash/posts/10/restore", userID, role)
+	}
+
+	Describe("restoring a post", func() {
+		It("lets authors restore what they deleted themselves", func() {
+			posts.post.DeletedByID = &posts.post.UserID
+
+			Expect(restore(authorID, "user")).To(Equal(fiber.StatusNoContent))
+			Expect(posts.restored).To(Equal([]uint{10}))
+		})
+
+		It("keeps a post a moderator took down from its author", func() {
+			deletedBy := moderatorID
+			posts.post.DeletedByID = &deletedBy
+
+			Expect(restore(authorID, "user")).To(Equal(fiber.StatusForbidden))
+			Expect(restore(moderatorID, "moderator")).To(Equal(fiber.StatusForbidden))
+			Expect(posts.restored).To(BeEmpty())
+
+			Expect(restore(adminID, "admin")).To(Equal(fiber.StatusNoContent))
+			Expect(posts.restored).To(Equal([]uint{10}))
+		})
+
+		It("returns 404 for a post that isn't in the trash", func() {
+			Expect(serve(fiber.MethodPost, "/trash/posts/11/restore", adminID, "admin")).To(Equal(fiber.StatusNotFound))
+		})
+	})
+
+	Describe("listing trashed posts", func() {
+		It("lists the user's own trash", func() {
+			Expect(serve(fiber.MethodGet, "/trash/posts", authorID, "user")).To(Equal(fiber.StatusOK))
+			Expect(posts.trashOf).To(Equal([]uint{authorID}))
+		})
+
+		It("refuses everyone's trash to non admins", func() {
+			Expect(serve(fiber.MethodGet, "/trash/posts?all=true", authorID, "user")).To(Equal(fiber.StatusForbidden))
+			Expect(serve(fiber.MethodGet, "/trash/posts?all=true", moderatorID, "moderator")).To(Equal(fiber.StatusForbidden))
+			Expect(posts.trashOf).To(BeEmpty())
+		})
+
+		It("lists everyone's trash to admins", func() {
+			Expect(serve(fiber.MethodGet, "/trash/posts?all=true", adminID, "admin")).To(Equal(fiber.StatusOK))
+			Expect(posts.trashOf).To(Equal([]uint{0}))
+		})
+	})
+
+	It("purges in batches until one comes back short", func() {
+		posts.purgeSizes = []int64{trashPurgeBatch, trashPurgeBatch, 3}
+		comments := &purgedComments{}
+		app.store.Comments = comments
+
+		Expect(app.purgeTrash(context.Background())).To(Succeed())
+		Expect(posts.purgeSizes).To(BeEmpty())
+		Expect(comments.calls).To(Equal(1))
+	})
+})
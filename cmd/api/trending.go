@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/pangdfg/gopher-social/internal/store"
+)
+
+const (
+	defaultTrendingWindow = "24h"
+	defaultTrendingLimit  = 20
+)
+
+// trendingWindow is a period trending is computed over. Activity loses
+// half its weight every quarter window, so the last hours of a day matter
+// more than its first ones.
+type trendingWindow struct {
+	name   string
+	period time.Duration
+}
+
+func (w trendingWindow) halfLife() time.Duration {
+	return w.period / 4
+}
+
+// parseTrendingWindows reads a list like "1h,24h,7d". Besides the units of
+// time.ParseDuration, "d" stands for days.
+func parseTrendingWindows(s string) ([]trendingWindow, error) {
+	var windows []trendingWindow
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		var period time.Duration
+		if days, ok := strings.CutSuffix(name, "d"); ok {
+			n, err := strconv.Atoi(days)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trending window %q", name)
+			}
+			period = time.Duration(n) * 24 * time.Hour
+		} else {
+			d, err := time.ParseDuration(name)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trending window %q", name)
+			}
+			period = d
+		}
+		if period <= 0 {
+			return nil, fmt.Errorf("invalid trending window %q", name)
+		}
+
+		windows = append(windows, trendingWindow{name: name, period: period})
+	}
+
+	if len(windows) == 0 {
+		return nil, fmt.Errorf("no trending windows configured")
+	}
+	return windows, nil
+}
+
+type TrendingTag struct {
+	TagsMini
+	Score float64 `json:"score"`
+}
+
+type TrendingTagsResponse struct {
+	Window string        `json:"window"`
+	Tags   []TrendingTag `json:"tags"`
+}
+
+type TrendingPost struct {
+	PostMini
+	Score float64 `json:"score"`
+}
+
+type TrendingPostsResponse struct {
+	Window string         `json:"window"`
+	Posts  []TrendingPost `json:"posts"`
+}
+
+// getTrendingTagsHandler godoc
+//
+//	@Summary		Fetches trending tags
+//	@Description	Tags ranked by recent activity on their posts, with older activity counting less. Rankings are recomputed periodically.
+//	@Tags			tags
+//	@Produce		json
+//	@Param			window	query		string	false	"Window, one of the configured ones (default 24h)"
+//	@Param			limit	query		int		false	"Limit (default 20)"
+//	@Success		200		{object}	TrendingTagsResponse
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/tags/trending [get]
+func (app *application) getTrendingTagsHandler(c *fiber.Ctx) error {
+	window, limit, err := app.parseTrendingQuery(c)
+	if err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	ranked, err := app.cacheStorage.Rankings.Top(c.Context(), trendingRanking("tags", window), limit)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	tags, err := app.store.Tags.GetByIDs(c.Context(), scoredIDs(ranked))
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	byID := make(map[uint]store.Tag, len(tags))
+	for _, t := range tags {
+		byID[t.ID] = t
+	}
+
+	res := make([]TrendingTag, 0, len(ranked))
+	for _, r := range ranked {
+		t, ok := byID[r.ID]
+		if !ok {
+			continue // deleted since the last refresh
+		}
+		res = append(res, TrendingTag{
+			TagsMini: TagsMini{ID: t.ID, Title: t.Title, Name: t.Name},
+			Score:    r.Score,
+		})
+	}
+
+	return app.jsonResponse(c, fiber.StatusOK, TrendingTagsResponse{Window: window.name, Tags: res})
+}
+
+// getTrendingPostsHandler godoc
+//
+//	@Summary		Fetches trending posts
+//	@Description	Posts ranked by recent activity, with older activity counting less. Rankings are recomputed periodically.
+//	@Tags			posts
+//	@Produce		json
+//	@Param			window	query		string	false	"Window, one of the configured ones (default 24h)"
+//	@Param			limit	query		int		false	"Limit (default 20)"
+//	@Success		200		{object}	TrendingPostsResponse
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/posts/trending [get]
+func (app *application) getTrendingPostsHandler(c *fiber.Ctx) error {
+	window, limit, err := app.parseTrendingQuery(c)
+	if err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	ranked, err := app.cacheStorage.Rankings.Top(c.Context(), trendingRanking("posts", window), limit)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	posts, err := app.store.Posts.GetByIDs(c.Context(), scoredIDs(ranked))
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	byID := make(map[uint]*store.Post, len(posts))
+	for i := range posts {
+		byID[posts[i].ID] = &posts[i]
+	}
+
+	minis := make([]PostMini, 0, len(ranked))
+	scores := make([]float64, 0, len(ranked))
+	for _, r := range ranked {
+		p, ok := byID[r.ID]
+		if !ok {
+			continue // deleted since the last refresh
+		}
+		minis = append(minis, newPostMini(p))
+		scores = append(scores, r.Score)
+	}
+	if err := app.markBookmarked(c.Context(), getUserFromContext(c), minis); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	res := make([]TrendingPost, 0, len(minis))
+	for i := range minis {
+		res = append(res, TrendingPost{PostMini: minis[i], Score: scores[i]})
+	}
+
+	return app.jsonResponse(c, fiber.StatusOK, TrendingPostsResponse{Window: window.name, Posts: res})
+}
+
+func (app *application) parseTrendingQuery(c *fiber.Ctx) (trendingWindow, int, error) {
+	windows := app.config.trending.windows
+	name := c.Query("window")
+
+	// without a window ask for 24h, or the first one if that isn't configured
+	window := windows[0]
+	var names []string
+	for _, w := range windows {
+		names = append(names, w.name)
+		if w.name == name || (name == "" && w.name == defaultTrendingWindow) {
+			window = w
+		}
+	}
+	if name != "" && window.name != name {
+		return window, 0, fmt.Errorf("window must be one of %s", strings.Join(names, ", "))
+	}
+
+	limit := c.QueryInt("limit", defaultTrendingLimit)
+	if limit < 1 || limit > app.config.trending.size {
+		return window, 0, fmt.Errorf("limit must be between 1 and %d", app.config.trending.size)
+	}
+
+	return window, limit, nil
+}
+
+// refreshTrending recomputes every ranking. Rankings outlive a few missed
+// refreshes and then expire rather than go on showing stale results.
+func (app *application) refreshTrending(ctx context.Context) error {
+	now := time.Now()
+	ttl := 3 * app.config.trending.refresh
+
+	for _, w := range app.config.trending.windows {
+		q := store.TrendingQuery{
+			Since:      now.Add(-w.period),
+			Now:        now,
+			HalfLife:   w.halfLife(),
+			MinAuthors: app.config.trending.minAuthors,
+			Limit:      app.config.trending.size,
+		}
+
+		posts, err := app.store.Trending.Posts(ctx, q)
+		if err != nil {
+			return err
+		}
+		if err := app.cacheStorage.Rankings.Replace(ctx, trendingRanking("posts", w), posts, ttl); err != nil {
+			return err
+		}
+
+		tags, err := app.store.Trending.Tags(ctx, q)
+		if err != nil {
+			return err
+		}
+		if err := app.cacheStorage.Rankings.Replace(ctx, trendingRanking("tags", w), tags, ttl); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func trendingRanking(kind string, w trendingWindow) string {
+	return "trending-" + kind + "-" + w.name
+}
+
+func scoredIDs(entries []store.Scored) []uint {
+	ids := make([]uint, 0, len(entries))
+	for _, e := range entries {
+		ids = append(ids, e.ID)
+	}
+	return ids
+}
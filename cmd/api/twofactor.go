@@ -0,0 +1,400 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/pangdfg/gopher-social/internal/auth"
+	"github.com/pangdfg/gopher-social/internal/store"
+)
+
+const (
+	twoFactorChallengeType = "2fa_challenge"
+	recoveryCodeCount      = 10
+)
+
+var errInvalidTwoFactorCode = errors.New("invalid two-factor code")
+
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token"`
+}
+
+type TwoFactorEnrollResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type TwoFactorCodePayload struct {
+	Code string `json:"code" validate:"required,max=32"`
+}
+
+type DisableTwoFactorPayload struct {
+	Password string `json:"password" validate:"required,min=3,max=72"`
+	Code     string `json:"code" validate:"required,max=32"`
+}
+
+type VerifyTwoFactorPayload struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	Code           string `json:"code" validate:"required,max=32"`
+}
+
+// enrollTwoFactorHandler godoc
+//
+//	@Summary		Starts 2FA enrollment
+//	@Description	Generates a TOTP secret for the authenticated user. 2FA stays off until the secret is confirmed with a code.
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{object}	TwoFactorEnrollResponse
+//	@Failure		401	{object}	error
+//	@Failure		409	{object}	error	"2FA already enabled"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/2fa/enroll [post]
+func (app *application) enrollTwoFactorHandler(c *fiber.Ctx) error {
+	user, err := app.store.Users.GetByID(c.Context(), getUserFromContext(c).ID)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	if user.TOTPEnabled {
+		return app.conflictResponse(c, errors.New("two-factor authentication is already enabled"))
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	if err := app.store.Users.SetTOTPSecret(c.Context(), user.ID, secret); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return app.jsonResponse(c, fiber.StatusOK, TwoFactorEnrollResponse{
+		Secret:     secret,
+		OTPAuthURI: auth.TOTPURI(app.config.auth.twoFactor.issuer, user.Email, secret),
+	})
+}
+
+// confirmTwoFactorHandler godoc
+//
+//	@Summary		Confirms 2FA enrollment
+//	@Description	Enables 2FA once a code from the enrolled secret is supplied and returns the one-time recovery codes
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		TwoFactorCodePayload	true	"TOTP code"
+//	@Success		200		{object}	RecoveryCodesResponse
+//	@Failure		400		{object}	error
+//	@Failure		409		{object}	error	"2FA already enabled"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/2fa/confirm [post]
+func (app *application) confirmTwoFactorHandler(c *fiber.Ctx) error {
+	var payload TwoFactorCodePayload
+	if err := readJSON(c, &payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+	if err := Validate.Struct(payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	ctx := c.Context()
+	user, err := app.store.Users.GetCredentials(ctx, getUserFromContext(c).ID)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	if user.TOTPEnabled {
+		return app.conflictResponse(c, errors.New("two-factor authentication is already enabled"))
+	}
+	if user.TOTPSecret == "" {
+		return app.badRequestResponse(c, errors.New("two-factor enrollment has not been started"))
+	}
+
+	step, ok := auth.ValidateTOTP(user.TOTPSecret, payload.Code, time.Now())
+	if !ok {
+		return app.badRequestResponse(c, errInvalidTwoFactorCode)
+	}
+	if err := app.store.Users.ConsumeTOTPStep(ctx, user.ID, step); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	if err := app.store.Users.EnableTOTP(ctx, user.ID); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	codes, err := app.resetRecoveryCodes(ctx, user.ID)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return app.jsonResponse(c, fiber.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// disableTwoFactorHandler godoc
+//
+//	@Summary		Disables 2FA
+//	@Description	Turns 2FA off. Requires the current password and a TOTP or recovery code.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		DisableTwoFactorPayload	true	"Credentials"
+//	@Success		204		{string}	string	"2FA disabled"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		429		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/2fa/disable [post]
+func (app *application) disableTwoFactorHandler(c *fiber.Ctx) error {
+	var payload DisableTwoFactorPayload
+	if err := readJSON(c, &payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+	if err := Validate.Struct(payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	ctx := c.Context()
+	user, err := app.store.Users.GetCredentials(ctx, getUserFromContext(c).ID)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	if !user.TOTPEnabled {
+		return app.badRequestResponse(c, errors.New("two-factor authentication is not enabled"))
+	}
+
+	if err := user.Authenticate(payload.Password); err != nil {
+		return app.unauthorizedErrorResponse(c, err)
+	}
+
+	ok, err := app.verifySecondFactor(ctx, user, payload.Code)
+	if err != nil {
+		switch err {
+		case store.ErrTooManyAttempts:
+			return app.rateLimitExceededResponse(c, app.config.auth.twoFactor.lockout.String())
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+	if !ok {
+		return app.unauthorizedError(c, errInvalidTwoFactorCode)
+	}
+
+	if err := app.store.Users.DisableTOTP(ctx, user.ID); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// regenerateRecoveryCodesHandler godoc
+//
+//	@Summary		Regenerates 2FA recovery codes
+//	@Description	Invalidates all existing recovery codes and returns a fresh set
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		TwoFactorCodePayload	true	"TOTP code"
+//	@Success		200		{object}	RecoveryCodesResponse
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		429		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/2fa/recovery-codes [post]
+func (app *application) regenerateRecoveryCodesHandler(c *fiber.Ctx) error {
+	var payload TwoFactorCodePayload
+	if err := readJSON(c, &payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+	if err := Validate.Struct(payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	ctx := c.Context()
+	user, err := app.store.Users.GetCredentials(ctx, getUserFromContext(c).ID)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	if !user.TOTPEnabled {
+		return app.badRequestResponse(c, errors.New("two-factor authentication is not enabled"))
+	}
+
+	ok, err := app.verifySecondFactor(ctx, user, payload.Code)
+	if err != nil {
+		switch err {
+		case store.ErrTooManyAttempts:
+			return app.rateLimitExceededResponse(c, app.config.auth.twoFactor.lockout.String())
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+	if !ok {
+		return app.unauthorizedError(c, errInvalidTwoFactorCode)
+	}
+
+	codes, err := app.resetRecoveryCodes(ctx, user.ID)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return app.jsonResponse(c, fiber.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// verifyTwoFactorHandler godoc
+//
+//	@Summary		Completes a 2FA login
+//	@Description	Exchanges the challenge token from /auth/token and a TOTP or recovery code for an access token
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		VerifyTwoFactorPayload	true	"Challenge and code"
+//	@Success		201		{string}	string	"Token"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		429		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/auth/token/2fa [post]
+func (app *application) verifyTwoFactorHandler(c *fiber.Ctx) error {
+	var payload VerifyTwoFactorPayload
+	if err := readJSON(c, &payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+	if err := Validate.Struct(payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	jwtToken, err := app.authenticator.ValidateTokenAuth(payload.ChallengeToken)
+	if err != nil {
+		return app.unauthorizedError(c, err)
+	}
+
+	claims := jwtToken.Claims.(jwt.MapClaims)
+	if claims["type"] != twoFactorChallengeType {
+		return app.unauthorizedError(c, errors.New("token is not a two-factor challenge"))
+	}
+
+	userID, err := subjectFromClaims(claims)
+	if err != nil {
+		return app.unauthorizedError(c, err)
+	}
+
+	ctx := c.Context()
+	user, err := app.store.Users.GetCredentials(ctx, userID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			return app.unauthorizedError(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	if !user.TOTPEnabled {
+		return app.unauthorizedError(c, errors.New("two-factor authentication is not enabled"))
+	}
+
+	ok, err := app.verifySecondFactor(ctx, user, payload.Code)
+	if err != nil {
+		switch err {
+		case store.ErrTooManyAttempts:
+			return app.rateLimitExceededResponse(c, app.config.auth.twoFactor.lockout.String())
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+	if !ok {
+		return app.unauthorizedError(c, errInvalidTwoFactorCode)
+	}
+
+	token, err := app.issueAccessToken(user)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(token)
+}
+
+// issueTwoFactorChallenge signs a short-lived token that proves the password
+// step passed. It has no audience, so AuthTokenMiddleware never accepts it.
+func (app *application) issueTwoFactorChallenge(user *store.User) (string, error) {
+	claims := jwt.MapClaims{
+		"sub":  user.ID,
+		"type": twoFactorChallengeType,
+		"exp":  time.Now().Add(app.config.auth.twoFactor.challengeExp).Unix(),
+		"iat":  time.Now().Unix(),
+		"iss":  app.config.auth.token.iss,
+	}
+
+	return app.authenticator.GenerateToken(claims)
+}
+
+// verifySecondFactor accepts either a current TOTP code that hasn't been
+// used yet or one of the user's unused recovery codes. Every attempt counts
+// against the user until one succeeds; past the limit it returns
+// store.ErrTooManyAttempts without checking the code.
+func (app *application) verifySecondFactor(ctx context.Context, user *store.User, code string) (bool, error) {
+	cfg := app.config.auth.twoFactor
+	if err := app.store.Users.ReserveTOTPAttempt(ctx, user.ID, cfg.maxAttempts, cfg.lockout); err != nil {
+		return false, err
+	}
+
+	ok, err := app.checkSecondFactor(ctx, user, code)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	if err := app.store.Users.ResetTOTPFailures(ctx, user.ID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (app *application) checkSecondFactor(ctx context.Context, user *store.User, code string) (bool, error) {
+	if step, ok := auth.ValidateTOTP(user.TOTPSecret, code, time.Now()); ok {
+		err := app.store.Users.ConsumeTOTPStep(ctx, user.ID, step)
+		switch err {
+		case nil:
+			return true, nil
+		case store.ErrConflict:
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+
+	err := app.store.RecoveryCodes.Consume(ctx, user.ID, code)
+	switch err {
+	case nil:
+		return true, nil
+	case store.ErrNotFound:
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+func (app *application) resetRecoveryCodes(ctx context.Context, userID uint) ([]string, error) {
+	codes, err := auth.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := app.store.RecoveryCodes.Replace(ctx, userID, codes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
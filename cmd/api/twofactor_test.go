@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap"
+
+	"github.com/pangdfg/gopher-social/internal/auth"
+	"github.com/pangdfg/gopher-social/internal/store"
+)
+
+// stepUsers keeps the last used TOTP step and the attempt count the way
+// UserStore does, without the lockout window.
+type stepUsers struct {
+	store.Users
+	lastStep map[uint]int64
+	attempts map[uint]int
+}
+
+func (f *stepUsers) ReserveTOTPAttempt(_ context.Context, userID uint, limit int, _ time.Duration) error {
+	if f.attempts[userID] >= limit {
+		return store.ErrTooManyAttempts
+	}
+	f.attempts[userID]++
+	return nil
+}
+
+func (f *stepUsers) ResetTOTPFailures(_ context.Context, userID uint) error {
+	delete(f.attempts, userID)
+	return nil
+}
+
+func (f *stepUsers) ConsumeTOTPStep(_ context.Context, userID uint, step int64) error {
+	if last, ok := f.lastStep[userID]; ok && last >= step {
+		return store.ErrConflict
+	}
+	f.lastStep[userID] = step
+	return nil
+}
+
+type noRecoveryCodes struct {
+	store.RecoveryCodes
+}
+
+func (noRecoveryCodes) Consume(context.Context, uint, string) error {
+	return store.ErrNotFound
+}
+
+var _ = Describe("Second factor", func() {
+	var (
+		ctx  context.Context
+		app  *application
+		user *store.User
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		app = &application{
+			config: config{auth: authConfig{twoFactor: twoFactorConfig{maxAttempts: 5, lockout: time.Minute}}},
+			logger: zap.NewNop().Sugar(),
+			store: store.Storage{
+				Users:         &stepUsers{lastStep: map[uint]int64{}, attempts: map[uint]int{}},
+				RecoveryCodes: noRecoveryCodes{},
+			},
+		}
+
+		secret, err := auth.GenerateTOTPSecret()
+		Expect(err).NotTo(HaveOccurred())
+		user = &store.User{ID: 1, TOTPSecret: secret, TOTPEnabled: true}
+	})
+
+	code := func(t time.Time) string {
+		c, err := auth.TOTPCode(user.TOTPSecret, t)
+		Expect(err).NotTo(HaveOccurred())
+		return c
+	}
+
+	It("accepts a code once", func() {
+		current := code(time.Now())
+
+		Expect(app.verifySecondFactor(ctx, user, current)).To(BeTrue())
+		Expect(app.verifySecondFactor(ctx, user, current)).To(BeFalse())
+	})
+
+	It("refuses the previous step's code once a later one was used", func() {
+		Expect(app.verifySecondFactor(ctx, user, code(time.Now()))).To(BeTrue())
+
+		Expect(app.verifySecondFactor(ctx, user, code(time.Now().Add(-30*time.Second)))).To(BeFalse())
+	})
+
+	It("locks after too many wrong codes, even for the right one", func() {
+		for range 5 {
+			Expect(app.verifySecondFactor(ctx, user, "not-a-code")).To(BeFalse())
+		}
+
+		_, err := app.verifySecondFactor(ctx, user, code(time.Now()))
+		Expect(err).To(MatchError(store.ErrTooManyAttempts))
+	})
+
+	It("starts counting again after a good code", func() {
+		for range 4 {
+			Expect(app.verifySecondFactor(ctx, user, "not-a-code")).To(BeFalse())
+		}
+		Expect(app.verifySecondFactor(ctx, user, code(time.Now()))).To(BeTrue())
+
+		for range 4 {
+			Expect(app.verifySecondFactor(ctx, user, "not-a-code")).To(BeFalse())
+		}
+		Expect(app.verifySecondFactor(ctx, user, code(time.Now().Add(30*time.Second)))).To(BeTrue())
+	})
+})
@@ -1,10 +1,17 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 
+	"github.com/pangdfg/gopher-social/internal/mailer"
 	"github.com/pangdfg/gopher-social/internal/store"
 )
 
@@ -54,7 +61,7 @@ func (app *application) getUserHandler(c *fiber.Ctx) error {
 	}
 
 
-	user, err := app.getUser(c.Context(), uint(userID))
+	user, err := app.store.Users.GetByID(c.Context(), uint(userID))
 	if err != nil {
 		switch err {
 		case store.ErrNotFound:
@@ -80,6 +87,10 @@ func (app *application) getUserHandler(c *fiber.Ctx) error {
 		fq.Limit,
 		fq.Offset,
 		)
+	if err := app.markBookmarked(c.Context(), getUserFromContext(c), response.Posts.Posts); err != nil {
+		return app.internalServerError(c, err)
+	}
+	c.Set(fiber.HeaderETag, versionETag(user.Version))
 	return app.jsonResponse(c, fiber.StatusOK, response)
 }
 
@@ -103,13 +114,17 @@ func (app *application) followUserHandler(c *fiber.Ctx) error {
 	if err != nil {
 		return app.badRequestResponse(c, err)
 	}
+	if uint(followedID) == followerUser.ID {
+		return app.badRequestResponse(c, errors.New("you can't follow yourself"))
+	}
 
-
-	err = app.store.Followers.Follow(c.Context(), uint(followedID), followerUser.ID)
+	err = app.store.Followers.Follow(c.Context(), followerUser.ID, uint(followedID))
 	if err != nil {
 		switch err {
 		case store.ErrConflict:
 			return app.conflictResponse(c, err)
+		case store.ErrNotFound:
+			return app.notFoundResponse(c, err)
 		default:
 			return app.internalServerError(c, err)
 		}
@@ -128,7 +143,7 @@ func (app *application) followUserHandler(c *fiber.Ctx) error {
 //	@Param			userID	path		int		true	"User ID"
 //	@Success		204		{string}	string	"User unfollowed"
 //	@Failure		400		{object}	error	"User payload missing"
-//	@Failure		404		{object}	error	"User not found"
+//	@Failure		404		{object}	error	"Not following the user"
 //	@Security		ApiKeyAuth
 //	@Router			/users/{userID}/unfollow [put]
 func (app *application) unfollowUserHandler(c *fiber.Ctx) error {
@@ -140,11 +155,11 @@ func (app *application) unfollowUserHandler(c *fiber.Ctx) error {
 	}
 
 
-	err = app.store.Followers.Unfollow(c.Context(), uint(followedID), unfollowerUser.ID)
+	err = app.store.Followers.Unfollow(c.Context(), unfollowerUser.ID, uint(followedID))
 	if err != nil {
 		switch err {
-		case store.ErrConflict:
-			return app.conflictResponse(c, err)
+		case store.ErrNotFound:
+			return app.notFoundResponse(c, err)
 		default:
 			return app.internalServerError(c, err)
 		}
@@ -240,13 +255,254 @@ func (app *application) updateUsernameHandler(c *fiber.Ctx) error {
 		return app.internalServerError(c, err)
 	}
 
-	return app.jsonResponse(c, fiber.StatusOK, UserMini{
-		ID: updatedUser.ID,
-		Username: updatedUser.Username,
-		Role: updatedUser.Role.Name,
+	return app.jsonResponse(c, fiber.StatusOK, newUserMini(updatedUser))
+}
+
+type UpdateProfilePayload struct {
+	DisplayName *string   `json:"display_name" validate:"omitempty,max=100"`
+	Bio         *string   `json:"bio" validate:"omitempty,max=500"`
+	Location    *string   `json:"location" validate:"omitempty,max=100"`
+	Links       *[]string `json:"links" validate:"omitempty,max=5,dive,http_url,max=255"`
+	AvatarURL   *string   `json:"avatar_url" validate:"omitempty,http_url,max=2048"`
+	BannerURL   *string   `json:"banner_url" validate:"omitempty,http_url,max=2048"`
+	Language    *string   `json:"language" validate:"omitempty,bcp47_language_tag"`
+}
+
+type ProfileResponse struct {
+	ID       uint   `json:"id"`
+	Username string `json:"username"`
+	UserProfile
+}
+
+// UpdateProfileHandler godoc
+//
+//	@Summary		Update the authenticated user's profile
+//	@Description	Partially updates display name, bio, location, links, avatar and banner URLs and language. Omitted fields are left unchanged; empty values clear them.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			If-Match	header		string					true	"ETag of the user"
+//	@Param			payload		body		UpdateProfilePayload	true	"Profile fields"
+//	@Success		200		{object}	ProfileResponse
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		412		{object}	error	"Profile changed since it was fetched"
+//	@Failure		428		{object}	error	"If-Match missing"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/profile [patch]
+func (app *application) UpdateProfileHandler(c *fiber.Ctx) error {
+	var payload UpdateProfilePayload
+	if err := readJSON(c, &payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+	if err := Validate.Struct(payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	ctx := c.Context()
+	user, err := app.store.Users.GetByID(ctx, getUserFromContext(c).ID)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	if err := checkIfMatch(c, user.Version); err != nil {
+		return app.ifMatchResponse(c, err)
+	}
+
+	if payload.DisplayName != nil {
+		user.DisplayName = strings.TrimSpace(*payload.DisplayName)
+	}
+	if payload.Bio != nil {
+		user.Bio = strings.TrimSpace(*payload.Bio)
+	}
+	if payload.Location != nil {
+		user.Location = strings.TrimSpace(*payload.Location)
+	}
+	if payload.Links != nil {
+		user.Links = *payload.Links
+	}
+	if payload.AvatarURL != nil {
+		user.AvatarURL = *payload.AvatarURL
+	}
+	if payload.BannerURL != nil {
+		user.BannerURL = *payload.BannerURL
+	}
+	if payload.Language != nil {
+		user.Language = *payload.Language
+	}
+
+	if err := app.store.Users.UpdateProfile(ctx, user); err != nil {
+		switch {
+		case errors.Is(err, store.ErrConflict):
+			return app.preconditionFailedResponse(c)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	c.Set(fiber.HeaderETag, versionETag(user.Version))
+	return app.jsonResponse(c, fiber.StatusOK, ProfileResponse{
+		ID:          user.ID,
+		Username:    user.Username,
+		UserProfile: newUserProfile(user),
 	})
 }
 
+type ChangeEmailPayload struct {
+	NewEmail string `json:"new_email" validate:"required,email,max=255"`
+	Password string `json:"password" validate:"required,min=3,max=72"`
+}
+
+const emailChangeTokenType = "email_change"
+
+// ChangeEmailHandler godoc
+//
+//	@Summary		Request an email address change
+//	@Description	Sends a confirmation link to the new address. The email is only swapped once the link is confirmed.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ChangeEmailPayload	true	"New email and current password"
+//	@Success		202		{string}	string	"Confirmation sent"
+//	@Failure		400		{object}	error	"Bad request / email already in use"
+//	@Failure		401		{object}	error	"Current password invalid"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/change-email [post]
+func (app *application) ChangeEmailHandler(c *fiber.Ctx) error {
+	var payload ChangeEmailPayload
+	if err := readJSON(c, &payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+	if err := Validate.Struct(payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	ctx := c.Context()
+	user, err := app.store.Users.GetCredentials(ctx, getUserFromContext(c).ID)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	if err := user.Authenticate(payload.Password); err != nil {
+		return app.unauthorizedErrorResponse(c, err)
+	}
+
+	if strings.EqualFold(payload.NewEmail, user.Email) {
+		return app.badRequestResponse(c, errors.New("new email matches the current one"))
+	}
+
+	if _, err := app.store.Users.GetByEmail(ctx, payload.NewEmail); err == nil {
+		return app.badRequestResponse(c, store.ErrDuplicateEmail)
+	} else if err != store.ErrNotFound {
+		return app.internalServerError(c, err)
+	}
+
+	// old_email pins the token to the current address, so it stops working
+	// once the email changes by any other means
+	claims := jwt.MapClaims{
+		"sub":       user.ID,
+		"email":     payload.NewEmail,
+		"old_email": user.Email,
+		"id":        uuid.New().String(),
+		"exp":       time.Now().Add(app.config.mail.exp).Unix(),
+		"type":      emailChangeTokenType,
+	}
+
+	token, err := app.authenticator.GenerateToken(claims)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	mailVars := struct {
+		Username   string
+		ConfirmURL string
+	}{
+		Username:   user.Username,
+		ConfirmURL: fmt.Sprintf("%s/confirm-email/%s", app.config.frontendURL, token),
+	}
+
+	isProdEnv := app.config.env == "production"
+	status, err := app.mailer.Send(mailer.EmailChangeTemplate, user.Username, payload.NewEmail, mailVars, !isProdEnv)
+	if err != nil {
+		app.logger.Errorw("error sending email change confirmation", "error", err)
+		return app.internalServerError(c, err)
+	}
+
+	app.logger.Infow("Email sent", "status code", status)
+
+	return c.SendStatus(fiber.StatusAccepted)
+}
+
+// ConfirmEmailHandler godoc
+//
+//	@Summary		Confirm an email address change
+//	@Description	Swaps the account email for the address the confirmation token was sent to and notifies the old address
+//	@Tags			users
+//	@Produce		json
+//	@Param			token	path		string	true	"Confirmation token"
+//	@Success		204		{string}	string	"Email changed"
+//	@Failure		400		{object}	error	"Email already in use"
+//	@Failure		401		{object}	error	"Invalid or expired token"
+//	@Failure		500		{object}	error
+//	@Router			/users/confirm-email/{token} [put]
+func (app *application) ConfirmEmailHandler(c *fiber.Ctx) error {
+	claims, err := app.parseActionToken(c.Params("token"), emailChangeTokenType)
+	if err != nil {
+		return app.unauthorizedError(c, err)
+	}
+
+	userID, err := subjectFromClaims(claims)
+	if err != nil {
+		return app.unauthorizedError(c, err)
+	}
+	newEmail, _ := claims["email"].(string)
+	oldEmail, _ := claims["old_email"].(string)
+
+	ctx := c.Context()
+	user, err := app.store.Users.GetByID(ctx, userID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			return app.unauthorizedError(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	if newEmail == "" || !strings.EqualFold(user.Email, oldEmail) {
+		return app.unauthorizedError(c, errors.New("email change token is no longer valid"))
+	}
+
+	user.Email = newEmail
+	if err := app.store.Users.UpdateEmail(ctx, user); err != nil {
+		switch err {
+		case store.ErrDuplicateEmail:
+			return app.badRequestResponse(c, err)
+		case store.ErrNotFound:
+			return app.unauthorizedError(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	mailVars := struct {
+		Username string
+		NewEmail string
+	}{
+		Username: user.Username,
+		NewEmail: newEmail,
+	}
+
+	isProdEnv := app.config.env == "production"
+	if _, err := app.mailer.Send(mailer.EmailChangedNoticeTemplate, user.Username, oldEmail, mailVars, !isProdEnv); err != nil {
+		app.logger.Errorw("error sending email change notice", "error", err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
 func getUserFromContext(c *fiber.Ctx) *store.User {
 	user, ok := c.Locals("user").(*store.User)
 	if !ok || user == nil {
@@ -0,0 +1,23 @@
+ALTER TABLE
+  users
+ADD
+  COLUMN totp_secret TEXT,
+ADD
+  COLUMN totp_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+ADD
+  COLUMN totp_last_step BIGINT;
+
+CREATE TABLE IF NOT EXISTS user_recovery_codes (
+  id bigserial PRIMARY KEY,
+  user_id bigint NOT NULL,
+  code_hash text NOT NULL,
+  used_at timestamp(0) with time zone,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+  CONSTRAINT fk_user_recovery_codes_user
+    FOREIGN KEY (user_id)
+    REFERENCES users (id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_recovery_codes_user_id ON user_recovery_codes (user_id);
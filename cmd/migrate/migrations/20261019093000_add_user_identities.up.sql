@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS user_identities (
+  id bigserial PRIMARY KEY,
+  user_id bigint NOT NULL,
+  provider varchar(64) NOT NULL,
+  subject varchar(255) NOT NULL,
+  email citext,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+  CONSTRAINT uq_user_identities_provider_subject UNIQUE (provider, subject),
+
+  CONSTRAINT fk_user_identities_user
+    FOREIGN KEY (user_id)
+    REFERENCES users (id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities (user_id);
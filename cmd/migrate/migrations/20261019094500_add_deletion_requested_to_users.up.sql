@@ -0,0 +1,8 @@
+ALTER TABLE
+  users
+ADD
+  COLUMN deletion_requested_at timestamp(0) with time zone;
+
+CREATE INDEX IF NOT EXISTS idx_users_deletion_requested_at ON users (deletion_requested_at)
+WHERE
+  deletion_requested_at IS NOT NULL;
@@ -0,0 +1,8 @@
+ALTER TABLE
+  users DROP COLUMN IF EXISTS language,
+  DROP COLUMN IF EXISTS banner_url,
+  DROP COLUMN IF EXISTS avatar_url,
+  DROP COLUMN IF EXISTS website_links,
+  DROP COLUMN IF EXISTS location,
+  DROP COLUMN IF EXISTS bio,
+  DROP COLUMN IF EXISTS display_name;
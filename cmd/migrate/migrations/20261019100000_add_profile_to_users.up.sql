@@ -0,0 +1,16 @@
+ALTER TABLE
+  users
+ADD
+  COLUMN display_name varchar(100) NOT NULL DEFAULT '',
+ADD
+  COLUMN bio text NOT NULL DEFAULT '',
+ADD
+  COLUMN location varchar(100) NOT NULL DEFAULT '',
+ADD
+  COLUMN website_links text [] NOT NULL DEFAULT '{}',
+ADD
+  COLUMN avatar_url text NOT NULL DEFAULT '',
+ADD
+  COLUMN banner_url text NOT NULL DEFAULT '',
+ADD
+  COLUMN language varchar(35) NOT NULL DEFAULT '';
@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS media_attachments (
+  id bigserial PRIMARY KEY,
+  user_id bigint NOT NULL,
+  post_id bigint,
+  kind varchar(16) NOT NULL,
+  storage_key text NOT NULL UNIQUE,
+  url text NOT NULL,
+  content_type varchar(64) NOT NULL,
+  size bigint NOT NULL,
+  alt_text varchar(1000) NOT NULL DEFAULT '',
+  position int NOT NULL DEFAULT 0,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+  CONSTRAINT fk_media_attachments_user
+    FOREIGN KEY (user_id)
+    REFERENCES users (id)
+    ON DELETE CASCADE,
+
+  -- detached media is purged by a background job together with its blob
+  CONSTRAINT fk_media_attachments_post
+    FOREIGN KEY (post_id)
+    REFERENCES posts (id)
+    ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_media_attachments_post_id ON media_attachments (post_id, position);
+CREATE INDEX IF NOT EXISTS idx_media_attachments_user_id ON media_attachments (user_id);
@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS media_variants;
+
+DROP INDEX IF EXISTS idx_media_attachments_status;
+
+ALTER TABLE
+  media_attachments DROP COLUMN IF EXISTS claimed_at,
+  DROP COLUMN IF EXISTS blurhash,
+  DROP COLUMN IF EXISTS height,
+  DROP COLUMN IF EXISTS width,
+  DROP COLUMN IF EXISTS orientation,
+  DROP COLUMN IF EXISTS status;
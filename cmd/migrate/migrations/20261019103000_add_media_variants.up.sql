@@ -0,0 +1,36 @@
+ALTER TABLE
+  media_attachments
+ADD
+  COLUMN status varchar(16) NOT NULL DEFAULT 'pending',
+ADD
+  COLUMN orientation smallint NOT NULL DEFAULT 1,
+ADD
+  COLUMN width int NOT NULL DEFAULT 0,
+ADD
+  COLUMN height int NOT NULL DEFAULT 0,
+ADD
+  COLUMN blurhash varchar(64) NOT NULL DEFAULT '',
+ADD
+  COLUMN claimed_at timestamp(0) with time zone;
+
+CREATE INDEX IF NOT EXISTS idx_media_attachments_status ON media_attachments (status) WHERE status <> 'ready';
+
+CREATE TABLE IF NOT EXISTS media_variants (
+  id bigserial PRIMARY KEY,
+  media_id bigint NOT NULL,
+  name varchar(16) NOT NULL,
+  storage_key text NOT NULL UNIQUE,
+  url text NOT NULL,
+  content_type varchar(64) NOT NULL,
+  width int NOT NULL,
+  height int NOT NULL,
+  size bigint NOT NULL,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+  CONSTRAINT fk_media_variants_media
+    FOREIGN KEY (media_id)
+    REFERENCES media_attachments (id)
+    ON DELETE CASCADE,
+
+  CONSTRAINT uq_media_variants_media_name UNIQUE (media_id, name)
+);
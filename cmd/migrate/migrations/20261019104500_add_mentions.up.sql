@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS mentions (
+  id bigserial PRIMARY KEY,
+  user_id bigint NOT NULL,
+  post_id bigint,
+  comment_id bigint,
+  start_offset int NOT NULL,
+  end_offset int NOT NULL,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+  CONSTRAINT fk_mentions_user
+    FOREIGN KEY (user_id)
+    REFERENCES users (id)
+    ON DELETE CASCADE,
+
+  CONSTRAINT fk_mentions_post
+    FOREIGN KEY (post_id)
+    REFERENCES posts (id)
+    ON DELETE CASCADE,
+
+  CONSTRAINT fk_mentions_comment
+    FOREIGN KEY (comment_id)
+    REFERENCES comments (id)
+    ON DELETE CASCADE,
+
+  -- a mention belongs to exactly one of a post or a comment
+  CONSTRAINT ck_mentions_target CHECK ((post_id IS NULL) <> (comment_id IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_mentions_post_id ON mentions (post_id) WHERE post_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_mentions_comment_id ON mentions (comment_id) WHERE comment_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_mentions_user_id ON mentions (user_id);
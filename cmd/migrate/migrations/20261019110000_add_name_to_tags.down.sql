@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_tags_name;
+
+ALTER TABLE tags DROP COLUMN IF EXISTS name;
@@ -0,0 +1,6 @@
+ALTER TABLE tags ADD COLUMN IF NOT EXISTS name varchar(200);
+
+-- existing rows are named by `migrate up` once the migrations ran, with the
+-- same richtext.NormalizeTag the API uses for new tags (store.TagStore.
+-- BackfillNames); until then they have no name, which the index allows
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tags_name ON tags (name);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS tag_followers (
+  tag_id bigint NOT NULL,
+  user_id bigint NOT NULL,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+  PRIMARY KEY (tag_id, user_id),
+  FOREIGN KEY (tag_id) REFERENCES tags (id) ON DELETE CASCADE,
+  FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+-- the followed tags feed and list look up by user
+CREATE INDEX IF NOT EXISTS idx_tag_followers_user_id ON tag_followers (user_id);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS tag_aliases;
+ALTER TABLE tags DROP COLUMN IF EXISTS description;
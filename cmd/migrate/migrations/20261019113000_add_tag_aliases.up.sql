@@ -0,0 +1,17 @@
+ALTER TABLE tags ADD COLUMN IF NOT EXISTS description text NOT NULL DEFAULT '';
+
+-- alternative names that resolve to a canonical tag when posts are written
+CREATE TABLE IF NOT EXISTS tag_aliases (
+  id bigserial PRIMARY KEY,
+  tag_id bigint NOT NULL,
+  name varchar(200) NOT NULL,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+  CONSTRAINT fk_tag_aliases_tag
+    FOREIGN KEY (tag_id)
+    REFERENCES tags (id)
+    ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tag_aliases_name ON tag_aliases (name);
+CREATE INDEX IF NOT EXISTS idx_tag_aliases_tag_id ON tag_aliases (tag_id);
@@ -0,0 +1,43 @@
+CREATE TABLE IF NOT EXISTS bookmark_collections (
+  id bigserial PRIMARY KEY,
+  user_id bigint NOT NULL,
+  name varchar(100) NOT NULL,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+  CONSTRAINT fk_bookmark_collections_user
+    FOREIGN KEY (user_id)
+    REFERENCES users (id)
+    ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_bookmark_collections_user_name ON bookmark_collections (user_id, name);
+
+CREATE TABLE IF NOT EXISTS bookmarks (
+  user_id bigint NOT NULL,
+  post_id bigint NOT NULL,
+  collection_id bigint,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+  PRIMARY KEY (user_id, post_id),
+
+  CONSTRAINT fk_bookmarks_user
+    FOREIGN KEY (user_id)
+    REFERENCES users (id)
+    ON DELETE CASCADE,
+
+  -- bookmarks of a deleted post go with it
+  CONSTRAINT fk_bookmarks_post
+    FOREIGN KEY (post_id)
+    REFERENCES posts (id)
+    ON DELETE CASCADE,
+
+  -- deleting a collection keeps its bookmarks, uncollected
+  CONSTRAINT fk_bookmarks_collection
+    FOREIGN KEY (collection_id)
+    REFERENCES bookmark_collections (id)
+    ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_bookmarks_user_created_at ON bookmarks (user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_bookmarks_collection_id ON bookmarks (collection_id) WHERE collection_id IS NOT NULL;
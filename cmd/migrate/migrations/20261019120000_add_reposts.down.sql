@@ -0,0 +1,15 @@
+DELETE FROM posts WHERE kind = 'repost';
+
+DROP INDEX IF EXISTS idx_posts_quote_of_id;
+DROP INDEX IF EXISTS idx_posts_repost_of_id;
+DROP INDEX IF EXISTS idx_posts_user_repost_of;
+
+ALTER TABLE posts
+  DROP CONSTRAINT IF EXISTS fk_posts_quote_of,
+  DROP CONSTRAINT IF EXISTS fk_posts_repost_of,
+  DROP CONSTRAINT IF EXISTS ck_posts_quote,
+  DROP CONSTRAINT IF EXISTS ck_posts_repost,
+  DROP CONSTRAINT IF EXISTS ck_posts_kind,
+  DROP COLUMN IF EXISTS quote_of_id,
+  DROP COLUMN IF EXISTS repost_of_id,
+  DROP COLUMN IF EXISTS kind;
@@ -0,0 +1,26 @@
+ALTER TABLE posts
+  ADD COLUMN IF NOT EXISTS kind varchar(10) NOT NULL DEFAULT 'post',
+  ADD COLUMN IF NOT EXISTS repost_of_id bigint,
+  ADD COLUMN IF NOT EXISTS quote_of_id bigint;
+
+ALTER TABLE posts
+  ADD CONSTRAINT ck_posts_kind CHECK (kind IN ('post', 'repost', 'quote')),
+  -- a repost is nothing without its original
+  ADD CONSTRAINT ck_posts_repost CHECK ((kind = 'repost') = (repost_of_id IS NOT NULL)),
+  ADD CONSTRAINT ck_posts_quote CHECK (kind = 'quote' OR quote_of_id IS NULL),
+
+  ADD CONSTRAINT fk_posts_repost_of
+    FOREIGN KEY (repost_of_id)
+    REFERENCES posts (id)
+    ON DELETE CASCADE,
+
+  -- a quote outlives its original and shows a tombstone instead
+  ADD CONSTRAINT fk_posts_quote_of
+    FOREIGN KEY (quote_of_id)
+    REFERENCES posts (id)
+    ON DELETE SET NULL;
+
+-- one repost of a post per user
+CREATE UNIQUE INDEX IF NOT EXISTS idx_posts_user_repost_of ON posts (user_id, repost_of_id) WHERE repost_of_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_posts_repost_of_id ON posts (repost_of_id) WHERE repost_of_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_posts_quote_of_id ON posts (quote_of_id) WHERE quote_of_id IS NOT NULL;
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_posts_user_unpublished;
+DROP INDEX IF EXISTS idx_posts_scheduled_publish_at;
+
+ALTER TABLE posts
+  DROP CONSTRAINT IF EXISTS ck_posts_publish_at,
+  DROP CONSTRAINT IF EXISTS ck_posts_status,
+  DROP COLUMN IF EXISTS publish_at,
+  DROP COLUMN IF EXISTS status;
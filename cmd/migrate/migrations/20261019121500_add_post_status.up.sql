@@ -0,0 +1,12 @@
+ALTER TABLE posts
+  ADD COLUMN IF NOT EXISTS status varchar(10) NOT NULL DEFAULT 'published',
+  ADD COLUMN IF NOT EXISTS publish_at timestamp(0) with time zone;
+
+ALTER TABLE posts
+  ADD CONSTRAINT ck_posts_status CHECK (status IN ('draft', 'scheduled', 'published')),
+  ADD CONSTRAINT ck_posts_publish_at CHECK (status <> 'scheduled' OR publish_at IS NOT NULL);
+
+-- the scheduler looks for due posts
+CREATE INDEX IF NOT EXISTS idx_posts_scheduled_publish_at ON posts (publish_at) WHERE status = 'scheduled';
+-- an author's drafts listing
+CREATE INDEX IF NOT EXISTS idx_posts_user_unpublished ON posts (user_id, updated_at DESC) WHERE status <> 'published';
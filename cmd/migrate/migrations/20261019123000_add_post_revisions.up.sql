@@ -0,0 +1,19 @@
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS edited_at timestamp(0) with time zone;
+
+-- the title and content a post had before each edit; version is the post
+-- version the text belonged to
+CREATE TABLE IF NOT EXISTS post_revisions (
+  id bigserial PRIMARY KEY,
+  post_id bigint NOT NULL,
+  version int NOT NULL,
+  title varchar(255) NOT NULL,
+  content text NOT NULL,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+  CONSTRAINT fk_post_revisions_post
+    FOREIGN KEY (post_id)
+    REFERENCES posts (id)
+    ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_post_revisions_post_version ON post_revisions (post_id, version);
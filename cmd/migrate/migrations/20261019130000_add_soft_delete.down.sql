@@ -0,0 +1,17 @@
+-- without deleted_at, trashed rows would come back
+DELETE FROM comments WHERE deleted_at IS NOT NULL OR post_id IN (SELECT id FROM posts WHERE deleted_at IS NOT NULL);
+DELETE FROM posts WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_posts_user_repost_of;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_posts_user_repost_of ON posts (user_id, repost_of_id) WHERE repost_of_id IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_comments_deleted_at;
+DROP INDEX IF EXISTS idx_posts_deleted_at;
+
+ALTER TABLE comments
+  DROP COLUMN IF EXISTS deleted_by_id,
+  DROP COLUMN IF EXISTS deleted_at;
+
+ALTER TABLE posts
+  DROP COLUMN IF EXISTS deleted_by_id,
+  DROP COLUMN IF EXISTS deleted_at;
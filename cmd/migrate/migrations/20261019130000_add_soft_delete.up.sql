@@ -0,0 +1,15 @@
+ALTER TABLE posts
+  ADD COLUMN IF NOT EXISTS deleted_at timestamp(0) with time zone,
+  ADD COLUMN IF NOT EXISTS deleted_by_id bigint;
+
+ALTER TABLE comments
+  ADD COLUMN IF NOT EXISTS deleted_at timestamp(0) with time zone,
+  ADD COLUMN IF NOT EXISTS deleted_by_id bigint;
+
+-- trash listings and the retention purge
+CREATE INDEX IF NOT EXISTS idx_posts_deleted_at ON posts (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_comments_deleted_at ON comments (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- a repost in the trash doesn't stop reposting again
+DROP INDEX IF EXISTS idx_posts_user_repost_of;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_posts_user_repost_of ON posts (user_id, repost_of_id) WHERE repost_of_id IS NOT NULL AND deleted_at IS NULL;
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_followers_follower_id;
+
+DROP TRIGGER IF EXISTS followers_count ON followers;
+DROP FUNCTION IF EXISTS count_followers();
+
+ALTER TABLE users DROP COLUMN IF EXISTS followers_count;
@@ -0,0 +1,21 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS followers_count bigint NOT NULL DEFAULT 0;
+
+UPDATE users u SET followers_count = (SELECT COUNT(*) FROM followers f WHERE f.user_id = u.id);
+
+-- kept by a trigger so follows removed by cascading user deletes count too
+CREATE OR REPLACE FUNCTION count_followers() RETURNS trigger AS $$
+BEGIN
+  IF TG_OP = 'INSERT' THEN
+    UPDATE users SET followers_count = followers_count + 1 WHERE id = NEW.user_id;
+  ELSE
+    UPDATE users SET followers_count = followers_count - 1 WHERE id = OLD.user_id;
+  END IF;
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS followers_count ON followers;
+CREATE TRIGGER followers_count AFTER INSERT OR DELETE ON followers
+  FOR EACH ROW EXECUTE FUNCTION count_followers();
+
+CREATE INDEX IF NOT EXISTS idx_followers_follower_id ON followers (follower_id);
@@ -0,0 +1,6 @@
+ALTER TABLE
+  users
+ADD
+  COLUMN totp_failures INT NOT NULL DEFAULT 0,
+ADD
+  COLUMN totp_failed_at timestamp(0) with time zone;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_posts_announce_pending;
+
+ALTER TABLE posts DROP COLUMN IF EXISTS announce_pending;
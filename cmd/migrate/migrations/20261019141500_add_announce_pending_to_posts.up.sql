@@ -0,0 +1,5 @@
+-- set when a scheduled post is published and cleared once its mentions
+-- notified and it went onto timelines, so the job can retry in between
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS announce_pending BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS idx_posts_announce_pending ON posts (id) WHERE announce_pending;
@@ -0,0 +1,19 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+)
+
+// AccessTokenPrefix marks personal access tokens so they can be told
+// apart from JWTs without parsing them.
+const AccessTokenPrefix = "gsp_"
+
+// GenerateAccessToken returns a new random personal access token.
+func GenerateAccessToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return AccessTokenPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// KeySetAuthenticator signs tokens with one active asymmetric key and
+// verifies them against every loaded key, picked by the kid header.
+type KeySetAuthenticator struct {
+	active  *SigningKey
+	keys    map[string]*SigningKey
+	methods []string
+	aud     string
+	iss     string
+}
+
+func NewKeySetAuthenticator(keys []*SigningKey, activeKID, aud, iss string) (*KeySetAuthenticator, error) {
+	a := &KeySetAuthenticator{
+		keys: make(map[string]*SigningKey, len(keys)),
+		aud:  aud,
+		iss:  iss,
+	}
+
+	seen := map[string]bool{}
+	for _, k := range keys {
+		if _, dup := a.keys[k.ID]; dup {
+			return nil, fmt.Errorf("duplicate key id %q", k.ID)
+		}
+		a.keys[k.ID] = k
+
+		if alg := k.Method.Alg(); !seen[alg] {
+			seen[alg] = true
+			a.methods = append(a.methods, alg)
+		}
+	}
+
+	active, ok := a.keys[activeKID]
+	if !ok {
+		return nil, fmt.Errorf("active key %q not found", activeKID)
+	}
+	if active.Private == nil {
+		return nil, fmt.Errorf("active key %q has no private key", activeKID)
+	}
+	a.active = active
+
+	return a, nil
+}
+
+func (a *KeySetAuthenticator) GenerateToken(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(a.active.Method, claims)
+	token.Header["kid"] = a.active.ID
+
+	return token.SignedString(a.active.Private)
+}
+
+func (a *KeySetAuthenticator) ValidateToken(token string) (*jwt.Token, error) {
+	return jwt.Parse(token, a.keyFunc,
+		jwt.WithExpirationRequired(),
+		jwt.WithAudience(a.aud),
+		jwt.WithIssuer(a.iss),
+		jwt.WithValidMethods(a.methods),
+	)
+}
+
+func (a *KeySetAuthenticator) ValidateTokenAuth(tokenString string) (*jwt.Token, error) {
+	token, err := jwt.Parse(tokenString, a.keyFunc, jwt.WithValidMethods(a.methods))
+	if err != nil {
+		return nil, err
+	}
+
+	if !token.Valid {
+		return nil, fmt.Errorf("invalid token")
+	}
+
+	return token, nil
+}
+
+// JWKS lists the public half of every key, including retired ones that
+// are still accepted for verification.
+func (a *KeySetAuthenticator) JWKS() JWKSet {
+	kids := make([]string, 0, len(a.keys))
+	for kid := range a.keys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	set := JWKSet{Keys: make([]JWK, 0, len(kids))}
+	for _, kid := range kids {
+		if jwk, ok := newJWK(a.keys[kid]); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	return set
+}
+
+func (a *KeySetAuthenticator) keyFunc(t *jwt.Token) (any, error) {
+	kid, _ := t.Header["kid"].(string)
+	if kid == "" {
+		return nil, errors.New("token has no kid header")
+	}
+
+	key, ok := a.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	if t.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %v for key %q", t.Header["alg"], kid)
+	}
+
+	return key.Public, nil
+}
@@ -0,0 +1,123 @@
+package auth_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/pangdfg/gopher-social/internal/auth"
+)
+
+var _ = Describe("Key set authenticator", func() {
+	const aud = "gophersocial"
+
+	var previous, next ed25519.PrivateKey
+
+	// signingKey loads key as kid the way LoadKeyDir does, keeping only the
+	// public half for a retired key.
+	signingKey := func(kid string, key ed25519.PrivateKey, publicOnly bool) *auth.SigningKey {
+		var block *pem.Block
+		if publicOnly {
+			der, err := x509.MarshalPKIXPublicKey(key.Public())
+			Expect(err).NotTo(HaveOccurred())
+			block = &pem.Block{Type: "PUBLIC KEY", Bytes: der}
+		} else {
+			der, err := x509.MarshalPKCS8PrivateKey(key)
+			Expect(err).NotTo(HaveOccurred())
+			block = &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+		}
+
+		k, err := auth.ParsePEMKey(kid, pem.EncodeToMemory(block))
+		Expect(err).NotTo(HaveOccurred())
+		return k
+	}
+
+	keySet := func(activeKID string, keys ...*auth.SigningKey) *auth.KeySetAuthenticator {
+		a, err := auth.NewKeySetAuthenticator(keys, activeKID, aud, aud)
+		Expect(err).NotTo(HaveOccurred())
+		return a
+	}
+
+	token := func(a auth.Authenticator) string {
+		t, err := a.GenerateToken(jwt.MapClaims{
+			"sub": 1,
+			"aud": aud,
+			"iss": aud,
+			"exp": time.Now().Add(time.Hour).Unix(),
+		})
+		Expect(err).NotTo(HaveOccurred())
+		return t
+	}
+
+	BeforeEach(func() {
+		var err error
+		_, previous, err = ed25519.GenerateKey(rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		_, next, err = ed25519.GenerateKey(rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("signs with the active key and names it in the kid header", func() {
+		a := keySet("2026-11", signingKey("2026-10", previous, false), signingKey("2026-11", next, false))
+
+		parsed, err := a.ValidateToken(token(a))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(parsed.Header["kid"]).To(Equal("2026-11"))
+		Expect(parsed.Header["alg"]).To(Equal("EdDSA"))
+	})
+
+	It("still verifies tokens signed with the previous key during the overlap", func() {
+		before := keySet("2026-10", signingKey("2026-10", previous, false))
+		issued := token(before)
+
+		after := keySet("2026-11", signingKey("2026-10", previous, true), signingKey("2026-11", next, false))
+		_, err := after.ValidateToken(issued)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("rejects tokens once the previous key is removed", func() {
+		issued := token(keySet("2026-10", signingKey("2026-10", previous, false)))
+
+		after := keySet("2026-11", signingKey("2026-11", next, false))
+		_, err := after.ValidateToken(issued)
+		Expect(err).To(MatchError(ContainSubstring(`unknown key id "2026-10"`)))
+	})
+
+	It("rejects a token whose kid names a key that didn't sign it", func() {
+		forged := token(keySet("2026-11", signingKey("2026-11", previous, false)))
+
+		a := keySet("2026-11", signingKey("2026-11", next, false))
+		_, err := a.ValidateToken(forged)
+		Expect(err).To(MatchError(jwt.ErrTokenSignatureInvalid))
+	})
+
+	It("won't sign with a public key", func() {
+		_, err := auth.NewKeySetAuthenticator([]*auth.SigningKey{signingKey("2026-10", previous, true)}, "2026-10", aud, aud)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("publishes every key, retired ones included", func() {
+		a := keySet("2026-11", signingKey("2026-10", previous, true), signingKey("2026-11", next, false))
+
+		set := a.JWKS()
+		Expect(set.Keys).To(HaveLen(2))
+		for i, kid := range []string{"2026-10", "2026-11"} {
+			key := []ed25519.PrivateKey{previous, next}[i]
+			Expect(set.Keys[i]).To(Equal(auth.JWK{
+				Kty: "OKP",
+				Kid: kid,
+				Use: "sig",
+				Alg: "EdDSA",
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(key.Public().(ed25519.PublicKey)),
+			}))
+		}
+	})
+})
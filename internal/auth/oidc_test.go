@@ -0,0 +1,175 @@
+package auth_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/pangdfg/gopher-social/internal/auth"
+)
+
+// mockIssuer is a minimal OpenID Connect provider: discovery, JWKS and a
+// token endpoint that checks the PKCE verifier for the one code it issued.
+type mockIssuer struct {
+	server    *httptest.Server
+	key       *rsa.PrivateKey
+	challenge string
+	nonce     string
+	claims    jwt.MapClaims
+}
+
+func newMockIssuer() *mockIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	Expect(err).NotTo(HaveOccurred())
+
+	m := &mockIssuer{key: key}
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 m.server.URL,
+			"authorization_endpoint": m.server.URL + "/authorize",
+			"token_endpoint":         m.server.URL + "/token",
+			"jwks_uri":               m.server.URL + "/jwks",
+		})
+	})
+
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		signing, err := auth.ParsePEMKey("mock", encodePrivateKey(m.key))
+		Expect(err).NotTo(HaveOccurred())
+
+		a, err := auth.NewKeySetAuthenticator([]*auth.SigningKey{signing}, "mock", "", "")
+		Expect(err).NotTo(HaveOccurred())
+		json.NewEncoder(w).Encode(a.JWKS())
+	})
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		id, secret, _ := r.BasicAuth()
+		if r.Form.Get("code") != "good-code" || id != "client" || secret != "s3cret" ||
+			auth.PKCEChallenge(r.Form.Get("code_verifier")) != m.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		claims := jwt.MapClaims{
+			"iss":   m.server.URL,
+			"aud":   "client",
+			"sub":   "user-123",
+			"nonce": m.nonce,
+			"exp":   time.Now().Add(time.Minute).Unix(),
+			"iat":   time.Now().Unix(),
+		}
+		for k, v := range m.claims {
+			claims[k] = v
+		}
+
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "mock"
+		signed, err := token.SignedString(m.key)
+		Expect(err).NotTo(HaveOccurred())
+
+		json.NewEncoder(w).Encode(map[string]string{"id_token": signed, "access_token": "at"})
+	})
+
+	m.server = httptest.NewServer(mux)
+	return m
+}
+
+// authorize plays the browser leg: it records the PKCE challenge and nonce
+// from the authorization URL the provider would have been sent to.
+func (m *mockIssuer) authorize(authURL string) {
+	u, err := url.Parse(authURL)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(u.Path).To(Equal("/authorize"))
+	Expect(u.Query().Get("code_challenge_method")).To(Equal("S256"))
+
+	m.challenge = u.Query().Get("code_challenge")
+	m.nonce = u.Query().Get("nonce")
+}
+
+var _ = Describe("OIDCProvider", func() {
+	var (
+		issuer   *mockIssuer
+		provider *auth.OIDCProvider
+		ctx      context.Context
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		issuer = newMockIssuer()
+		provider = auth.NewOIDCProvider(auth.OIDCConfig{
+			Name:         "mock",
+			Issuer:       issuer.server.URL,
+			ClientID:     "client",
+			ClientSecret: "s3cret",
+			RedirectURL:  "http://localhost/callback",
+		}, issuer.server.Client())
+	})
+
+	AfterEach(func() {
+		issuer.server.Close()
+	})
+
+	It("completes the code flow with PKCE and returns the verified identity", func() {
+		issuer.claims = jwt.MapClaims{"email": "gopher@example.com", "email_verified": true, "preferred_username": "gopher"}
+
+		authURL, err := provider.AuthCodeURL(ctx, "state", "nonce-1", "verifier-1")
+		Expect(err).NotTo(HaveOccurred())
+		issuer.authorize(authURL)
+
+		id, err := provider.Exchange(ctx, "good-code", "verifier-1", "nonce-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(id.Subject).To(Equal("user-123"))
+		Expect(id.Email).To(Equal("gopher@example.com"))
+		Expect(id.EmailVerified).To(BeTrue())
+		Expect(id.PreferredUsername).To(Equal("gopher"))
+	})
+
+	It("rejects a code redeemed with the wrong verifier", func() {
+		authURL, err := provider.AuthCodeURL(ctx, "state", "nonce-1", "verifier-1")
+		Expect(err).NotTo(HaveOccurred())
+		issuer.authorize(authURL)
+
+		_, err = provider.Exchange(ctx, "good-code", "other-verifier", "nonce-1")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("rejects an ID token with a different nonce", func() {
+		authURL, err := provider.AuthCodeURL(ctx, "state", "nonce-1", "verifier-1")
+		Expect(err).NotTo(HaveOccurred())
+		issuer.authorize(authURL)
+
+		_, err = provider.Exchange(ctx, "good-code", "verifier-1", "nonce-2")
+		Expect(err).To(MatchError(ContainSubstring("nonce")))
+	})
+
+	It("treats a string email_verified claim as a boolean", func() {
+		issuer.claims = jwt.MapClaims{"email": "gopher@example.com", "email_verified": "false"}
+
+		authURL, err := provider.AuthCodeURL(ctx, "state", "nonce-1", "verifier-1")
+		Expect(err).NotTo(HaveOccurred())
+		issuer.authorize(authURL)
+
+		id, err := provider.Exchange(ctx, "good-code", "verifier-1", "nonce-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(id.EmailVerified).To(BeFalse())
+	})
+})
+
+func encodePrivateKey(key *rsa.PrivateKey) []byte {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	Expect(err).NotTo(HaveOccurred())
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
@@ -0,0 +1,69 @@
+package auth_test
+
+import (
+	"encoding/base32"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/pangdfg/gopher-social/internal/auth"
+)
+
+var _ = Describe("TOTP", func() {
+	// the SHA-1 seed of the RFC 6238 test vectors
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+	// The RFC lists eight digit codes; six digit codes are their last six.
+	DescribeTable("matches the RFC 6238 test vectors",
+		func(unix int64, rfcCode string) {
+			code, err := auth.TOTPCode(secret, time.Unix(unix, 0))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(code).To(Equal(rfcCode[2:]))
+		},
+		Entry(nil, int64(59), "94287082"),
+		Entry(nil, int64(1111111109), "07081804"),
+		Entry(nil, int64(1111111111), "14050471"),
+		Entry(nil, int64(1234567890), "89005924"),
+		Entry(nil, int64(2000000000), "69279037"),
+		Entry(nil, int64(20000000000), "65353130"),
+	)
+
+	It("accepts codes one step either side and returns their step", func() {
+		now := time.Unix(1111111109, 0)
+		current := now.Unix() / 30
+
+		for _, offset := range []int64{-1, 0, 1} {
+			code, err := auth.TOTPCode(secret, now.Add(time.Duration(offset)*30*time.Second))
+			Expect(err).NotTo(HaveOccurred())
+
+			step, ok := auth.ValidateTOTP(secret, code, now)
+			Expect(ok).To(BeTrue())
+			Expect(step).To(Equal(current + offset))
+		}
+	})
+
+	It("refuses codes two steps away", func() {
+		now := time.Unix(1111111109, 0)
+
+		for _, offset := range []time.Duration{-2, 2} {
+			code, err := auth.TOTPCode(secret, now.Add(offset*30*time.Second))
+			Expect(err).NotTo(HaveOccurred())
+
+			_, ok := auth.ValidateTOTP(secret, code, now)
+			Expect(ok).To(BeFalse())
+		}
+	})
+
+	It("refuses malformed codes and secrets", func() {
+		now := time.Unix(59, 0)
+
+		_, ok := auth.ValidateTOTP(secret, "2870820", now)
+		Expect(ok).To(BeFalse())
+		_, ok = auth.ValidateTOTP("not base32!", "287082", now)
+		Expect(ok).To(BeFalse())
+
+		_, ok = auth.ValidateTOTP(secret, " 287082 ", now)
+		Expect(ok).To(BeTrue())
+	})
+})
@@ -17,6 +17,8 @@ func NewGorm(addr string, maxOpenConns, maxIdleConns int, maxIdleTime string, de
 
 	gormConfig := &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Silent), // silent by default
+		// map unique violations to gorm.ErrDuplicatedKey for the stores
+		TranslateError: true,
 	}
 
 	if debug {
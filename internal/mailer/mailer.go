@@ -6,6 +6,9 @@ const (
 	FromName            = "GopherSocial"
 	maxRetires          = 3
 	UserWelcomeTemplate = "user_invitation.tmpl"
+	EmailChangeTemplate = "email_change_confirm.tmpl"
+	EmailChangedNoticeTemplate = "email_changed_notice.tmpl"
+	UserMentionTemplate = "user_mention.tmpl"
 )
 
 //go:embed templates/*.tmpl
@@ -0,0 +1,115 @@
+package media
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	_ "image/png"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// maxPixels bounds decoding so a tiny file claiming huge dimensions can't
+// exhaust memory.
+const maxPixels = 50_000_000
+
+// VariantSpec is a named size an upload is rendered at. MaxSize bounds the
+// longer edge; images are never upscaled.
+type VariantSpec struct {
+	Name    string
+	MaxSize int
+}
+
+var DefaultVariants = []VariantSpec{
+	{Name: "thumbnail", MaxSize: 320},
+	{Name: "medium", MaxSize: 1024},
+	{Name: "full", MaxSize: 2048},
+}
+
+// VariantContentType is the format variants are encoded in. There is no
+// pure Go WebP encoder, and we don't want cgo in the build.
+const VariantContentType = "image/jpeg"
+
+type Variant struct {
+	Name   string
+	Width  int
+	Height int
+	Data   []byte
+}
+
+type Processed struct {
+	// Width and Height are of the upright image.
+	Width    int
+	Height   int
+	Blurhash string
+	Variants []Variant
+}
+
+// Process decodes an uploaded image, turns it upright and renders every
+// spec as a JPEG along with a blurhash placeholder.
+func Process(data []byte, orientation int, specs []VariantSpec) (*Processed, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return nil, fmt.Errorf("image of %dx%d is too large to process", cfg.Width, cfg.Height)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	img = applyOrientation(img, orientation)
+
+	res := &Processed{
+		Width:  img.Bounds().Dx(),
+		Height: img.Bounds().Dy(),
+	}
+
+	for _, spec := range specs {
+		scaled := resize(img, spec.MaxSize, draw.CatmullRom)
+
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, scaled, &jpeg.Options{Quality: 82}); err != nil {
+			return nil, err
+		}
+
+		res.Variants = append(res.Variants, Variant{
+			Name:   spec.Name,
+			Width:  scaled.Bounds().Dx(),
+			Height: scaled.Bounds().Dy(),
+			Data:   buf.Bytes(),
+		})
+	}
+
+	res.Blurhash, err = Blurhash(resize(img, 32, draw.ApproxBiLinear), 4, 3)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// resize scales img so its longer edge is at most maxSize, onto a white
+// background since JPEG has no alpha.
+func resize(img image.Image, maxSize int, scaler draw.Scaler) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	if w > maxSize || h > maxSize {
+		if w >= h {
+			w, h = maxSize, max(1, h*maxSize/w)
+		} else {
+			w, h = max(1, w*maxSize/h), maxSize
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	scaler.Scale(dst, dst.Bounds(), img, b, draw.Over, nil)
+	return dst
+}
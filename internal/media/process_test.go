@@ -0,0 +1,77 @@
+package media_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/pangdfg/gopher-social/internal/media"
+)
+
+func encodePNG(w, h int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.RGBA{200, 40, 40, 255}), image.Point{}, draw.Src)
+
+	var buf bytes.Buffer
+	Expect(png.Encode(&buf, img)).To(Succeed())
+	return buf.Bytes()
+}
+
+var _ = Describe("Blurhash", func() {
+	It("matches the reference encoder for a white image", func() {
+		white := image.NewRGBA(image.Rect(0, 0, 4, 4))
+		draw.Draw(white, white.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+
+		hash, err := media.Blurhash(white, 4, 3)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(hash).To(Equal("L~TSUA~qfQ~q~q%MfQ%MfQfQfQfQ"))
+	})
+})
+
+var _ = Describe("Process", func() {
+	It("renders every variant without upscaling", func() {
+		res, err := media.Process(encodePNG(1600, 800), 1, media.DefaultVariants)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.Width).To(Equal(1600))
+		Expect(res.Height).To(Equal(800))
+		Expect(res.Blurhash).NotTo(BeEmpty())
+
+		Expect(res.Variants).To(HaveLen(3))
+		sizes := map[string][2]int{}
+		for _, v := range res.Variants {
+			sizes[v.Name] = [2]int{v.Width, v.Height}
+			_, err := jpeg.Decode(bytes.NewReader(v.Data))
+			Expect(err).NotTo(HaveOccurred())
+		}
+		Expect(sizes["thumbnail"]).To(Equal([2]int{320, 160}))
+		Expect(sizes["medium"]).To(Equal([2]int{1024, 512}))
+		Expect(sizes["full"]).To(Equal([2]int{1600, 800}))
+	})
+
+	It("applies EXIF rotation", func() {
+		res, err := media.Process(encodePNG(40, 20), 6, media.DefaultVariants[:1])
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.Width).To(Equal(20))
+		Expect(res.Height).To(Equal(40))
+	})
+})
+
+var _ = Describe("Orientation", func() {
+	It("reads the orientation tag from EXIF", func() {
+		var buf bytes.Buffer
+		Expect(jpeg.Encode(&buf, testImage(), nil)).To(Succeed())
+
+		// big endian TIFF with a single IFD0 entry: orientation = 6
+		exif := []byte("Exif\x00\x00MM\x00\x2a\x00\x00\x00\x08\x00\x01\x01\x12\x00\x03\x00\x00\x00\x01\x00\x06\x00\x00\x00\x00\x00\x00")
+		data := withSegment(buf.Bytes(), 0xE1, exif)
+
+		Expect(media.Orientation("image/jpeg", data)).To(Equal(6))
+		Expect(media.Orientation("image/jpeg", buf.Bytes())).To(Equal(1))
+	})
+})
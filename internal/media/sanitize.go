@@ -0,0 +1,210 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net/http"
+)
+
+var (
+	ErrUnsupportedType = errors.New("unsupported media type")
+	ErrMalformed       = errors.New("malformed image")
+)
+
+// allowedTypes maps the image types we accept to their file extension.
+var allowedTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+}
+
+// Sniff detects the content type from the file's leading bytes and rejects
+// anything that is not an accepted image. The client supplied type is never
+// trusted.
+func Sniff(data []byte) (contentType, ext string, err error) {
+	contentType = http.DetectContentType(data)
+	ext, ok := allowedTypes[contentType]
+	if !ok {
+		return "", "", ErrUnsupportedType
+	}
+	return contentType, ext, nil
+}
+
+// StripMetadata removes EXIF, XMP, comments and text chunks without
+// re-encoding, so pixel data is untouched. Colour profiles are kept, and so
+// is a JPEG's EXIF orientation, written back on its own so the original
+// still displays upright.
+func StripMetadata(contentType string, data []byte) ([]byte, error) {
+	switch contentType {
+	case "image/jpeg":
+		return stripJPEG(data)
+	case "image/png":
+		return stripPNG(data)
+	case "image/webp":
+		return stripWebP(data)
+	default:
+		return nil, ErrUnsupportedType
+	}
+}
+
+func stripJPEG(data []byte) ([]byte, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, ErrMalformed
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, 0xFF, 0xD8)
+	if o := Orientation("image/jpeg", data); o > 1 {
+		out = append(out, orientationSegment(o)...)
+	}
+
+	i := 2
+	for i < len(data) {
+		if data[i] != 0xFF {
+			return nil, ErrMalformed
+		}
+		// markers may be preceded by fill bytes
+		for i < len(data) && data[i] == 0xFF {
+			i++
+		}
+		if i >= len(data) {
+			return nil, ErrMalformed
+		}
+		marker := data[i]
+		i++
+
+		switch {
+		case marker == 0xD9: // EOI
+			return append(out, 0xFF, marker), nil
+		case marker >= 0xD0 && marker <= 0xD7, marker == 0x01:
+			out = append(out, 0xFF, marker)
+			continue
+		}
+
+		if i+2 > len(data) {
+			return nil, ErrMalformed
+		}
+		n := int(binary.BigEndian.Uint16(data[i:]))
+		if n < 2 || i+n > len(data) {
+			return nil, ErrMalformed
+		}
+		segment := data[i : i+n]
+
+		if marker == 0xDA {
+			// start of scan: entropy coded data and any later scans follow
+			out = append(out, 0xFF, marker)
+			return append(out, data[i:]...), nil
+		}
+
+		if keepJPEGSegment(marker, segment[2:]) {
+			out = append(out, 0xFF, marker)
+			out = append(out, segment...)
+		}
+		i += n
+	}
+
+	return nil, ErrMalformed
+}
+
+// keepJPEGSegment drops APPn and COM segments except JFIF (APP0), ICC
+// profiles (APP2) and the Adobe colour transform (APP14), which decoders
+// need to render the image correctly.
+func keepJPEGSegment(marker byte, payload []byte) bool {
+	switch {
+	case marker == 0xFE:
+		return false
+	case marker == 0xE0, marker == 0xEE:
+		return true
+	case marker == 0xE2:
+		return bytes.HasPrefix(payload, []byte("ICC_PROFILE\x00"))
+	case marker >= 0xE1 && marker <= 0xEF:
+		return false
+	default:
+		return true
+	}
+}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+var pngMetadataChunks = map[string]bool{
+	"tEXt": true,
+	"zTXt": true,
+	"iTXt": true,
+	"eXIf": true,
+	"tIME": true,
+}
+
+func stripPNG(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, ErrMalformed
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, pngSignature...)
+
+	i := len(pngSignature)
+	for i+8 <= len(data) {
+		n := int(binary.BigEndian.Uint32(data[i:]))
+		typ := string(data[i+4 : i+8])
+		end := i + 12 + n
+		if n < 0 || end > len(data) {
+			return nil, ErrMalformed
+		}
+
+		if !pngMetadataChunks[typ] {
+			out = append(out, data[i:end]...)
+		}
+		if typ == "IEND" {
+			return out, nil
+		}
+		i = end
+	}
+
+	return nil, ErrMalformed
+}
+
+// VP8X feature flags announcing EXIF and XMP chunks.
+const (
+	webpFlagXMP  = 0x04
+	webpFlagEXIF = 0x08
+)
+
+func stripWebP(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, ErrMalformed
+	}
+
+	out := make([]byte, 12, len(data))
+	copy(out, data[:12])
+
+	i := 12
+	for i+8 <= len(data) {
+		fourcc := string(data[i : i+4])
+		n := int(binary.LittleEndian.Uint32(data[i+4:]))
+		end := i + 8 + n + n%2
+		if end > len(data) {
+			// tolerate a missing pad byte on the final chunk
+			if i+8+n != len(data) {
+				return nil, ErrMalformed
+			}
+			end = len(data)
+		}
+
+		switch fourcc {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			start := len(out)
+			out = append(out, data[i:end]...)
+			if n > 0 {
+				out[start+8] &^= webpFlagEXIF | webpFlagXMP
+			}
+		default:
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	return out, nil
+}
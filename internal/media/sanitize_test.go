@@ -0,0 +1,135 @@
+package media_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/pangdfg/gopher-social/internal/media"
+)
+
+func testImage() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for x := 0; x < 8; x++ {
+		for y := 0; y < 8; y++ {
+			img.Set(x, y, color.RGBA{uint8(x * 30), uint8(y * 30), 90, 255})
+		}
+	}
+	return img
+}
+
+// withSegment inserts a JPEG marker segment right after SOI.
+func withSegment(data []byte, marker byte, payload []byte) []byte {
+	n := len(payload) + 2
+	seg := append([]byte{0xFF, marker, byte(n >> 8), byte(n)}, payload...)
+	out := append([]byte{}, data[:2]...)
+	out = append(out, seg...)
+	return append(out, data[2:]...)
+}
+
+// rotatedJPEG is a 16x8 JPEG whose EXIF says to display it with
+// orientation, followed by data that must not survive stripping.
+func rotatedJPEG(orientation byte) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 16, 8))
+	var buf bytes.Buffer
+	Expect(jpeg.Encode(&buf, img, nil)).To(Succeed())
+
+	// big endian TIFF with a single IFD0 entry for the orientation
+	exif := []byte("Exif\x00\x00MM\x00\x2a\x00\x00\x00\x08\x00\x01\x01\x12\x00\x03\x00\x00\x00\x01\x00")
+	exif = append(exif, orientation)
+	exif = append(exif, []byte("\x00\x00\x00\x00\x00\x00GPS-SECRET")...)
+	return withSegment(buf.Bytes(), 0xE1, exif)
+}
+
+var _ = Describe("Sniff", func() {
+	It("rejects non images whatever the client claims", func() {
+		_, _, err := media.Sniff([]byte("<html><body>hi</body></html>"))
+		Expect(err).To(MatchError(media.ErrUnsupportedType))
+	})
+
+	It("detects PNG", func() {
+		var buf bytes.Buffer
+		Expect(png.Encode(&buf, testImage())).To(Succeed())
+
+		ct, ext, err := media.Sniff(buf.Bytes())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ct).To(Equal("image/png"))
+		Expect(ext).To(Equal(".png"))
+	})
+})
+
+var _ = Describe("StripMetadata", func() {
+	It("drops EXIF and comments from JPEG but keeps the ICC profile", func() {
+		var buf bytes.Buffer
+		Expect(jpeg.Encode(&buf, testImage(), nil)).To(Succeed())
+		clean := buf.Bytes()
+
+		dirty := withSegment(clean, 0xE1, []byte("Exif\x00\x00GPS-SECRET"))
+		dirty = withSegment(dirty, 0xFE, []byte("a comment"))
+		dirty = withSegment(dirty, 0xE2, []byte("ICC_PROFILE\x00\x01\x01profile"))
+
+		out, err := media.StripMetadata("image/jpeg", dirty)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).NotTo(ContainSubstring("GPS-SECRET"))
+		Expect(out).NotTo(ContainSubstring("a comment"))
+		Expect(out).To(ContainSubstring("ICC_PROFILE"))
+
+		_, err = jpeg.Decode(bytes.NewReader(out))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("keeps only the orientation of a rotated JPEG's EXIF", func() {
+		out, err := media.StripMetadata("image/jpeg", rotatedJPEG(6))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).NotTo(ContainSubstring("GPS-SECRET"))
+		Expect(media.Orientation("image/jpeg", out)).To(Equal(6))
+
+		cfg, err := jpeg.DecodeConfig(bytes.NewReader(out))
+		Expect(err).NotTo(HaveOccurred())
+		Expect([]int{cfg.Width, cfg.Height}).To(Equal([]int{16, 8}))
+
+		res, err := media.Process(out, media.Orientation("image/jpeg", out), media.DefaultVariants[:1])
+		Expect(err).NotTo(HaveOccurred())
+		Expect([]int{res.Width, res.Height}).To(Equal([]int{8, 16}))
+	})
+
+	It("writes no EXIF back for an upright JPEG", func() {
+		out, err := media.StripMetadata("image/jpeg", rotatedJPEG(1))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).NotTo(ContainSubstring("Exif"))
+	})
+
+	It("drops text chunks from PNG without changing pixels", func() {
+		var buf bytes.Buffer
+		Expect(png.Encode(&buf, testImage())).To(Succeed())
+		data := buf.Bytes()
+
+		// tEXt chunk with a dummy CRC placed after IHDR (8 + 25 bytes)
+		text := []byte("\x00\x00\x00\x0atEXtAuthor\x00Bob\x00\x00\x00\x00")
+		dirty := append(append(append([]byte{}, data[:33]...), text...), data[33:]...)
+
+		out, err := media.StripMetadata("image/png", dirty)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).To(Equal(data))
+	})
+
+	It("drops EXIF from WebP and clears the VP8X flag", func() {
+		vp8x := []byte("VP8X\x0a\x00\x00\x00\x08\x00\x00\x00\x07\x00\x00\x07\x00\x00")
+		exif := []byte("EXIF\x03\x00\x00\x00abc\x00")
+		body := append([]byte("WEBP"), vp8x...)
+		body = append(body, exif...)
+		riff := append([]byte("RIFF\x00\x00\x00\x00"), body...)
+		riff[4] = byte(len(body))
+
+		out, err := media.StripMetadata("image/webp", riff)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).NotTo(ContainSubstring("EXIF"))
+		Expect(out[20] & 0x08).To(BeZero())
+		Expect(int(out[4])).To(Equal(len(out) - 8))
+	})
+})
@@ -0,0 +1,78 @@
+package richtext
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// MaxTagRunes bounds a normalized tag name.
+const MaxTagRunes = 50
+
+var tagFolder = cases.Fold()
+
+// Hashtags finds #hashtags. Like mentions they must not follow a word
+// character, so "C#" or URL fragments after a word are not tags. Value is
+// the tag as written, without the '#'.
+func Hashtags(text string) []Entity {
+	var out []Entity
+
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '#' || (i > 0 && isTagRune(runes[i-1])) {
+			continue
+		}
+
+		end := i + 1
+		for end < len(runes) && isTagRune(runes[end]) {
+			end++
+		}
+
+		value := string(runes[i+1 : end])
+		// "#1" is a number, not a topic
+		if end > i+1 && strings.IndexFunc(value, isTagLetter) >= 0 {
+			out = append(out, Entity{Start: i, End: end, Value: value})
+		}
+		i = end - 1
+	}
+
+	return out
+}
+
+// NormalizeTag returns the canonical name of a tag: NFKC normalized, case
+// folded, without a leading '#'. Tags so compare equal regardless of case
+// or compatibility forms such as full-width letters. It returns false for
+// names that are empty, too long or contain anything but letters, marks,
+// digits and underscores.
+func NormalizeTag(s string) (string, bool) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "#")
+	s = norm.NFKC.String(tagFolder.String(norm.NFKC.String(s)))
+
+	if s == "" || len([]rune(s)) > MaxTagRunes {
+		return "", false
+	}
+	for _, r := range s {
+		if !isTagRune(r) {
+			return "", false
+		}
+	}
+	if strings.IndexFunc(s, isTagLetter) < 0 {
+		return "", false
+	}
+	return s, true
+}
+
+// DisplayTag is the form a new tag is shown in: NFKC normalized, case kept.
+func DisplayTag(s string) string {
+	return norm.NFKC.String(strings.TrimPrefix(strings.TrimSpace(s), "#"))
+}
+
+func isTagRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsMark(r) || unicode.IsDigit(r)
+}
+
+func isTagLetter(r rune) bool {
+	return unicode.IsLetter(r)
+}
@@ -0,0 +1,38 @@
+package richtext_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/pangdfg/gopher-social/internal/richtext"
+)
+
+var _ = Describe("Hashtags", func() {
+	It("finds hashtags but not numbers or mid-word sharps", func() {
+		Expect(richtext.Hashtags("#Go is fun, C# is #1, see #golang_tips.")).To(Equal([]richtext.Entity{
+			{Start: 0, End: 3, Value: "Go"},
+			{Start: 26, End: 38, Value: "golang_tips"},
+		}))
+	})
+})
+
+var _ = Describe("NormalizeTag", func() {
+	DescribeTable("canonical names",
+		func(in, want string) {
+			got, ok := richtext.NormalizeTag(in)
+			Expect(ok).To(BeTrue())
+			Expect(got).To(Equal(want))
+		},
+		Entry("case", "GoLang", "golang"),
+		Entry("leading sharp", "#Go", "go"),
+		Entry("full-width letters", "Ｇｏ", "go"),
+		Entry("sharp s folds", "Straße", "strasse"),
+	)
+
+	It("rejects invalid names", func() {
+		for _, in := range []string{"", "#", "two words", "123", "a-b"} {
+			_, ok := richtext.NormalizeTag(in)
+			Expect(ok).To(BeFalse(), in)
+		}
+	})
+})
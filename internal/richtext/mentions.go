@@ -0,0 +1,52 @@
+// Package richtext extracts entities such as @mentions from user text.
+package richtext
+
+import "unicode"
+
+// Entity is a span of text. Start and End are rune offsets into the text,
+// End exclusive, and include the leading sigil.
+type Entity struct {
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	Value string `json:"value"`
+}
+
+// maxUsernameRunes matches the username length limit on registration.
+const maxUsernameRunes = 100
+
+// Mentions finds @username mentions. A mention must start the text or
+// follow a character that can't be part of a word, so e-mail addresses are
+// skipped, and trailing dots or dashes are treated as punctuation.
+func Mentions(text string) []Entity {
+	var out []Entity
+
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '@' || (i > 0 && isNameRune(runes[i-1])) {
+			continue
+		}
+
+		end := i + 1
+		for end < len(runes) && isNameRune(runes[end]) {
+			end++
+		}
+		for end > i+1 && (runes[end-1] == '.' || runes[end-1] == '-') {
+			end--
+		}
+
+		if n := end - i - 1; n > 0 && n <= maxUsernameRunes {
+			out = append(out, Entity{
+				Start: i,
+				End:   end,
+				Value: string(runes[i+1 : end]),
+			})
+		}
+		i = end - 1
+	}
+
+	return out
+}
+
+func isNameRune(r rune) bool {
+	return r == '_' || r == '.' || r == '-' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
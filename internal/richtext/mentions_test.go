@@ -0,0 +1,27 @@
+package richtext_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/pangdfg/gopher-social/internal/richtext"
+)
+
+var _ = Describe("Mentions", func() {
+	It("finds mentions with rune offsets", func() {
+		Expect(richtext.Mentions("héllo @alice and @bob_2!")).To(Equal([]richtext.Entity{
+			{Start: 6, End: 12, Value: "alice"},
+			{Start: 17, End: 23, Value: "bob_2"},
+		}))
+	})
+
+	It("treats trailing dots and dashes as punctuation", func() {
+		Expect(richtext.Mentions("thanks @j.doe.")).To(Equal([]richtext.Entity{
+			{Start: 7, End: 13, Value: "j.doe"},
+		}))
+	})
+
+	It("ignores e-mail addresses and bare sigils", func() {
+		Expect(richtext.Mentions("mail me at me@example.com @ noon")).To(BeEmpty())
+	})
+})
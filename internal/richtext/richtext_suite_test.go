@@ -0,0 +1,13 @@
+package richtext_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestRichtext(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Richtext Suite")
+}
@@ -0,0 +1,109 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// AccessToken is a user-managed personal access token for scripts and
+// integrations. Only the SHA-256 of the token is stored.
+type AccessToken struct {
+	ID         uint           `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID     uint           `json:"user_id"`
+	Name       string         `gorm:"size:100" json:"name"`
+	Prefix     string         `gorm:"size:16" json:"prefix"`
+	TokenHash  string         `gorm:"uniqueIndex" json:"-"`
+	Scopes     pq.StringArray `gorm:"type:text[]" json:"scopes"`
+	ExpiresAt  *time.Time     `json:"expires_at"`
+	LastUsedAt *time.Time     `json:"last_used_at"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
+
+func (AccessToken) TableName() string {
+	return "personal_access_tokens"
+}
+
+// accessTokenTouchInterval throttles last_used_at writes for busy tokens.
+const accessTokenTouchInterval = time.Minute
+
+type AccessTokenStore struct {
+	db *gorm.DB
+}
+
+func NewAccessTokenStore(db *gorm.DB) *AccessTokenStore {
+	return &AccessTokenStore{db: db}
+}
+
+func (s *AccessTokenStore) Create(ctx context.Context, token *AccessToken, plain string) error {
+	token.TokenHash = hashAccessToken(plain)
+	return s.db.WithContext(ctx).Create(token).Error
+}
+
+// GetByToken looks up an unexpired token by its plain text value.
+func (s *AccessTokenStore) GetByToken(ctx context.Context, plain string) (*AccessToken, error) {
+	token := &AccessToken{}
+	err := s.db.WithContext(ctx).
+		Where("token_hash = ? AND (expires_at IS NULL OR expires_at > ?)", hashAccessToken(plain), time.Now()).
+		First(token).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return token, nil
+}
+
+func (s *AccessTokenStore) GetByUserID(ctx context.Context, userID uint) ([]AccessToken, error) {
+	var tokens []AccessToken
+	err := s.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("created_at desc").
+		Find(&tokens).Error
+	if err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// Delete revokes a token owned by userID.
+func (s *AccessTokenStore) Delete(ctx context.Context, userID, tokenID uint) error {
+	tx := s.db.WithContext(ctx).
+		Where("id = ? AND user_id = ?", tokenID, userID).
+		Delete(&AccessToken{})
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Touch records that the token was just used, at most once per interval.
+func (s *AccessTokenStore) Touch(ctx context.Context, token *AccessToken) error {
+	now := time.Now()
+	if token.LastUsedAt != nil && now.Sub(*token.LastUsedAt) < accessTokenTouchInterval {
+		return nil
+	}
+
+	err := s.db.WithContext(ctx).Model(&AccessToken{}).
+		Where("id = ?", token.ID).
+		Update("last_used_at", now).Error
+	if err != nil {
+		return err
+	}
+
+	token.LastUsedAt = &now
+	return nil
+}
+
+func hashAccessToken(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
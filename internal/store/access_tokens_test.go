@@ -0,0 +1,56 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("AccessTokenStore", func() {
+	var (
+		ctx    context.Context
+		tokens *AccessTokenStore
+		user   *User
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		tx := testDB()
+		tokens = &AccessTokenStore{db: tx}
+		user = seedUser(tx, "gopher")
+	})
+
+	create := func(plain string, expiresAt *time.Time) *AccessToken {
+		token := &AccessToken{UserID: user.ID, Name: plain, Scopes: []string{"read"}, ExpiresAt: expiresAt}
+		Expect(tokens.Create(ctx, token, plain)).To(Succeed())
+		return token
+	}
+
+	It("finds a live token by its plain text", func() {
+		later := time.Now().Add(time.Hour)
+		created := create("gsp_live", &later)
+
+		token, err := tokens.GetByToken(ctx, "gsp_live")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(token.ID).To(Equal(created.ID))
+		Expect([]string(token.Scopes)).To(Equal([]string{"read"}))
+	})
+
+	It("doesn't find an expired token", func() {
+		earlier := time.Now().Add(-time.Minute)
+		create("gsp_expired", &earlier)
+
+		_, err := tokens.GetByToken(ctx, "gsp_expired")
+		Expect(err).To(MatchError(ErrNotFound))
+	})
+
+	It("doesn't find a revoked token", func() {
+		token := create("gsp_revoked", nil)
+		Expect(tokens.Delete(ctx, user.ID, token.ID)).To(Succeed())
+
+		_, err := tokens.GetByToken(ctx, "gsp_revoked")
+		Expect(err).To(MatchError(ErrNotFound))
+	})
+})
@@ -0,0 +1,201 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Bookmark is a post a user saved, optionally into one of their
+// collections. Bookmarks are private to the user.
+type Bookmark struct {
+	UserID       uint      `gorm:"primaryKey" json:"user_id"`
+	PostID       uint      `gorm:"primaryKey" json:"post_id"`
+	CollectionID *uint     `json:"collection_id"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type BookmarkCollection struct {
+	ID             uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID         uint      `json:"user_id"`
+	Name           string    `gorm:"size:100" json:"name"`
+	BookmarksCount int64     `gorm:"->;-:migration" json:"bookmarks_count"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+type BookmarkStore struct {
+	db *gorm.DB
+}
+
+func NewBookmarkStore(db *gorm.DB) *BookmarkStore {
+	return &BookmarkStore{db: db}
+}
+
+// Add bookmarks a post, or moves an existing bookmark to b.CollectionID
+// keeping its time. It returns ErrNotFound if the post or the user's
+// collection doesn't exist.
+func (s *BookmarkStore) Add(ctx context.Context, b *Bookmark) error {
+	db := s.db.WithContext(ctx)
+
+	if b.CollectionID != nil {
+		var count int64
+		err := db.Model(&BookmarkCollection{}).
+			Where("id = ? AND user_id = ?", *b.CollectionID, b.UserID).
+			Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return ErrNotFound
+		}
+	}
+
+	err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "post_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"collection_id"}),
+	}).Create(b).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrForeignKeyViolated) {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// Remove returns ErrNotFound if the post wasn't bookmarked.
+func (s *BookmarkStore) Remove(ctx context.Context, userID, postID uint) error {
+	tx := s.db.WithContext(ctx).
+		Where("user_id = ? AND post_id = ?", userID, postID).
+		Delete(&Bookmark{})
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetPosts lists bookmarked posts by bookmark time, limited to a collection
+// when collectionID is set. Posts that are gone are simply not listed.
+func (s *BookmarkStore) GetPosts(ctx context.Context, userID uint, collectionID *uint, fq PaginatedFeedQuery) ([]Post, error) {
+	var posts []Post
+
+	query := s.db.WithContext(ctx).
+		Preload("User", authorColumns).
+		Preload("User.Role").
+		Preload("Tags").
+		Preload("Attachments", orderedAttachments).
+		Preload("Mentions", orderedMentions).
+		Scopes(withShares, published).
+		Joins("JOIN bookmarks b ON b.post_id = posts.id AND b.user_id = ?", userID)
+
+	if collectionID != nil {
+		query = query.Where("b.collection_id = ?", *collectionID)
+	}
+
+	if fq.Search != "" {
+		query = query.Where("title ILIKE ? OR content ILIKE ?", "%"+fq.Search+"%", "%"+fq.Search+"%")
+	}
+
+	if fq.Sort == "" {
+		fq.Sort = "desc"
+	}
+
+	err := query.
+		Order("b.created_at " + fq.Sort).
+		Order("posts.id " + fq.Sort).
+		Limit(fq.Limit).
+		Offset(fq.Offset).
+		Find(&posts).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
+
+// GetBookmarked returns which of postIDs the user bookmarked.
+func (s *BookmarkStore) GetBookmarked(ctx context.Context, userID uint, postIDs []uint) (map[uint]bool, error) {
+	out := make(map[uint]bool)
+	if userID == 0 || len(postIDs) == 0 {
+		return out, nil
+	}
+
+	var ids []uint
+	err := s.db.WithContext(ctx).
+		Model(&Bookmark{}).
+		Where("user_id = ? AND post_id IN ?", userID, postIDs).
+		Pluck("post_id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		out[id] = true
+	}
+	return out, nil
+}
+
+// CreateCollection returns ErrConflict if the user has a collection with
+// the same name.
+func (s *BookmarkStore) CreateCollection(ctx context.Context, col *BookmarkCollection) error {
+	if err := s.db.WithContext(ctx).Create(col).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return ErrConflict
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *BookmarkStore) GetCollections(ctx context.Context, userID uint) ([]BookmarkCollection, error) {
+	var cols []BookmarkCollection
+	err := s.db.WithContext(ctx).
+		Select("bookmark_collections.*, (SELECT COUNT(*) FROM bookmarks b WHERE b.collection_id = bookmark_collections.id) AS bookmarks_count").
+		Where("user_id = ?", userID).
+		Order("name").
+		Find(&cols).Error
+	if err != nil {
+		return nil, err
+	}
+	return cols, nil
+}
+
+// RenameCollection returns ErrNotFound if the user has no such collection
+// and ErrConflict if the name is taken.
+func (s *BookmarkStore) RenameCollection(ctx context.Context, col *BookmarkCollection) error {
+	tx := s.db.WithContext(ctx).
+		Model(&BookmarkCollection{}).
+		Where("id = ? AND user_id = ?", col.ID, col.UserID).
+		Updates(map[string]interface{}{"name": col.Name, "updated_at": time.Now()})
+	if tx.Error != nil {
+		if errors.Is(tx.Error, gorm.ErrDuplicatedKey) {
+			return ErrConflict
+		}
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteCollection keeps the bookmarks in it, outside any collection.
+func (s *BookmarkStore) DeleteCollection(ctx context.Context, userID, id uint) error {
+	tx := s.db.WithContext(ctx).
+		Where("id = ? AND user_id = ?", id, userID).
+		Delete(&BookmarkCollection{})
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"strconv"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"github.com/pangdfg/gopher-social/internal/store"
+)
+
+const (
+	postTTL = 2 * time.Minute
+	userTTL = 15 * time.Minute
+	// notFoundTTL is how long a lookup that found nothing is remembered;
+	// short, as creating the row doesn't always evict it.
+	notFoundTTL = 30 * time.Second
+)
+
+// NewCachingStorage serves post and user lookups by ID from c, loading
+// them from s on a miss, and evicts them on writes as
+// NewInvalidatingStorage does. Concurrent misses for one ID share a
+// single load, and the cache failing falls back to s.
+func NewCachingStorage(s store.Storage, c Storage) store.Storage {
+	s = NewInvalidatingStorage(s, c)
+	s.Posts = &cachingPosts{Posts: s.Posts, cache: c.PostCache}
+	s.Users = &cachingUsers{Users: s.Users, cache: c.UserCache}
+	return s
+}
+
+type cachingPosts struct {
+	store.Posts
+	cache PostCache
+	group singleflight.Group
+}
+
+func (s *cachingPosts) GetByID(ctx context.Context, id uint) (*store.Post, error) {
+	post, err := s.cache.Get(ctx, id)
+	switch {
+	case err == nil:
+		return post, nil
+	case errors.Is(err, store.ErrNotFound):
+		return nil, err
+	}
+
+	v, err, _ := s.group.Do(strconv.FormatUint(uint64(id), 10), func() (any, error) {
+		ctx := context.WithoutCancel(ctx)
+		post, err := s.Posts.GetByID(ctx, id)
+		switch {
+		case err == nil:
+			s.cache.Set(ctx, post, jitter(postTTL))
+		case errors.Is(err, store.ErrNotFound):
+			s.cache.SetNotFound(ctx, id, jitter(notFoundTTL))
+		}
+		return post, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// callers sharing a load each get their own copy to change
+	shared := *v.(*store.Post)
+	return &shared, nil
+}
+
+// cachingUsers caches GetByID only. Cached users leave out the password
+// hash and TOTP state, so GetCredentials always reads s.
+type cachingUsers struct {
+	store.Users
+	cache UserCache
+	group singleflight.Group
+}
+
+func (s *cachingUsers) GetByID(ctx context.Context, id uint) (*store.User, error) {
+	user, err := s.cache.Get(ctx, id)
+	switch {
+	case err == nil:
+		return user, nil
+	case errors.Is(err, store.ErrNotFound):
+		return nil, err
+	}
+
+	v, err, _ := s.group.Do(strconv.FormatUint(uint64(id), 10), func() (any, error) {
+		ctx := context.WithoutCancel(ctx)
+		user, err := s.Users.GetByID(ctx, id)
+		switch {
+		case err == nil:
+			s.cache.Set(ctx, user, jitter(userTTL))
+		case errors.Is(err, store.ErrNotFound):
+			s.cache.SetNotFound(ctx, id, jitter(notFoundTTL))
+		}
+		return user, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	shared := *v.(*store.User)
+	return &shared, nil
+}
+
+// jitter adds up to a tenth to ttl so entries cached together don't all
+// expire together.
+func jitter(ttl time.Duration) time.Duration {
+	return ttl + rand.N(ttl/10)
+}
@@ -0,0 +1,166 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/pangdfg/gopher-social/internal/store"
+	"github.com/pangdfg/gopher-social/internal/store/cache"
+)
+
+// loadingPosts counts GetByID loads. Posts it doesn't hold are not found,
+// and a non-nil release holds every load until it is closed.
+type loadingPosts struct {
+	store.Posts
+	posts   map[uint]*store.Post
+	loads   atomic.Int32
+	release chan struct{}
+}
+
+func (f *loadingPosts) GetByID(_ context.Context, id uint) (*store.Post, error) {
+	f.loads.Add(1)
+	if f.release != nil {
+		<-f.release
+	}
+	post, ok := f.posts[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return post, nil
+}
+
+func (f *loadingPosts) Create(_ context.Context, post *store.Post) error {
+	f.posts[post.ID] = post
+	return nil
+}
+
+type loadingUsers struct {
+	store.Users
+	user  *store.User
+	loads int
+}
+
+func (f *loadingUsers) GetByID(context.Context, uint) (*store.User, error) {
+	f.loads++
+	return f.user, nil
+}
+
+func (f *loadingUsers) GetCredentials(context.Context, uint) (*store.User, error) {
+	f.loads++
+	return f.user, nil
+}
+
+var _ = Describe("Caching storage", func() {
+	var (
+		ctx   context.Context
+		db    *loadingPosts
+		users *loadingUsers
+		posts *fakePostCache
+		s     store.Storage
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		db = &loadingPosts{posts: map[uint]*store.Post{1: {ID: 1, Title: "hello"}}}
+		users = &loadingUsers{user: &store.User{ID: 2, Username: "gopher", Password: []byte("hash"), TOTPSecret: "totp"}}
+		posts = newFakePostCache()
+		s = cache.NewCachingStorage(store.Storage{Posts: db, Users: users}, cache.Storage{
+			PostCache: posts,
+			UserCache: newFakeUserCache(),
+		})
+	})
+
+	It("loads a missing post once and then serves it from the cache", func() {
+		for range 3 {
+			post, err := s.Posts.GetByID(ctx, 1)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(post.Title).To(Equal("hello"))
+		}
+
+		Expect(db.loads.Load()).To(BeEquivalentTo(1))
+		Expect(posts.ttls).To(HaveLen(1))
+		Expect(posts.ttls[0]).To(BeNumerically(">=", 2*time.Minute))
+		Expect(posts.ttls[0]).To(BeNumerically("<", 2*time.Minute+12*time.Second))
+	})
+
+	It("remembers briefly that a post wasn't found", func() {
+		for range 2 {
+			_, err := s.Posts.GetByID(ctx, 5)
+			Expect(err).To(MatchError(store.ErrNotFound))
+		}
+
+		Expect(db.loads.Load()).To(BeEquivalentTo(1))
+		Expect(posts.ttls).To(HaveLen(1))
+		Expect(posts.ttls[0]).To(BeNumerically("<", time.Minute))
+	})
+
+	It("forgets a not found post once it is created", func() {
+		_, err := s.Posts.GetByID(ctx, 5)
+		Expect(err).To(MatchError(store.ErrNotFound))
+
+		Expect(s.Posts.Create(ctx, &store.Post{ID: 5})).To(Succeed())
+		post, err := s.Posts.GetByID(ctx, 5)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(post.ID).To(BeEquivalentTo(5))
+	})
+
+	It("falls back to the store when the cache fails", func() {
+		posts.err = errors.New("connection refused")
+
+		post, err := s.Posts.GetByID(ctx, 1)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(post.ID).To(BeEquivalentTo(1))
+		Expect(db.loads.Load()).To(BeEquivalentTo(1))
+	})
+
+	It("collapses concurrent misses into one load", func() {
+		db.release = make(chan struct{})
+
+		const callers = 8
+		results := make([]*store.Post, callers)
+		var wg sync.WaitGroup
+		for i := range callers {
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+				post, err := s.Posts.GetByID(ctx, 1)
+				Expect(err).NotTo(HaveOccurred())
+				results[i] = post
+			}()
+		}
+
+		Eventually(db.loads.Load).Should(BeEquivalentTo(1))
+		time.Sleep(50 * time.Millisecond)
+		close(db.release)
+		wg.Wait()
+
+		Expect(db.loads.Load()).To(BeEquivalentTo(1))
+		results[0].Title = "changed"
+		for _, post := range results[1:] {
+			Expect(post.Title).To(Equal("hello"))
+		}
+	})
+
+	It("reads credentials past the cache", func() {
+		cached, err := s.Users.GetByID(ctx, 2)
+		Expect(err).NotTo(HaveOccurred())
+		cached, err = s.Users.GetByID(ctx, 2)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cached.Username).To(Equal("gopher"))
+		Expect(cached.Password).To(BeEmpty())
+		Expect(users.loads).To(Equal(1))
+
+		user, err := s.Users.GetCredentials(ctx, 2)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(user.Password).To(Equal([]byte("hash")))
+		Expect(user.TOTPSecret).To(Equal("totp"))
+		Expect(users.loads).To(Equal(2))
+	})
+})
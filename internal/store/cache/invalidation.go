@@ -0,0 +1,381 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/pangdfg/gopher-social/internal/store"
+)
+
+// NewInvalidatingStorage wraps s so that every write evicts the cached
+// posts, users and timelines it makes stale, and handlers never have to.
+// Reads and the stores nothing is cached for pass straight through.
+func NewInvalidatingStorage(s store.Storage, c Storage) store.Storage {
+	s.Posts = &invalidatingPosts{Posts: s.Posts, cache: c.PostCache}
+	s.Comments = &invalidatingComments{Comments: s.Comments, posts: c.PostCache}
+	s.Users = &invalidatingUsers{Users: s.Users, cache: c.UserCache, posts: c.PostCache}
+	s.Media = &invalidatingMedia{Media: s.Media, posts: c.PostCache}
+	s.Mentions = &invalidatingMentions{Mentions: s.Mentions, comments: s.Comments, posts: c.PostCache}
+	s.Tags = &invalidatingTags{Tags: s.Tags, posts: c.PostCache}
+	s.Identities = &invalidatingIdentities{Identities: s.Identities, users: c.UserCache}
+	if c.Timelines != nil {
+		s.Followers = &invalidatingFollowers{Followers: s.Followers, timelines: c.Timelines}
+	}
+	return s
+}
+
+type invalidatingPosts struct {
+	store.Posts
+	cache PostCache
+}
+
+// Create evicts the new post, in case a lookup cached it as not found,
+// and the quoted post, whose quote count changed.
+func (s *invalidatingPosts) Create(ctx context.Context, post *store.Post) error {
+	if err := s.Posts.Create(ctx, post); err != nil {
+		return err
+	}
+	s.cache.Delete(ctx, post.ID)
+	if post.QuoteOfID != nil {
+		s.cache.Delete(ctx, *post.QuoteOfID)
+	}
+	return nil
+}
+
+func (s *invalidatingPosts) Update(ctx context.Context, post *store.Post) error {
+	if err := s.Posts.Update(ctx, post); err != nil {
+		return err
+	}
+	s.cache.Delete(ctx, post.ID)
+	return nil
+}
+
+func (s *invalidatingPosts) SetTags(ctx context.Context, postID uint, tags []store.Tag) error {
+	if err := s.Posts.SetTags(ctx, postID, tags); err != nil {
+		return err
+	}
+	s.cache.Delete(ctx, postID)
+	return nil
+}
+
+func (s *invalidatingPosts) Delete(ctx context.Context, id uint, version int, deletedByID uint) error {
+	return s.evictShared(ctx, []uint{id}, func() error { return s.Posts.Delete(ctx, id, version, deletedByID) })
+}
+
+func (s *invalidatingPosts) Purge(ctx context.Context, id uint) error {
+	return s.evictShared(ctx, []uint{id}, func() error { return s.Posts.Purge(ctx, id) })
+}
+
+func (s *invalidatingPosts) Restore(ctx context.Context, id uint) error {
+	return s.evictShared(ctx, []uint{id}, func() error { return s.Posts.Restore(ctx, id) })
+}
+
+func (s *invalidatingPosts) PurgeDeleted(ctx context.Context, cutoff time.Time, limit int) (int64, error) {
+	ids, err := s.Posts.GetPurgeable(ctx, cutoff, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	var purged int64
+	err = s.evictShared(ctx, ids, func() error {
+		purged, err = s.Posts.PurgeDeleted(ctx, cutoff, limit)
+		return err
+	})
+	return purged, err
+}
+
+// evictShared evicts the posts along with their reposts and quotes, which
+// show the original, once write went through. The shares are looked up
+// before the write, which may delete them or take the original off them.
+func (s *invalidatingPosts) evictShared(ctx context.Context, ids []uint, write func() error) error {
+	shares, err := s.Posts.GetShareIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	if err := write(); err != nil {
+		return err
+	}
+	for _, id := range append(ids, shares...) {
+		s.cache.Delete(ctx, id)
+	}
+	return nil
+}
+
+func (s *invalidatingPosts) PublishDue(ctx context.Context, now time.Time, limit int) ([]uint, error) {
+	ids, err := s.Posts.PublishDue(ctx, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		s.cache.Delete(ctx, id)
+	}
+	return ids, nil
+}
+
+// Repost evicts the repost and the original, whose repost count changed.
+func (s *invalidatingPosts) Repost(ctx context.Context, userID, postID uint) (*store.Post, error) {
+	repost, err := s.Posts.Repost(ctx, userID, postID)
+	if err != nil {
+		return nil, err
+	}
+	s.cache.Delete(ctx, repost.ID)
+	s.cache.Delete(ctx, postID)
+	return repost, nil
+}
+
+func (s *invalidatingPosts) Unrepost(ctx context.Context, userID, postID uint) error {
+	if err := s.Posts.Unrepost(ctx, userID, postID); err != nil {
+		return err
+	}
+	s.cache.Delete(ctx, postID)
+	return nil
+}
+
+// invalidatingComments evicts the post a comment is on; cached posts carry
+// their comments.
+type invalidatingComments struct {
+	store.Comments
+	posts PostCache
+}
+
+func (s *invalidatingComments) Create(ctx context.Context, c *store.Comment) error {
+	if err := s.Comments.Create(ctx, c); err != nil {
+		return err
+	}
+	s.posts.Delete(ctx, c.PostID)
+	return nil
+}
+
+func (s *invalidatingComments) Delete(ctx context.Context, id, deletedByID uint) error {
+	comment, err := s.Comments.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.Comments.Delete(ctx, id, deletedByID); err != nil {
+		return err
+	}
+	s.posts.Delete(ctx, comment.PostID)
+	return nil
+}
+
+func (s *invalidatingComments) Restore(ctx context.Context, id uint) error {
+	comment, err := s.Comments.GetDeleted(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.Comments.Restore(ctx, id); err != nil {
+		return err
+	}
+	s.posts.Delete(ctx, comment.PostID)
+	return nil
+}
+
+// invalidatingUsers evicts the user, and for changes to what posts show of
+// them, the posts they show up on; cached posts carry their author,
+// commenters and mentioned users.
+type invalidatingUsers struct {
+	store.Users
+	cache UserCache
+	posts PostCache
+}
+
+func (s *invalidatingUsers) Activate(ctx context.Context, userID uint) error {
+	return s.evict(ctx, userID, s.Users.Activate(ctx, userID))
+}
+
+func (s *invalidatingUsers) UpdateUsername(ctx context.Context, user *store.User) error {
+	return s.evictShown(ctx, user.ID, func() error { return s.Users.UpdateUsername(ctx, user) })
+}
+
+func (s *invalidatingUsers) UpdateEmail(ctx context.Context, user *store.User) error {
+	return s.evict(ctx, user.ID, s.Users.UpdateEmail(ctx, user))
+}
+
+func (s *invalidatingUsers) UpdateProfile(ctx context.Context, user *store.User) error {
+	return s.evictShown(ctx, user.ID, func() error { return s.Users.UpdateProfile(ctx, user) })
+}
+
+func (s *invalidatingUsers) UpdatePassword(ctx context.Context, user *store.User, plain string) error {
+	return s.evict(ctx, user.ID, s.Users.UpdatePassword(ctx, user, plain))
+}
+
+func (s *invalidatingUsers) Delete(ctx context.Context, id uint) error {
+	return s.evictShown(ctx, id, func() error { return s.Users.Delete(ctx, id) })
+}
+
+func (s *invalidatingUsers) RequestDeletion(ctx context.Context, userID uint, at time.Time) error {
+	return s.evict(ctx, userID, s.Users.RequestDeletion(ctx, userID, at))
+}
+
+func (s *invalidatingUsers) CancelDeletion(ctx context.Context, userID uint) error {
+	return s.evict(ctx, userID, s.Users.CancelDeletion(ctx, userID))
+}
+
+func (s *invalidatingUsers) SetTOTPSecret(ctx context.Context, userID uint, secret string) error {
+	return s.evict(ctx, userID, s.Users.SetTOTPSecret(ctx, userID, secret))
+}
+
+func (s *invalidatingUsers) EnableTOTP(ctx context.Context, userID uint) error {
+	return s.evict(ctx, userID, s.Users.EnableTOTP(ctx, userID))
+}
+
+func (s *invalidatingUsers) DisableTOTP(ctx context.Context, userID uint) error {
+	return s.evict(ctx, userID, s.Users.DisableTOTP(ctx, userID))
+}
+
+func (s *invalidatingUsers) ConsumeTOTPStep(ctx context.Context, userID uint, step int64) error {
+	return s.evict(ctx, userID, s.Users.ConsumeTOTPStep(ctx, userID, step))
+}
+
+// evict drops the user from the cache once err shows the write went
+// through, and passes err on.
+func (s *invalidatingUsers) evict(ctx context.Context, userID uint, err error) error {
+	if err == nil {
+		s.cache.Delete(ctx, userID)
+	}
+	return err
+}
+
+// evictShown evicts the user and the posts that show them once write went
+// through. The posts are looked up before the write, which may delete them.
+func (s *invalidatingUsers) evictShown(ctx context.Context, userID uint, write func() error) error {
+	ids, err := s.Users.GetPostIDs(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.evict(ctx, userID, write()); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		s.posts.Delete(ctx, id)
+	}
+	return nil
+}
+
+// invalidatingIdentities evicts users signing up through a provider, who
+// are active, and so found by ID, from the start.
+type invalidatingIdentities struct {
+	store.Identities
+	users UserCache
+}
+
+func (s *invalidatingIdentities) Provision(ctx context.Context, user *store.User, plain string, identity *store.UserIdentity) error {
+	if err := s.Identities.Provision(ctx, user, plain, identity); err != nil {
+		return err
+	}
+	s.users.Delete(ctx, user.ID)
+	return nil
+}
+
+// invalidatingFollowers drops the follower's timeline when they follow or
+// unfollow someone, to be rebuilt with or without that user's posts.
+type invalidatingFollowers struct {
+	store.Followers
+	timelines Timelines
+}
+
+func (s *invalidatingFollowers) Follow(ctx context.Context, followerID, userID uint) error {
+	if err := s.Followers.Follow(ctx, followerID, userID); err != nil {
+		return err
+	}
+	s.timelines.Delete(ctx, followerID)
+	return nil
+}
+
+func (s *invalidatingFollowers) Unfollow(ctx context.Context, followerID, userID uint) error {
+	if err := s.Followers.Unfollow(ctx, followerID, userID); err != nil {
+		return err
+	}
+	s.timelines.Delete(ctx, followerID)
+	return nil
+}
+
+// invalidatingMedia evicts the post attachments are on; cached posts
+// carry their attachments and variants.
+type invalidatingMedia struct {
+	store.Media
+	posts PostCache
+}
+
+func (s *invalidatingMedia) AttachToPost(ctx context.Context, userID, postID uint, items []store.MediaAttachment) error {
+	if err := s.Media.AttachToPost(ctx, userID, postID, items); err != nil {
+		return err
+	}
+	s.posts.Delete(ctx, postID)
+	return nil
+}
+
+func (s *invalidatingMedia) SaveProcessed(ctx context.Context, m *store.MediaAttachment, variants []store.MediaVariant) error {
+	if err := s.Media.SaveProcessed(ctx, m, variants); err != nil {
+		return err
+	}
+	if m.PostID != nil {
+		s.posts.Delete(ctx, *m.PostID)
+	}
+	return nil
+}
+
+// invalidatingMentions evicts the post mentions are made on; cached posts
+// carry their mentions and those of their comments.
+type invalidatingMentions struct {
+	store.Mentions
+	comments store.Comments
+	posts    PostCache
+}
+
+func (s *invalidatingMentions) ReplaceForPost(ctx context.Context, postID uint, mentions []store.Mention) ([]uint, error) {
+	added, err := s.Mentions.ReplaceForPost(ctx, postID, mentions)
+	if err != nil {
+		return nil, err
+	}
+	s.posts.Delete(ctx, postID)
+	return added, nil
+}
+
+func (s *invalidatingMentions) ReplaceForComment(ctx context.Context, commentID uint, mentions []store.Mention) ([]uint, error) {
+	comment, err := s.comments.GetByID(ctx, commentID)
+	if err != nil {
+		return nil, err
+	}
+	added, err := s.Mentions.ReplaceForComment(ctx, commentID, mentions)
+	if err != nil {
+		return nil, err
+	}
+	s.posts.Delete(ctx, comment.PostID)
+	return added, nil
+}
+
+// invalidatingTags evicts the posts on a tag, and reposts of them, when
+// the tag is renamed, merged away or deleted; cached posts carry their
+// tags. They are looked up before the write, which may take the tag off
+// them.
+type invalidatingTags struct {
+	store.Tags
+	posts PostCache
+}
+
+func (s *invalidatingTags) Update(ctx context.Context, tag *store.Tag) error {
+	return s.evictTagged(ctx, tag.ID, func() error { return s.Tags.Update(ctx, tag) })
+}
+
+func (s *invalidatingTags) Merge(ctx context.Context, sourceID, targetID uint) error {
+	return s.evictTagged(ctx, sourceID, func() error { return s.Tags.Merge(ctx, sourceID, targetID) })
+}
+
+func (s *invalidatingTags) Delete(ctx context.Context, id uint) error {
+	return s.evictTagged(ctx, id, func() error { return s.Tags.Delete(ctx, id) })
+}
+
+func (s *invalidatingTags) evictTagged(ctx context.Context, tagID uint, write func() error) error {
+	ids, err := s.Tags.GetPostIDs(ctx, tagID)
+	if err != nil {
+		return err
+	}
+	if err := write(); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		s.posts.Delete(ctx, id)
+	}
+	return nil
+}
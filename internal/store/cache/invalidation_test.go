@@ -0,0 +1,373 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/pangdfg/gopher-social/internal/store"
+	"github.com/pangdfg/gopher-social/internal/store/cache"
+)
+
+var errWrite = errors.New("write failed")
+
+// evictions records what was dropped from a fake cache.
+type evictions struct {
+	ids []uint
+}
+
+func (e *evictions) record(id uint) { e.ids = append(e.ids, id) }
+
+// fakePostCache holds posts in a map, a nil post standing for one cached
+// as not found. err makes every Get fail, as an unreachable Redis would.
+type fakePostCache struct {
+	evictions
+	mu    sync.Mutex
+	posts map[uint]*store.Post
+	ttls  []time.Duration
+	err   error
+}
+
+func newFakePostCache() *fakePostCache { return &fakePostCache{posts: map[uint]*store.Post{}} }
+
+func (f *fakePostCache) Get(_ context.Context, id uint) (*store.Post, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	post, ok := f.posts[id]
+	switch {
+	case !ok:
+		return nil, cache.ErrMiss
+	case post == nil:
+		return nil, store.ErrNotFound
+	}
+	cached := *post
+	return &cached, nil
+}
+
+func (f *fakePostCache) Set(_ context.Context, post *store.Post, ttl time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.posts[post.ID], f.ttls = post, append(f.ttls, ttl)
+	return nil
+}
+
+func (f *fakePostCache) SetNotFound(_ context.Context, id uint, ttl time.Duration) error {
+	f.posts[id], f.ttls = nil, append(f.ttls, ttl)
+	return nil
+}
+
+func (f *fakePostCache) Delete(_ context.Context, id uint) {
+	delete(f.posts, id)
+	f.record(id)
+}
+
+type fakeUserCache struct {
+	evictions
+	users map[uint]*store.User
+}
+
+func newFakeUserCache() *fakeUserCache { return &fakeUserCache{users: map[uint]*store.User{}} }
+
+func (f *fakeUserCache) Get(_ context.Context, id uint) (*store.User, error) {
+	user, ok := f.users[id]
+	switch {
+	case !ok:
+		return nil, cache.ErrMiss
+	case user == nil:
+		return nil, store.ErrNotFound
+	}
+	// like the Redis cache, copies leave the secrets out
+	cached := *user
+	cached.Password, cached.TOTPSecret = nil, ""
+	return &cached, nil
+}
+
+func (f *fakeUserCache) Set(_ context.Context, user *store.User, _ time.Duration) error {
+	f.users[user.ID] = user
+	return nil
+}
+
+func (f *fakeUserCache) SetNotFound(_ context.Context, id uint, _ time.Duration) error {
+	f.users[id] = nil
+	return nil
+}
+
+func (f *fakeUserCache) Delete(_ context.Context, id uint) {
+	delete(f.users, id)
+	f.record(id)
+}
+
+// fakeTimelines records the timelines dropped.
+type fakeTimelines struct {
+	cache.Timelines
+	evictions
+}
+
+func (f *fakeTimelines) Delete(_ context.Context, userID uint) { f.record(userID) }
+
+// The fake stores embed the interface they fake, so anything a test
+// doesn't expect to be called panics. err makes every write fail.
+// fakePosts has reposts or quotes 20 and 21 of every post and post 30 due
+// for purging.
+type fakePosts struct {
+	store.Posts
+	err error
+}
+
+func (f *fakePosts) Create(context.Context, *store.Post) error          { return f.err }
+func (f *fakePosts) Update(context.Context, *store.Post) error          { return f.err }
+func (f *fakePosts) SetTags(context.Context, uint, []store.Tag) error   { return f.err }
+func (f *fakePosts) Delete(context.Context, uint, int, uint) error      { return f.err }
+func (f *fakePosts) Purge(context.Context, uint) error                  { return f.err }
+func (f *fakePosts) Restore(context.Context, uint) error                { return f.err }
+func (f *fakePosts) Unrepost(context.Context, uint, uint) error         { return f.err }
+func (f *fakePosts) GetByID(context.Context, uint) (*store.Post, error) { return &store.Post{}, nil }
+func (f *fakePosts) Repost(_ context.Context, _, _ uint) (*store.Post, error) {
+	return &store.Post{ID: 99}, f.err
+}
+func (f *fakePosts) PublishDue(context.Context, time.Time, int) ([]uint, error) {
+	return []uint{4, 5}, f.err
+}
+func (f *fakePosts) PurgeDeleted(context.Context, time.Time, int) (int64, error) { return 1, f.err }
+func (f *fakePosts) GetPurgeable(context.Context, time.Time, int) ([]uint, error) {
+	return []uint{30}, nil
+}
+func (f *fakePosts) GetShareIDs(_ context.Context, ids []uint) ([]uint, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	return []uint{20, 21}, nil
+}
+
+type fakeComments struct {
+	store.Comments
+	err error
+}
+
+func (f *fakeComments) Create(context.Context, *store.Comment) error { return f.err }
+func (f *fakeComments) Delete(context.Context, uint, uint) error     { return f.err }
+func (f *fakeComments) Restore(context.Context, uint) error          { return f.err }
+func (f *fakeComments) GetByID(_ context.Context, id uint) (*store.Comment, error) {
+	return &store.Comment{ID: id, PostID: 7}, nil
+}
+func (f *fakeComments) GetDeleted(_ context.Context, id uint) (*store.Comment, error) {
+	return &store.Comment{ID: id, PostID: 7}, nil
+}
+
+// fakeUsers shows every user on posts 40 and 41.
+type fakeUsers struct {
+	store.Users
+	err error
+}
+
+func (f *fakeUsers) GetPostIDs(context.Context, uint) ([]uint, error) { return []uint{40, 41}, nil }
+
+func (f *fakeUsers) Activate(context.Context, uint) error                      { return f.err }
+func (f *fakeUsers) UpdateUsername(context.Context, *store.User) error         { return f.err }
+func (f *fakeUsers) UpdateEmail(context.Context, *store.User) error            { return f.err }
+func (f *fakeUsers) UpdateProfile(context.Context, *store.User) error          { return f.err }
+func (f *fakeUsers) UpdatePassword(context.Context, *store.User, string) error { return f.err }
+func (f *fakeUsers) Delete(context.Context, uint) error                        { return f.err }
+func (f *fakeUsers) RequestDeletion(context.Context, uint, time.Time) error    { return f.err }
+func (f *fakeUsers) CancelDeletion(context.Context, uint) error                { return f.err }
+func (f *fakeUsers) SetTOTPSecret(context.Context, uint, string) error         { return f.err }
+func (f *fakeUsers) EnableTOTP(context.Context, uint) error                    { return f.err }
+func (f *fakeUsers) DisableTOTP(context.Context, uint) error                   { return f.err }
+func (f *fakeUsers) ConsumeTOTPStep(context.Context, uint, int64) error        { return f.err }
+
+type fakeIdentities struct {
+	store.Identities
+	err error
+}
+
+func (f *fakeIdentities) Provision(context.Context, *store.User, string, *store.UserIdentity) error {
+	return f.err
+}
+
+type fakeFollowers struct {
+	store.Followers
+	err error
+}
+
+func (f *fakeFollowers) Follow(context.Context, uint, uint) error   { return f.err }
+func (f *fakeFollowers) Unfollow(context.Context, uint, uint) error { return f.err }
+
+type fakeMedia struct {
+	store.Media
+	err error
+}
+
+func (f *fakeMedia) AttachToPost(context.Context, uint, uint, []store.MediaAttachment) error {
+	return f.err
+}
+func (f *fakeMedia) SaveProcessed(context.Context, *store.MediaAttachment, []store.MediaVariant) error {
+	return f.err
+}
+
+type fakeMentions struct {
+	store.Mentions
+	err error
+}
+
+func (f *fakeMentions) ReplaceForPost(context.Context, uint, []store.Mention) ([]uint, error) {
+	return nil, f.err
+}
+func (f *fakeMentions) ReplaceForComment(context.Context, uint, []store.Mention) ([]uint, error) {
+	return nil, f.err
+}
+
+// fakeTags has posts 3 and 12 on every tag.
+type fakeTags struct {
+	store.Tags
+	err error
+}
+
+func (f *fakeTags) Update(context.Context, *store.Tag) error         { return f.err }
+func (f *fakeTags) Merge(context.Context, uint, uint) error          { return f.err }
+func (f *fakeTags) Delete(context.Context, uint) error               { return f.err }
+func (f *fakeTags) GetPostIDs(context.Context, uint) ([]uint, error) { return []uint{3, 12}, nil }
+
+var _ = Describe("Invalidating storage", func() {
+	var (
+		ctx       context.Context
+		posts     *fakePostCache
+		users     *fakeUserCache
+		timelines *fakeTimelines
+		s         store.Storage
+	)
+
+	newStorage := func(err error) store.Storage {
+		return cache.NewInvalidatingStorage(store.Storage{
+			Posts:      &fakePosts{err: err},
+			Comments:   &fakeComments{err: err},
+			Users:      &fakeUsers{err: err},
+			Identities: &fakeIdentities{err: err},
+			Followers:  &fakeFollowers{err: err},
+			Media:      &fakeMedia{err: err},
+			Mentions:   &fakeMentions{err: err},
+			Tags:       &fakeTags{err: err},
+		}, cache.Storage{PostCache: posts, UserCache: users, Timelines: timelines})
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		posts = newFakePostCache()
+		users = newFakeUserCache()
+		timelines = &fakeTimelines{}
+		s = newStorage(nil)
+	})
+
+	postID := uint(3)
+	quoted := uint(8)
+	mediaPost := uint(6)
+	user := &store.User{ID: 11}
+
+	DescribeTable("post writes evict the posts they change",
+		func(write func() error, evicted ...uint) {
+			Expect(write()).To(Succeed())
+			if len(evicted) == 0 {
+				Expect(posts.ids).To(BeEmpty())
+			} else {
+				Expect(posts.ids).To(Equal(evicted))
+			}
+			Expect(users.ids).To(BeEmpty())
+		},
+		Entry("creating a post", func() error { return s.Posts.Create(ctx, &store.Post{ID: 1}) }, uint(1)),
+		Entry("creating a quote", func() error {
+			return s.Posts.Create(ctx, &store.Post{ID: 1, QuoteOfID: &quoted})
+		}, uint(1), quoted),
+		Entry("updating", func() error { return s.Posts.Update(ctx, &store.Post{ID: postID}) }, postID),
+		Entry("retagging", func() error { return s.Posts.SetTags(ctx, postID, nil) }, postID),
+		Entry("deleting", func() error { return s.Posts.Delete(ctx, postID, 1, 1) }, postID, uint(20), uint(21)),
+		Entry("purging", func() error { return s.Posts.Purge(ctx, postID) }, postID, uint(20), uint(21)),
+		Entry("restoring", func() error { return s.Posts.Restore(ctx, postID) }, postID, uint(20), uint(21)),
+		Entry("emptying the trash", func() error {
+			_, err := s.Posts.PurgeDeleted(ctx, time.Now(), 10)
+			return err
+		}, uint(30), uint(20), uint(21)),
+		Entry("reposting", func() error { _, err := s.Posts.Repost(ctx, 1, postID); return err }, uint(99), postID),
+		Entry("undoing a repost", func() error { return s.Posts.Unrepost(ctx, 1, postID) }, postID),
+		Entry("publishing scheduled posts", func() error {
+			_, err := s.Posts.PublishDue(ctx, time.Now(), 10)
+			return err
+		}, uint(4), uint(5)),
+		Entry("commenting", func() error { return s.Comments.Create(ctx, &store.Comment{PostID: 7}) }, uint(7)),
+		Entry("deleting a comment", func() error { return s.Comments.Delete(ctx, 2, 1) }, uint(7)),
+		Entry("restoring a comment", func() error { return s.Comments.Restore(ctx, 2) }, uint(7)),
+		Entry("attaching media", func() error { return s.Media.AttachToPost(ctx, 1, mediaPost, nil) }, mediaPost),
+		Entry("processing media", func() error {
+			return s.Media.SaveProcessed(ctx, &store.MediaAttachment{PostID: &mediaPost}, nil)
+		}, mediaPost),
+		Entry("processing an avatar", func() error {
+			return s.Media.SaveProcessed(ctx, &store.MediaAttachment{}, nil)
+		}),
+		Entry("mentioning", func() error { _, err := s.Mentions.ReplaceForPost(ctx, postID, nil); return err }, postID),
+		Entry("mentioning in a comment", func() error {
+			_, err := s.Mentions.ReplaceForComment(ctx, 2, nil)
+			return err
+		}, uint(7)),
+		Entry("renaming a tag", func() error { return s.Tags.Update(ctx, &store.Tag{ID: 5}) }, uint(3), uint(12)),
+		Entry("merging a tag", func() error { return s.Tags.Merge(ctx, 5, 6) }, uint(3), uint(12)),
+		Entry("deleting a tag", func() error { return s.Tags.Delete(ctx, 5) }, uint(3), uint(12)),
+	)
+
+	DescribeTable("user writes evict the user",
+		func(write func() error) {
+			Expect(write()).To(Succeed())
+			Expect(users.ids).To(Equal([]uint{user.ID}))
+			Expect(posts.ids).To(BeEmpty())
+		},
+		Entry("activating", func() error { return s.Users.Activate(ctx, user.ID) }),
+		Entry("changing the email", func() error { return s.Users.UpdateEmail(ctx, user) }),
+		Entry("changing the password", func() error { return s.Users.UpdatePassword(ctx, user, "secret") }),
+		Entry("requesting deletion", func() error { return s.Users.RequestDeletion(ctx, user.ID, time.Now()) }),
+		Entry("cancelling deletion", func() error { return s.Users.CancelDeletion(ctx, user.ID) }),
+		Entry("enrolling 2FA", func() error { return s.Users.SetTOTPSecret(ctx, user.ID, "secret") }),
+		Entry("enabling 2FA", func() error { return s.Users.EnableTOTP(ctx, user.ID) }),
+		Entry("disabling 2FA", func() error { return s.Users.DisableTOTP(ctx, user.ID) }),
+		Entry("using a 2FA code", func() error { return s.Users.ConsumeTOTPStep(ctx, user.ID, 1) }),
+		Entry("signing up through a provider", func() error {
+			return s.Identities.Provision(ctx, user, "", &store.UserIdentity{})
+		}),
+	)
+
+	DescribeTable("user writes to what posts show of them evict the posts too",
+		func(write func() error) {
+			Expect(write()).To(Succeed())
+			Expect(users.ids).To(Equal([]uint{user.ID}))
+			Expect(posts.ids).To(Equal([]uint{40, 41}))
+		},
+		Entry("changing the username", func() error { return s.Users.UpdateUsername(ctx, user) }),
+		Entry("updating the profile", func() error { return s.Users.UpdateProfile(ctx, user) }),
+		Entry("deleting", func() error { return s.Users.Delete(ctx, user.ID) }),
+	)
+
+	It("drops the timeline of a user who follows or unfollows someone", func() {
+		Expect(s.Followers.Follow(ctx, user.ID, 12)).To(Succeed())
+		Expect(s.Followers.Unfollow(ctx, user.ID, 13)).To(Succeed())
+
+		Expect(timelines.ids).To(Equal([]uint{user.ID, user.ID}))
+		Expect(users.ids).To(BeEmpty())
+	})
+
+	It("keeps the cache when a write fails", func() {
+		s = newStorage(errWrite)
+
+		Expect(s.Posts.Update(ctx, &store.Post{ID: postID})).To(MatchError(errWrite))
+		Expect(s.Comments.Create(ctx, &store.Comment{PostID: 7})).To(MatchError(errWrite))
+		Expect(s.Users.UpdatePassword(ctx, user, "secret")).To(MatchError(errWrite))
+		Expect(s.Followers.Follow(ctx, user.ID, 12)).To(MatchError(errWrite))
+		Expect(s.Tags.Merge(ctx, 5, 6)).To(MatchError(errWrite))
+
+		Expect(posts.ids).To(BeEmpty())
+		Expect(users.ids).To(BeEmpty())
+		Expect(timelines.ids).To(BeEmpty())
+	})
+})
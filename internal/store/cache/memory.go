@@ -0,0 +1,201 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pangdfg/gopher-social/internal/store"
+)
+
+// LRU is an in-process cache holding up to a fixed number of entries,
+// dropping the least recently used one to make room. Values are kept
+// marshalled, as in Redis, so callers never share what is cached.
+type LRU struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	order   *list.List // front is most recently used
+
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions atomic.Uint64
+}
+
+type lruEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// LRUStats counts lookups since start. Evictions are entries dropped to
+// make room, not ones that expired.
+type LRUStats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Entries   int    `json:"entries"`
+}
+
+func NewLRU(size int) *LRU {
+	return &LRU{
+		size:    max(size, 1),
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+func (c *LRU) Stats() LRUStats {
+	c.mu.Lock()
+	entries := c.order.Len()
+	c.mu.Unlock()
+
+	return LRUStats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evictions.Load(),
+		Entries:   entries,
+	}
+}
+
+func (c *LRU) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if ok && time.Now().After(el.Value.(*lruEntry).expires) {
+		c.remove(el)
+		ok = false
+	}
+	if !ok {
+		c.misses.Add(1)
+		return nil, false
+	}
+
+	c.hits.Add(1)
+	c.order.MoveToFront(el)
+	return el.Value.(*lruEntry).value, true
+}
+
+func (c *LRU) set(key string, value []byte, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires := time.Now().Add(ttl)
+	if el, ok := c.entries[key]; ok {
+		entry := el.Value.(*lruEntry)
+		entry.value, entry.expires = value, expires
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&lruEntry{key: key, value: value, expires: expires})
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+		c.evictions.Add(1)
+	}
+}
+
+func (c *LRU) delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+}
+
+func (c *LRU) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.entries, el.Value.(*lruEntry).key)
+}
+
+// NewMemoryStorage caches posts and users in c and keeps rankings in
+// process memory, for a single instance without Redis.
+func NewMemoryStorage(c *LRU) Storage {
+	return Storage{
+		UserCache: &MemoryUserStore{lru: c},
+		PostCache: &MemoryPostStore{lru: c},
+		Rankings:  NewMemoryRankingStore(),
+	}
+}
+
+// MemoryPostStore is PostStore backed by an LRU.
+type MemoryPostStore struct {
+	lru *LRU
+}
+
+func (s *MemoryPostStore) Get(_ context.Context, postID uint) (*store.Post, error) {
+	val, ok := s.lru.get(fmt.Sprintf("post-%d", postID))
+	if !ok {
+		return nil, ErrMiss
+	}
+	if len(val) == 0 {
+		return nil, store.ErrNotFound
+	}
+	var p store.Post
+	if err := json.Unmarshal(val, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (s *MemoryPostStore) Set(_ context.Context, post *store.Post, ttl time.Duration) error {
+	b, err := json.Marshal(post)
+	if err != nil {
+		return err
+	}
+	s.lru.set(fmt.Sprintf("post-%d", post.ID), b, ttl)
+	return nil
+}
+
+func (s *MemoryPostStore) SetNotFound(_ context.Context, postID uint, ttl time.Duration) error {
+	s.lru.set(fmt.Sprintf("post-%d", postID), nil, ttl)
+	return nil
+}
+
+func (s *MemoryPostStore) Delete(_ context.Context, postID uint) {
+	s.lru.delete(fmt.Sprintf("post-%d", postID))
+}
+
+// MemoryUserStore is UserStore backed by an LRU.
+type MemoryUserStore struct {
+	lru *LRU
+}
+
+func (s *MemoryUserStore) Get(_ context.Context, userID uint) (*store.User, error) {
+	val, ok := s.lru.get(fmt.Sprintf("user-%d", userID))
+	if !ok {
+		return nil, ErrMiss
+	}
+	if len(val) == 0 {
+		return nil, store.ErrNotFound
+	}
+	var u store.User
+	if err := json.Unmarshal(val, &u); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (s *MemoryUserStore) Set(_ context.Context, user *store.User, ttl time.Duration) error {
+	b, err := json.Marshal(user)
+	if err != nil {
+		return err
+	}
+	s.lru.set(fmt.Sprintf("user-%d", user.ID), b, ttl)
+	return nil
+}
+
+func (s *MemoryUserStore) SetNotFound(_ context.Context, userID uint, ttl time.Duration) error {
+	s.lru.set(fmt.Sprintf("user-%d", userID), nil, ttl)
+	return nil
+}
+
+func (s *MemoryUserStore) Delete(_ context.Context, userID uint) {
+	s.lru.delete(fmt.Sprintf("user-%d", userID))
+}
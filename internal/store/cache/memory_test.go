@@ -0,0 +1,95 @@
+package cache_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/pangdfg/gopher-social/internal/store"
+	"github.com/pangdfg/gopher-social/internal/store/cache"
+)
+
+var _ = Describe("LRU", func() {
+	var (
+		ctx context.Context
+		lru *cache.LRU
+		c   cache.Storage
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		lru = cache.NewLRU(2)
+		c = cache.NewMemoryStorage(lru)
+	})
+
+	It("tells a miss from a post cached as not found", func() {
+		_, err := c.PostCache.Get(ctx, 1)
+		Expect(err).To(MatchError(cache.ErrMiss))
+
+		Expect(c.PostCache.SetNotFound(ctx, 1, time.Minute)).To(Succeed())
+		_, err = c.PostCache.Get(ctx, 1)
+		Expect(err).To(MatchError(store.ErrNotFound))
+	})
+
+	It("hands out copies", func() {
+		post := &store.Post{ID: 1, Title: "hello"}
+		Expect(c.PostCache.Set(ctx, post, time.Minute)).To(Succeed())
+		post.Title = "changed"
+
+		cached, err := c.PostCache.Get(ctx, 1)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cached.Title).To(Equal("hello"))
+		cached.Title = "changed"
+
+		cached, err = c.PostCache.Get(ctx, 1)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cached.Title).To(Equal("hello"))
+	})
+
+	It("leaves user secrets out", func() {
+		user := &store.User{ID: 1, Username: "gopher", Password: []byte("hash"), TOTPSecret: "totp"}
+		Expect(c.UserCache.Set(ctx, user, time.Minute)).To(Succeed())
+
+		cached, err := c.UserCache.Get(ctx, 1)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cached.Username).To(Equal("gopher"))
+		Expect(cached.Password).To(BeEmpty())
+		Expect(cached.TOTPSecret).To(BeEmpty())
+	})
+
+	It("expires entries", func() {
+		Expect(c.PostCache.Set(ctx, &store.Post{ID: 1}, 10*time.Millisecond)).To(Succeed())
+
+		Eventually(func() error {
+			_, err := c.PostCache.Get(ctx, 1)
+			return err
+		}).Should(MatchError(cache.ErrMiss))
+		Expect(lru.Stats().Entries).To(BeZero())
+	})
+
+	It("evicts the least recently used entry and counts lookups", func() {
+		Expect(c.PostCache.Set(ctx, &store.Post{ID: 1}, time.Minute)).To(Succeed())
+		Expect(c.UserCache.Set(ctx, &store.User{ID: 1}, time.Minute)).To(Succeed())
+		_, err := c.PostCache.Get(ctx, 1)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(c.PostCache.Set(ctx, &store.Post{ID: 2}, time.Minute)).To(Succeed())
+
+		_, err = c.UserCache.Get(ctx, 1)
+		Expect(err).To(MatchError(cache.ErrMiss))
+		_, err = c.PostCache.Get(ctx, 1)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(lru.Stats()).To(Equal(cache.LRUStats{Hits: 2, Misses: 1, Evictions: 1, Entries: 2}))
+	})
+
+	It("forgets deleted entries", func() {
+		Expect(c.UserCache.Set(ctx, &store.User{ID: 1}, time.Minute)).To(Succeed())
+		c.UserCache.Delete(ctx, 1)
+
+		_, err := c.UserCache.Get(ctx, 1)
+		Expect(err).To(MatchError(cache.ErrMiss))
+	})
+})
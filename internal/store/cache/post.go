@@ -10,6 +10,9 @@ import (
 	"github.com/pangdfg/gopher-social/internal/store"
 )
 
+// notFound is cached in place of a post or user that doesn't exist.
+const notFound = ""
+
 type PostStore struct {
 	rdb *redis.Client
 }
@@ -19,10 +22,13 @@ func (s *PostStore) Get(ctx context.Context, postID uint) (*store.Post, error) {
 	val, err := s.rdb.Get(ctx, cacheKey).Result()
 	if err != nil {
 		if err == redis.Nil {
-			return nil, store.ErrNotFound
+			return nil, ErrMiss
 		}
 		return nil, err
 	}
+	if val == notFound {
+		return nil, store.ErrNotFound
+	}
 	var p store.Post
 	if err := json.Unmarshal([]byte(val), &p); err != nil {
 		return nil, err
@@ -30,13 +36,18 @@ func (s *PostStore) Get(ctx context.Context, postID uint) (*store.Post, error) {
 	return &p, nil
 }
 
-func (s *PostStore) Set(ctx context.Context, post *store.Post) error {
+func (s *PostStore) Set(ctx context.Context, post *store.Post, ttl time.Duration) error {
 	cacheKey := fmt.Sprintf("post-%d", post.ID)
 	b, err := json.Marshal(post)
 	if err != nil {
 		return err
 	}
-	return s.rdb.Set(ctx, cacheKey, b, time.Minute*2).Err()
+	return s.rdb.Set(ctx, cacheKey, b, ttl).Err()
+}
+
+func (s *PostStore) SetNotFound(ctx context.Context, postID uint, ttl time.Duration) error {
+	cacheKey := fmt.Sprintf("post-%d", postID)
+	return s.rdb.Set(ctx, cacheKey, notFound, ttl).Err()
 }
 
 func (s *PostStore) Delete(ctx context.Context, postID uint) {
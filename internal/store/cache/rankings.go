@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pangdfg/gopher-social/internal/store"
+)
+
+// RankingStore keeps precomputed rankings such as trending posts in sorted
+// sets. A ranking is swapped in whole, so readers never see a half
+// written one.
+type RankingStore struct {
+	rdb *redis.Client
+}
+
+func NewRankingStore(rdb *redis.Client) *RankingStore {
+	return &RankingStore{rdb: rdb}
+}
+
+func (s *RankingStore) Replace(ctx context.Context, name string, entries []store.Scored, ttl time.Duration) error {
+	key := "ranking-" + name
+	if len(entries) == 0 {
+		return s.rdb.Del(ctx, key).Err()
+	}
+
+	members := make([]*redis.Z, 0, len(entries))
+	for _, e := range entries {
+		members = append(members, &redis.Z{Score: e.Score, Member: strconv.FormatUint(uint64(e.ID), 10)})
+	}
+
+	tmp := key + "-next"
+	_, err := s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, tmp)
+		pipe.ZAdd(ctx, tmp, members...)
+		pipe.Rename(ctx, tmp, key)
+		pipe.Expire(ctx, key, ttl)
+		return nil
+	})
+	return err
+}
+
+// Top returns up to limit entries, best first. A missing ranking is empty.
+func (s *RankingStore) Top(ctx context.Context, name string, limit int) ([]store.Scored, error) {
+	zs, err := s.rdb.ZRevRangeWithScores(ctx, "ranking-"+name, 0, int64(limit)-1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]store.Scored, 0, len(zs))
+	for _, z := range zs {
+		member, _ := z.Member.(string)
+		id, err := strconv.ParseUint(member, 10, 64)
+		if err != nil {
+			continue
+		}
+		out = append(out, store.Scored{ID: uint(id), Score: z.Score})
+	}
+	return out, nil
+}
+
+// MemoryRankingStore is RankingStore for a single instance without Redis.
+type MemoryRankingStore struct {
+	mu       sync.RWMutex
+	rankings map[string]memoryRanking
+}
+
+type memoryRanking struct {
+	entries []store.Scored
+	expires time.Time
+}
+
+func NewMemoryRankingStore() *MemoryRankingStore {
+	return &MemoryRankingStore{rankings: make(map[string]memoryRanking)}
+}
+
+// Replace expects entries best first, as the trending queries return them.
+func (s *MemoryRankingStore) Replace(_ context.Context, name string, entries []store.Scored, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rankings[name] = memoryRanking{
+		entries: append([]store.Scored(nil), entries...),
+		expires: time.Now().Add(ttl),
+	}
+	return nil
+}
+
+func (s *MemoryRankingStore) Top(_ context.Context, name string, limit int) ([]store.Scored, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	r, ok := s.rankings[name]
+	if !ok || time.Now().After(r.expires) {
+		return []store.Scored{}, nil
+	}
+
+	n := min(limit, len(r.entries))
+	return append([]store.Scored(nil), r.entries[:n]...), nil
+}
@@ -2,28 +2,48 @@ package cache
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/pangdfg/gopher-social/internal/store"
 )
 
+// ErrMiss is returned by Get when nothing is cached for the ID. A cached
+// lookup that found nothing returns store.ErrNotFound instead.
+var ErrMiss = errors.New("cache miss")
+
 type Storage struct {
-	UserCache interface {
-		Get(context.Context, uint) (*store.User, error)
-		Set(context.Context, *store.User) error
-		Delete(context.Context, uint)
-	}	
-	PostCache interface {
-		Get(context.Context, uint) (*store.Post, error)
-		Set(context.Context, *store.Post) error
-		Delete(context.Context, uint)
-	}
+	UserCache UserCache
+	PostCache PostCache
+	Rankings  Rankings
+	// Timelines is only kept in Redis; it is nil without it
+	Timelines Timelines
+}
+
+type UserCache interface {
+	Get(context.Context, uint) (*store.User, error)
+	Set(context.Context, *store.User, time.Duration) error
+	SetNotFound(context.Context, uint, time.Duration) error
+	Delete(context.Context, uint)
+}
+
+type PostCache interface {
+	Get(context.Context, uint) (*store.Post, error)
+	Set(context.Context, *store.Post, time.Duration) error
+	SetNotFound(context.Context, uint, time.Duration) error
+	Delete(context.Context, uint)
+}
+
+type Rankings interface {
+	Replace(ctx context.Context, name string, entries []store.Scored, ttl time.Duration) error
+	Top(ctx context.Context, name string, limit int) ([]store.Scored, error)
 }
 
 func NewRedisStorage(rbd *redis.Client) Storage {
 	return Storage{
 		UserCache: &UserStore{rdb: rbd},
 		PostCache: &PostStore{rdb: rbd},
+		Rankings:  &RankingStore{rdb: rbd},
 	}
 }
-
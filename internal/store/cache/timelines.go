@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pangdfg/gopher-social/internal/store"
+)
+
+// Timelines keeps each user's home timeline as post IDs scored by post
+// time, newest first.
+type Timelines interface {
+	// Push adds a post to the timelines of userIDs that are cached; the
+	// rest are rebuilt with it when next read.
+	Push(ctx context.Context, userIDs []uint, entry store.TimelineEntry) error
+	// Range returns the newest limit entries. ok is false when the
+	// timeline isn't cached.
+	Range(ctx context.Context, userID uint, limit int) (entries []store.TimelineEntry, ok bool, err error)
+	Replace(ctx context.Context, userID uint, entries []store.TimelineEntry) error
+	Remove(ctx context.Context, userID uint, postIDs []uint) error
+	Delete(ctx context.Context, userID uint)
+}
+
+// TimelineStore keeps timelines in sorted sets holding up to size posts.
+// A timeline lives for ttl after it is built and is then rebuilt, which
+// also picks up anything a push missed.
+type TimelineStore struct {
+	rdb  *redis.Client
+	size int
+	ttl  time.Duration
+}
+
+func NewTimelineStore(rdb *redis.Client, size int, ttl time.Duration) *TimelineStore {
+	return &TimelineStore{rdb: rdb, size: size, ttl: ttl}
+}
+
+// pushTimeline adds to a timeline only if it exists, so a push can't
+// leave behind a timeline holding nothing but the one post, and trims it.
+var pushTimeline = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	redis.call('ZADD', KEYS[1], ARGV[1], ARGV[2])
+	redis.call('ZREMRANGEBYRANK', KEYS[1], 0, -tonumber(ARGV[3]) - 1)
+end
+return 0`)
+
+func timelineKey(userID uint) string {
+	return fmt.Sprintf("timeline-%d", userID)
+}
+
+func timelineScore(t time.Time) float64 {
+	return float64(t.UnixMilli())
+}
+
+func (s *TimelineStore) Push(ctx context.Context, userIDs []uint, entry store.TimelineEntry) error {
+	_, err := s.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, id := range userIDs {
+			pushTimeline.Eval(ctx, pipe, []string{timelineKey(id)}, timelineScore(entry.CreatedAt), entry.ID, s.size)
+		}
+		return nil
+	})
+	return err
+}
+
+func (s *TimelineStore) Range(ctx context.Context, userID uint, limit int) ([]store.TimelineEntry, bool, error) {
+	zs, err := s.rdb.ZRevRangeWithScores(ctx, timelineKey(userID), 0, int64(limit)-1).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	// sorted sets are never empty; an empty result is a missing timeline
+	if len(zs) == 0 {
+		return nil, false, nil
+	}
+
+	entries := make([]store.TimelineEntry, 0, len(zs))
+	for _, z := range zs {
+		member, _ := z.Member.(string)
+		id, err := strconv.ParseUint(member, 10, 64)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, store.TimelineEntry{ID: uint(id), CreatedAt: time.UnixMilli(int64(z.Score))})
+	}
+	return entries, true, nil
+}
+
+func (s *TimelineStore) Replace(ctx context.Context, userID uint, entries []store.TimelineEntry) error {
+	key := timelineKey(userID)
+	if len(entries) == 0 {
+		return s.rdb.Del(ctx, key).Err()
+	}
+
+	members := make([]*redis.Z, 0, len(entries))
+	for _, e := range entries {
+		members = append(members, &redis.Z{Score: timelineScore(e.CreatedAt), Member: strconv.FormatUint(uint64(e.ID), 10)})
+	}
+
+	_, err := s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key)
+		pipe.ZAdd(ctx, key, members...)
+		pipe.ZRemRangeByRank(ctx, key, 0, -int64(s.size)-1)
+		pipe.Expire(ctx, key, s.ttl)
+		return nil
+	})
+	return err
+}
+
+func (s *TimelineStore) Remove(ctx context.Context, userID uint, postIDs []uint) error {
+	members := make([]any, 0, len(postIDs))
+	for _, id := range postIDs {
+		members = append(members, strconv.FormatUint(uint64(id), 10))
+	}
+	return s.rdb.ZRem(ctx, timelineKey(userID), members...).Err()
+}
+
+func (s *TimelineStore) Delete(ctx context.Context, userID uint) {
+	s.rdb.Del(ctx, timelineKey(userID))
+}
@@ -20,10 +20,13 @@ func (s *UserStore) Get(ctx context.Context, userID uint) (*store.User, error) {
     val, err := s.rdb.Get(ctx, cacheKey).Result()
     if err != nil {
         if err == redis.Nil {
-            return nil, store.ErrNotFound
+            return nil, ErrMiss
         }
         return nil, err
     }
+    if val == notFound {
+        return nil, store.ErrNotFound
+    }
     var u store.User
     if err := json.Unmarshal([]byte(val), &u); err != nil {
         return nil, err
@@ -31,13 +34,18 @@ func (s *UserStore) Get(ctx context.Context, userID uint) (*store.User, error) {
     return &u, nil
 }
 
-func (s *UserStore) Set(ctx context.Context, user *store.User) error {
+func (s *UserStore) Set(ctx context.Context, user *store.User, ttl time.Duration) error {
     cacheKey := fmt.Sprintf("user-%d", user.ID)
     b, err := json.Marshal(user)
     if err != nil {
         return err
     }
-    return s.rdb.Set(ctx, cacheKey, b, time.Minute*15).Err()
+    return s.rdb.Set(ctx, cacheKey, b, ttl).Err()
+}
+
+func (s *UserStore) SetNotFound(ctx context.Context, userID uint, ttl time.Duration) error {
+    cacheKey := fmt.Sprintf("user-%d", userID)
+    return s.rdb.Set(ctx, cacheKey, notFound, ttl).Err()
 }
 
 func (s *UserStore) Delete(ctx context.Context, userID uint) {
@@ -2,6 +2,7 @@ package store
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"gorm.io/gorm"
@@ -14,7 +15,11 @@ type Comment struct {
 	UserID    uint      `json:"user_id"`
 	User      User      `gorm:"foreignKey:UserID" json:"user"`
 	Content   string    `json:"content"`
+	Mentions  []Mention `gorm:"foreignKey:CommentID" json:"mentions"`
 	CreatedAt time.Time `json:"created_at"`
+	// DeletedAt puts a comment in the trash, like posts
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+	DeletedByID *uint          `json:"-"`
 }
 
 
@@ -30,4 +35,119 @@ func NewCommentStore(db *gorm.DB) *CommentStore {
 func (s *CommentStore) Create(ctx context.Context, comment *Comment) error {
     return s.db.WithContext(ctx).Create(comment).Error
 }
-	
\ No newline at end of file
+
+func (s *CommentStore) GetByID(ctx context.Context, id uint) (*Comment, error) {
+	comment := &Comment{}
+	if err := s.db.WithContext(ctx).First(comment, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return comment, nil
+}
+
+// Delete moves a comment to the trash, recording who deleted it.
+func (s *CommentStore) Delete(ctx context.Context, id, deletedByID uint) error {
+	tx := s.db.WithContext(ctx).
+		Model(&Comment{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"deleted_at":    time.Now(),
+			"deleted_by_id": deletedByID,
+		})
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetDeleted returns a comment from the trash.
+func (s *CommentStore) GetDeleted(ctx context.Context, id uint) (*Comment, error) {
+	comment := &Comment{}
+	err := s.db.WithContext(ctx).
+		Unscoped().
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		First(comment).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return comment, nil
+}
+
+// GetTrash lists trashed comments, most recently deleted first. With a
+// userID it is limited to the comments that user deleted themselves; 0
+// lists all.
+func (s *CommentStore) GetTrash(ctx context.Context, fq PaginatedFeedQuery, userID uint) ([]Comment, error) {
+	var comments []Comment
+
+	query := s.db.WithContext(ctx).
+		Unscoped().
+		Preload("User").
+		Where("comments.deleted_at IS NOT NULL")
+
+	if userID != 0 {
+		query = query.Where("comments.user_id = ? AND comments.deleted_by_id = ?", userID, userID)
+	}
+
+	if fq.Search != "" {
+		query = query.Where("content ILIKE ?", "%"+fq.Search+"%")
+	}
+
+	if fq.Sort == "" {
+		fq.Sort = "desc"
+	}
+
+	err := query.
+		Order("comments.deleted_at " + fq.Sort).
+		Limit(fq.Limit).
+		Offset(fq.Offset).
+		Find(&comments).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+// Restore takes a comment out of the trash.
+func (s *CommentStore) Restore(ctx context.Context, id uint) error {
+	tx := s.db.WithContext(ctx).
+		Unscoped().
+		Model(&Comment{}).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		Updates(map[string]interface{}{
+			"deleted_at":    nil,
+			"deleted_by_id": nil,
+		})
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// PurgeDeleted deletes up to limit comments trashed before cutoff for good
+// and returns how many went.
+func (s *CommentStore) PurgeDeleted(ctx context.Context, cutoff time.Time, limit int) (int64, error) {
+	ids := s.db.Unscoped().
+		Model(&Comment{}).
+		Select("id").
+		Where("deleted_at < ?", cutoff).
+		Order("deleted_at").
+		Limit(limit)
+
+	tx := s.db.WithContext(ctx).Unscoped().Where("id IN (?)", ids).Delete(&Comment{})
+	if tx.Error != nil {
+		return 0, tx.Error
+	}
+	return tx.RowsAffected, nil
+}
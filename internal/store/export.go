@@ -0,0 +1,120 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// UserExport is everything we hold about a user, for data portability
+// requests. Posts and comments in the trash are included until they are
+// purged and listed again by ID under TrashedPosts and TrashedComments.
+// Mentions cover both the user being mentioned and the mentions in their
+// own posts and comments. Secrets (password, TOTP, token hashes) are never
+// included.
+type UserExport struct {
+	ExportedAt      time.Time            `json:"exported_at"`
+	User            User                 `json:"user"`
+	Posts           []Post               `json:"posts"`
+	Comments        []Comment            `json:"comments"`
+	TrashedPosts    []uint               `json:"trashed_posts"`
+	TrashedComments []uint               `json:"trashed_comments"`
+	Revisions       []PostRevision       `json:"post_revisions"`
+	Media           []MediaAttachment    `json:"media"`
+	Mentions        []Mention            `json:"mentions"`
+	Followers       []uint               `json:"followers"`
+	Following       []uint               `json:"following"`
+	FollowedTags    []uint               `json:"followed_tags"`
+	Bookmarks       []Bookmark           `json:"bookmarks"`
+	Collections     []BookmarkCollection `json:"bookmark_collections"`
+	Identities      []UserIdentity       `json:"identities"`
+	AccessTokens    []AccessToken        `json:"access_tokens"`
+}
+
+type ExportStore struct {
+	db *gorm.DB
+}
+
+func NewExportStore(db *gorm.DB) *ExportStore {
+	return &ExportStore{db: db}
+}
+
+func (s *ExportStore) GetUserData(ctx context.Context, userID uint) (*UserExport, error) {
+	db := s.db.WithContext(ctx)
+	export := &UserExport{ExportedAt: time.Now()}
+
+	if err := db.Preload("Role").First(&export.User, userID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	if err := db.Unscoped().Preload("Tags").Where("user_id = ?", userID).Order("created_at asc").Find(&export.Posts).Error; err != nil {
+		return nil, err
+	}
+	for _, p := range export.Posts {
+		if p.DeletedAt.Valid {
+			export.TrashedPosts = append(export.TrashedPosts, p.ID)
+		}
+	}
+
+	if err := db.Unscoped().Where("user_id = ?", userID).Order("created_at asc").Find(&export.Comments).Error; err != nil {
+		return nil, err
+	}
+	for _, cm := range export.Comments {
+		if cm.DeletedAt.Valid {
+			export.TrashedComments = append(export.TrashedComments, cm.ID)
+		}
+	}
+
+	posts := db.Unscoped().Model(&Post{}).Select("id").Where("user_id = ?", userID)
+	comments := db.Unscoped().Model(&Comment{}).Select("id").Where("user_id = ?", userID)
+
+	if err := db.Where("post_id IN (?)", posts).Order("post_id, version").Find(&export.Revisions).Error; err != nil {
+		return nil, err
+	}
+
+	if err := db.Preload("Variants").Where("user_id = ?", userID).Order("created_at asc").Find(&export.Media).Error; err != nil {
+		return nil, err
+	}
+
+	// other users only show up by their public ID and username
+	if err := db.Preload("User", func(db *gorm.DB) *gorm.DB { return db.Select("id", "username") }).
+		Where("user_id = ? OR post_id IN (?) OR comment_id IN (?)", userID, posts, comments).
+		Order("created_at asc").
+		Find(&export.Mentions).Error; err != nil {
+		return nil, err
+	}
+
+	if err := db.Model(&Follower{}).Where("user_id = ?", userID).Pluck("follower_id", &export.Followers).Error; err != nil {
+		return nil, err
+	}
+
+	if err := db.Model(&Follower{}).Where("follower_id = ?", userID).Pluck("user_id", &export.Following).Error; err != nil {
+		return nil, err
+	}
+
+	if err := db.Model(&TagFollower{}).Where("user_id = ?", userID).Pluck("tag_id", &export.FollowedTags).Error; err != nil {
+		return nil, err
+	}
+
+	if err := db.Where("user_id = ?", userID).Order("created_at asc").Find(&export.Bookmarks).Error; err != nil {
+		return nil, err
+	}
+
+	if err := db.Where("user_id = ?", userID).Order("name").Find(&export.Collections).Error; err != nil {
+		return nil, err
+	}
+
+	if err := db.Where("user_id = ?", userID).Find(&export.Identities).Error; err != nil {
+		return nil, err
+	}
+
+	if err := db.Where("user_id = ?", userID).Find(&export.AccessTokens).Error; err != nil {
+		return nil, err
+	}
+
+	return export, nil
+}
@@ -0,0 +1,60 @@
+package store
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"gorm.io/gorm"
+)
+
+var _ = Describe("ExportStore", func() {
+	var (
+		ctx     context.Context
+		tx      *gorm.DB
+		exports *ExportStore
+		user    *User
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		tx = testDB()
+		exports = &ExportStore{db: tx}
+		user = seedUser(tx, "gopher")
+	})
+
+	It("includes trashed posts and comments and marks them", func() {
+		post := seedPost(tx, user)
+		comment := &Comment{PostID: post.ID, UserID: user.ID, Content: "first"}
+		Expect(tx.Omit("Post", "User").Create(comment).Error).To(Succeed())
+		Expect(tx.Delete(comment).Error).To(Succeed())
+		Expect(tx.Delete(post).Error).To(Succeed())
+
+		export, err := exports.GetUserData(ctx, user.ID)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(export.Posts).To(ConsistOf(HaveField("ID", post.ID)))
+		Expect(export.Comments).To(ConsistOf(HaveField("ID", comment.ID)))
+		Expect(export.TrashedPosts).To(ConsistOf(post.ID))
+		Expect(export.TrashedComments).To(ConsistOf(comment.ID))
+	})
+
+	It("includes revisions of the user's posts and mentions both ways", func() {
+		other := seedUser(tx, "other")
+		post := seedPost(tx, user)
+		theirs := seedPost(tx, other)
+		Expect(tx.Create(&PostRevision{PostID: post.ID, Version: 1, Title: "old", Content: "old"}).Error).To(Succeed())
+		Expect(tx.Omit("User").Create(&Mention{UserID: other.ID, PostID: &post.ID}).Error).To(Succeed())
+		Expect(tx.Omit("User").Create(&Mention{UserID: user.ID, PostID: &theirs.ID}).Error).To(Succeed())
+		Expect(tx.Omit("User").Create(&Mention{UserID: other.ID, PostID: &theirs.ID}).Error).To(Succeed())
+
+		export, err := exports.GetUserData(ctx, user.ID)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(export.Revisions).To(ConsistOf(HaveField("PostID", post.ID)))
+		Expect(export.Mentions).To(HaveLen(2))
+		for _, m := range export.Mentions {
+			Expect(m.User.Email).To(BeEmpty())
+		}
+	})
+})
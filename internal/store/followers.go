@@ -35,16 +35,70 @@ func (s *FollowerStore) Follow(ctx context.Context, followerID uint, userID uint
 		if errors.Is(err, gorm.ErrDuplicatedKey) {
 			return ErrConflict
 		}
+		if errors.Is(err, gorm.ErrForeignKeyViolated) {
+			return ErrNotFound
+		}
 		return err
 	}
 
 	return nil
 }
 
-// Unfollow deletes a follower record
+// Unfollow deletes a follower record, returning ErrNotFound if there was none
 func (s *FollowerStore) Unfollow(ctx context.Context, followerID uint, userID uint) error {
-	err := s.db.WithContext(ctx).
+	tx := s.db.WithContext(ctx).
 		Where("user_id = ? AND follower_id = ?", userID, followerID).
-		Delete(&Follower{}).Error
-	return err
-}
\ No newline at end of file
+		Delete(&Follower{})
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetFollowerIDs pages through the users following userID in ID order,
+// starting after afterID.
+func (s *FollowerStore) GetFollowerIDs(ctx context.Context, userID, afterID uint, limit int) ([]uint, error) {
+	var ids []uint
+	err := s.db.WithContext(ctx).
+		Model(&Follower{}).
+		Where("user_id = ? AND follower_id > ?", userID, afterID).
+		Order("follower_id").
+		Limit(limit).
+		Pluck("follower_id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// CountFollowers returns how many users follow userID.
+func (s *FollowerStore) CountFollowers(ctx context.Context, userID uint) (int64, error) {
+	var count int64
+	err := s.db.WithContext(ctx).
+		Table("users").
+		Select("followers_count").
+		Where("id = ?", userID).
+		Scan(&count).Error
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// GetPopularFollowed lists the users followerID follows that have at
+// least minFollowers followers.
+func (s *FollowerStore) GetPopularFollowed(ctx context.Context, followerID uint, minFollowers int64) ([]uint, error) {
+	var ids []uint
+	err := s.db.WithContext(ctx).
+		Table("followers f").
+		Joins("JOIN users u ON u.id = f.user_id").
+		Where("f.follower_id = ? AND u.followers_count >= ?", followerID, minFollowers).
+		Pluck("f.user_id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
@@ -0,0 +1,111 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// UserIdentity links a user to an account at an external OpenID Connect
+// provider. A user may have one identity per provider.
+type UserIdentity struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    uint      `json:"user_id"`
+	Provider  string    `gorm:"size:64" json:"provider"`
+	Subject   string    `gorm:"size:255" json:"-"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type IdentityStore struct {
+	db *gorm.DB
+}
+
+func NewIdentityStore(db *gorm.DB) *IdentityStore {
+	return &IdentityStore{db: db}
+}
+
+func (s *IdentityStore) GetByProviderSubject(ctx context.Context, provider, subject string) (*UserIdentity, error) {
+	identity := &UserIdentity{}
+	err := s.db.WithContext(ctx).
+		Where("provider = ? AND subject = ?", provider, subject).
+		First(identity).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return identity, nil
+}
+
+func (s *IdentityStore) GetByUserID(ctx context.Context, userID uint) ([]UserIdentity, error) {
+	var identities []UserIdentity
+	err := s.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("created_at asc").
+		Find(&identities).Error
+	if err != nil {
+		return nil, err
+	}
+	return identities, nil
+}
+
+// Create links an identity to an existing user.
+func (s *IdentityStore) Create(ctx context.Context, identity *UserIdentity) error {
+	if err := s.db.WithContext(ctx).Create(identity).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return ErrConflict
+		}
+		return err
+	}
+	return nil
+}
+
+// Provision creates a user together with its first identity. It returns
+// ErrDuplicateEmail or ErrDuplicateUsername when an active account already
+// holds the email or username.
+func (s *IdentityStore) Provision(ctx context.Context, user *User, plain string, identity *UserIdentity) error {
+	var p password
+	if err := p.Set(plain); err != nil {
+		return err
+	}
+	user.Password = p.hash
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// an unactivated account only proves someone typed the address in;
+		// the provider verified it, so the new account takes its place
+		if err := tx.Where("email = ? AND is_active = ?", user.Email, false).Delete(&User{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+
+		identity.UserID = user.ID
+		if err := tx.Create(identity).Error; err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return ErrConflict
+			}
+			return err
+		}
+		return nil
+	})
+	if !errors.Is(err, gorm.ErrDuplicatedKey) {
+		return err
+	}
+
+	// the translated error doesn't name the constraint, and the aborted
+	// transaction can't be asked, so look for the email after the fact
+	var emails int64
+	if err := s.db.WithContext(ctx).Model(&User{}).Where("email = ?", user.Email).Count(&emails).Error; err != nil {
+		return err
+	}
+	if emails > 0 {
+		return ErrDuplicateEmail
+	}
+	return ErrDuplicateUsername
+}
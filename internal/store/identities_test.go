@@ -0,0 +1,53 @@
+package store
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"gorm.io/gorm"
+)
+
+var _ = Describe("IdentityStore", func() {
+	var (
+		ctx        context.Context
+		tx         *gorm.DB
+		identities *IdentityStore
+		existing   *User
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		tx = testDB()
+		identities = &IdentityStore{db: tx}
+		existing = seedUser(tx, "gopher")
+	})
+
+	provision := func(username, email string) (*User, error) {
+		user := &User{Username: username, Email: email, IsActive: true, RoleID: existing.RoleID}
+		identity := &UserIdentity{Provider: "oidc", Subject: username, Email: email}
+		return user, identities.Provision(ctx, user, "secret-password", identity)
+	}
+
+	Describe("Provision", func() {
+		It("tells an email collision from a username collision", func() {
+			_, err := provision("someone-else", existing.Email)
+			Expect(err).To(MatchError(ErrDuplicateEmail))
+
+			_, err = provision(existing.Username, "other@example.com")
+			Expect(err).To(MatchError(ErrDuplicateUsername))
+		})
+
+		It("replaces an unactivated account on the same email", func() {
+			Expect(tx.Model(existing).Update("is_active", false).Error).To(Succeed())
+
+			user, err := provision("gopher-oidc", existing.Email)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(user.ID).NotTo(Equal(existing.ID))
+
+			var left int64
+			Expect(tx.Model(&User{}).Where("id = ?", existing.ID).Count(&left).Error).To(Succeed())
+			Expect(left).To(BeZero())
+		})
+	})
+})
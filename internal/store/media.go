@@ -0,0 +1,214 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	MediaKindPost   = "post"
+	MediaKindAvatar = "avatar"
+)
+
+// Processing states of an upload. Variants exist once it is ready.
+const (
+	MediaStatusPending    = "pending"
+	MediaStatusProcessing = "processing"
+	MediaStatusReady      = "ready"
+	MediaStatusFailed     = "failed"
+)
+
+// MediaAttachment is an uploaded image. Post media starts out detached and
+// is linked to a post when the post references it.
+type MediaAttachment struct {
+	ID          uint           `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID      uint           `json:"user_id"`
+	PostID      *uint          `json:"post_id"`
+	Kind        string         `gorm:"size:16" json:"kind"`
+	StorageKey  string         `gorm:"uniqueIndex" json:"-"`
+	URL         string         `json:"url"`
+	ContentType string         `gorm:"size:64" json:"content_type"`
+	Size        int64          `json:"size"`
+	AltText     string         `gorm:"size:1000" json:"alt_text"`
+	Position    int            `json:"position"`
+	Status      string         `gorm:"size:16;default:pending" json:"status"`
+	Orientation int            `gorm:"default:1" json:"-"`
+	Width       int            `json:"width"`
+	Height      int            `json:"height"`
+	Blurhash    string         `gorm:"size:64" json:"blurhash"`
+	ClaimedAt   *time.Time     `json:"-"`
+	Variants    []MediaVariant `gorm:"foreignKey:MediaID" json:"variants"`
+	CreatedAt   time.Time      `json:"created_at"`
+}
+
+// MediaVariant is a resized rendition of an upload.
+type MediaVariant struct {
+	ID          uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	MediaID     uint      `json:"media_id"`
+	Name        string    `gorm:"size:16" json:"name"`
+	StorageKey  string    `gorm:"uniqueIndex" json:"-"`
+	URL         string    `json:"url"`
+	ContentType string    `gorm:"size:64" json:"content_type"`
+	Width       int       `json:"width"`
+	Height      int       `json:"height"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type MediaStore struct {
+	db *gorm.DB
+}
+
+func NewMediaStore(db *gorm.DB) *MediaStore {
+	return &MediaStore{db: db}
+}
+
+func (s *MediaStore) Create(ctx context.Context, m *MediaAttachment) error {
+	return s.db.WithContext(ctx).Create(m).Error
+}
+
+// AttachToPost links the user's detached post media to postID in the given
+// order, setting alt text and position. It fails with ErrNotFound unless
+// every attachment belongs to userID and is still detached.
+func (s *MediaStore) AttachToPost(ctx context.Context, userID, postID uint, items []MediaAttachment) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for i, m := range items {
+			res := tx.Model(&MediaAttachment{}).
+				Where("id = ? AND user_id = ? AND kind = ? AND post_id IS NULL", m.ID, userID, MediaKindPost).
+				Updates(map[string]interface{}{
+					"post_id":  postID,
+					"alt_text": m.AltText,
+					"position": i,
+				})
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return ErrNotFound
+			}
+		}
+		return nil
+	})
+}
+
+func (s *MediaStore) GetByUserID(ctx context.Context, userID uint) ([]MediaAttachment, error) {
+	var media []MediaAttachment
+	err := s.db.WithContext(ctx).Preload("Variants").Where("user_id = ?", userID).Find(&media).Error
+	if err != nil {
+		return nil, err
+	}
+	return media, nil
+}
+
+// GetOrphaned returns media older than cutoff that nothing references:
+// post media never attached or whose post was deleted, and avatars the
+// owner has since replaced.
+func (s *MediaStore) GetOrphaned(ctx context.Context, cutoff time.Time, limit int) ([]MediaAttachment, error) {
+	var media []MediaAttachment
+	err := s.db.WithContext(ctx).
+		Preload("Variants").
+		Where("created_at < ?", cutoff).
+		Where(`((kind = ? AND post_id IS NULL) OR
+			(kind = ? AND NOT EXISTS (
+				SELECT 1 FROM users u WHERE u.id = media_attachments.user_id AND u.avatar_url = media_attachments.url
+			)))`, MediaKindPost, MediaKindAvatar).
+		Order("id").
+		Limit(limit).
+		Find(&media).Error
+	if err != nil {
+		return nil, err
+	}
+	return media, nil
+}
+
+func (s *MediaStore) Delete(ctx context.Context, id uint) error {
+	tx := s.db.WithContext(ctx).Delete(&MediaAttachment{}, id)
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Claim marks an upload as being processed and returns it. Uploads stuck in
+// processing since before staleBefore, e.g. after a crash, can be claimed
+// again. It returns ErrNotFound when there is nothing to do.
+func (s *MediaStore) Claim(ctx context.Context, id uint, staleBefore time.Time) (*MediaAttachment, error) {
+	now := time.Now()
+	tx := s.db.WithContext(ctx).Model(&MediaAttachment{}).
+		Where("id = ? AND (status = ? OR (status = ? AND claimed_at < ?))",
+			id, MediaStatusPending, MediaStatusProcessing, staleBefore).
+		Updates(map[string]interface{}{
+			"status":     MediaStatusProcessing,
+			"claimed_at": now,
+		})
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return nil, ErrNotFound
+	}
+
+	m := &MediaAttachment{}
+	if err := s.db.WithContext(ctx).First(m, id).Error; err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// GetUnprocessedIDs lists uploads waiting for processing, including stale
+// claims.
+func (s *MediaStore) GetUnprocessedIDs(ctx context.Context, staleBefore time.Time, limit int) ([]uint, error) {
+	var ids []uint
+	err := s.db.WithContext(ctx).Model(&MediaAttachment{}).
+		Where("status = ? OR (status = ? AND claimed_at < ?)", MediaStatusPending, MediaStatusProcessing, staleBefore).
+		Order("id").
+		Limit(limit).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// SaveProcessed stores the variants and metadata of an upload and marks it
+// ready, replacing variants from an earlier run.
+func (s *MediaStore) SaveProcessed(ctx context.Context, m *MediaAttachment, variants []MediaVariant) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("media_id = ?", m.ID).Delete(&MediaVariant{}).Error; err != nil {
+			return err
+		}
+
+		for i := range variants {
+			variants[i].MediaID = m.ID
+		}
+		if len(variants) > 0 {
+			if err := tx.Create(&variants).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(&MediaAttachment{}).
+			Where("id = ?", m.ID).
+			Updates(map[string]interface{}{
+				"status":     MediaStatusReady,
+				"width":      m.Width,
+				"height":     m.Height,
+				"blurhash":   m.Blurhash,
+				"claimed_at": nil,
+			}).Error
+	})
+}
+
+func (s *MediaStore) MarkFailed(ctx context.Context, id uint) error {
+	return s.db.WithContext(ctx).Model(&MediaAttachment{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":     MediaStatusFailed,
+			"claimed_at": nil,
+		}).Error
+}
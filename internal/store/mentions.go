@@ -0,0 +1,84 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Mention links a post or a comment to a user named in its text. Start
+// and End are rune offsets of the "@username" span.
+type Mention struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    uint      `json:"user_id"`
+	User      User      `gorm:"foreignKey:UserID" json:"user"`
+	PostID    *uint     `json:"post_id"`
+	CommentID *uint     `json:"comment_id"`
+	Start     int       `gorm:"column:start_offset" json:"start"`
+	End       int       `gorm:"column:end_offset" json:"end"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type MentionStore struct {
+	db *gorm.DB
+}
+
+func NewMentionStore(db *gorm.DB) *MentionStore {
+	return &MentionStore{db: db}
+}
+
+// ReplaceForPost sets the mentions of a post and returns the IDs of users
+// who were not mentioned in it before.
+func (s *MentionStore) ReplaceForPost(ctx context.Context, postID uint, mentions []Mention) ([]uint, error) {
+	for i := range mentions {
+		mentions[i].PostID, mentions[i].CommentID = &postID, nil
+	}
+	return s.replace(ctx, "post_id", postID, mentions)
+}
+
+// ReplaceForComment is ReplaceForPost for a comment.
+func (s *MentionStore) ReplaceForComment(ctx context.Context, commentID uint, mentions []Mention) ([]uint, error) {
+	for i := range mentions {
+		mentions[i].PostID, mentions[i].CommentID = nil, &commentID
+	}
+	return s.replace(ctx, "comment_id", commentID, mentions)
+}
+
+func (s *MentionStore) replace(ctx context.Context, column string, id uint, mentions []Mention) ([]uint, error) {
+	var added []uint
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var previous []uint
+		if err := tx.Model(&Mention{}).Where(column+" = ?", id).Distinct().Pluck("user_id", &previous).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where(column+" = ?", id).Delete(&Mention{}).Error; err != nil {
+			return err
+		}
+
+		if len(mentions) > 0 {
+			if err := tx.Omit("User").Create(&mentions).Error; err != nil {
+				return err
+			}
+		}
+
+		seen := make(map[uint]bool, len(previous))
+		for _, uid := range previous {
+			seen[uid] = true
+		}
+		for _, m := range mentions {
+			if !seen[m.UserID] {
+				seen[m.UserID] = true
+				added = append(added, m.UserID)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return added, nil
+}
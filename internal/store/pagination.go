@@ -1,13 +1,20 @@
 package store
 
 import (
+	"errors"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+
+	"github.com/pangdfg/gopher-social/internal/richtext"
 )
 
+// ErrInvalidTags is returned by Parse when none of the requested tags is a
+// valid tag name, rather than leaving the feed unfiltered.
+var ErrInvalidTags = errors.New("tags: no valid tag names")
+
 type PaginatedFeedQuery struct {
 	Limit  int      `json:"limit" validate:"gte=1,lte=20"`
 	Offset int      `json:"offset" validate:"gte=0"`
@@ -39,7 +46,15 @@ func (fq PaginatedFeedQuery) Parse(c *fiber.Ctx) (PaginatedFeedQuery, error) {
 
 	// Tags
 	if tags := c.Query("tags"); tags != "" {
-		fq.Tags = strings.Split(tags, ",")
+		fq.Tags = fq.Tags[:0]
+		for _, t := range strings.Split(tags, ",") {
+			if name, ok := richtext.NormalizeTag(t); ok {
+				fq.Tags = append(fq.Tags, name)
+			}
+		}
+		if len(fq.Tags) == 0 {
+			return fq, ErrInvalidTags
+		}
 	}
 
 	// Search
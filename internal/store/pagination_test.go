@@ -0,0 +1,44 @@
+package store
+
+import (
+	"net/http/httptest"
+
+	"github.com/gofiber/fiber/v2"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PaginatedFeedQuery", func() {
+	parse := func(query string) (PaginatedFeedQuery, error) {
+		var (
+			fq  PaginatedFeedQuery
+			err error
+		)
+		app := fiber.New()
+		app.Get("/", func(c *fiber.Ctx) error {
+			fq, err = PaginatedFeedQuery{Limit: 20, Sort: "desc", Tags: []string{}}.Parse(c)
+			return nil
+		})
+		_, testErr := app.Test(httptest.NewRequest(fiber.MethodGet, "/?"+query, nil), -1)
+		Expect(testErr).NotTo(HaveOccurred())
+		return fq, err
+	}
+
+	It("normalizes tags and drops invalid ones", func() {
+		fq, err := parse("tags=Go,two%20words,%23Stra%C3%9Fe")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fq.Tags).To(Equal([]string{"go", "strasse"}))
+	})
+
+	It("refuses to drop the filter when no tag is valid", func() {
+		_, err := parse("tags=two%20words,%21%21")
+		Expect(err).To(MatchError(ErrInvalidTags))
+	})
+
+	It("leaves the feed unfiltered without tags", func() {
+		fq, err := parse("limit=5")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fq.Tags).To(BeEmpty())
+		Expect(fq.Limit).To(Equal(5))
+	})
+})
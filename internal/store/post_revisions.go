@@ -0,0 +1,63 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PostRevision is the title and content a post had at Version, kept when
+// an edit replaced them. CreatedAt is when that text was written.
+type PostRevision struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	PostID    uint      `json:"post_id"`
+	Version   int       `json:"version"`
+	Title     string    `gorm:"size:255" json:"title"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type PostRevisionStore struct {
+	db *gorm.DB
+}
+
+func NewPostRevisionStore(db *gorm.DB) *PostRevisionStore {
+	return &PostRevisionStore{db: db}
+}
+
+// GetByPostID lists a post's revisions, newest first by default.
+func (s *PostRevisionStore) GetByPostID(ctx context.Context, postID uint, fq PaginatedFeedQuery) ([]PostRevision, error) {
+	var revisions []PostRevision
+
+	if fq.Sort == "" {
+		fq.Sort = "desc"
+	}
+
+	err := s.db.WithContext(ctx).
+		Where("post_id = ?", postID).
+		Order("version " + fq.Sort).
+		Limit(fq.Limit).
+		Offset(fq.Offset).
+		Find(&revisions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+func (s *PostRevisionStore) Get(ctx context.Context, postID uint, version int) (*PostRevision, error) {
+	revision := &PostRevision{}
+	err := s.db.WithContext(ctx).
+		Where("post_id = ? AND version = ?", postID, version).
+		First(revision).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return revision, nil
+}
@@ -2,6 +2,7 @@ package store
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"gorm.io/gorm"
@@ -15,11 +16,51 @@ type Post struct {
 	User      User           `gorm:"foreignKey:UserID" json:"user"`
 	Tags      []Tag      	 `gorm:"many2many:post_tags;" json:"tags"`
 	Comments  []Comment      `gorm:"foreignKey:PostID" json:"comments"`
+	Attachments []MediaAttachment `gorm:"foreignKey:PostID" json:"attachments"`
+	Mentions    []Mention         `gorm:"foreignKey:PostID" json:"mentions"`
+	Kind        string            `gorm:"size:10;default:post" json:"kind"`
+	RepostOfID  *uint             `json:"repost_of_id"`
+	RepostOf    *Post             `gorm:"foreignKey:RepostOfID" json:"repost_of,omitempty"`
+	QuoteOfID   *uint             `json:"quote_of_id"`
+	QuoteOf     *Post             `gorm:"foreignKey:QuoteOfID" json:"quote_of,omitempty"`
+	CommentsCount int64           `gorm:"->;-:migration" json:"comments_count"`
+	RepostsCount int64            `gorm:"->;-:migration" json:"reposts_count"`
+	QuotesCount  int64            `gorm:"->;-:migration" json:"quotes_count"`
+	Status      string            `gorm:"size:10;default:published" json:"status"`
+	PublishAt   *time.Time        `json:"publish_at"`
 	Version   int            `gorm:"default:1" json:"version"`
+	// EditedAt is when the title or content last changed after publishing
+	EditedAt  *time.Time     `json:"edited_at"`
+	// DeletedAt puts a post in the trash; GORM leaves trashed posts out of
+	// every query that isn't Unscoped
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+	DeletedByID *uint          `json:"-"`
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 }
 
+// Post kinds. A repost reshares RepostOf as is and has no content of its
+// own; a quote is a post about QuoteOf, which is nil once the original is
+// deleted.
+const (
+	PostKindPost   = "post"
+	PostKindRepost = "repost"
+	PostKindQuote  = "quote"
+)
+
+// Post statuses. Only published posts are public; drafts and scheduled
+// posts are seen by their author alone. CreatedAt of a post that was not
+// published right away is reset to when it went public.
+const (
+	PostStatusDraft     = "draft"
+	PostStatusScheduled = "scheduled"
+	PostStatusPublished = "published"
+)
+
+// scheduledPublishLock is the advisory lock key held while publishing due
+// posts, so one API instance does it at a time.
+const scheduledPublishLock = 4_202_001
+
 type PostStore struct {
 	db *gorm.DB
 }
@@ -37,12 +78,16 @@ func (s *PostStore) Create(ctx context.Context, post *Post) error {
 func (s *PostStore) GetByID(ctx context.Context, id uint) (*Post, error) {
 	post := &Post{}
 	err := s.db.WithContext(ctx).
+				Scopes(withShares).
 				Preload("Tags").
+				Preload("Attachments", orderedAttachments).
+				Preload("Mentions", orderedMentions).
 				Preload("User").
 				Preload("User.Role").
 				Preload("Comments").
 				Preload("Comments.User").
 				Preload("Comments.User.Role").
+				Preload("Comments.Mentions", orderedMentions).
 				First(post, id).Error
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
@@ -54,27 +99,166 @@ func (s *PostStore) GetByID(ctx context.Context, id uint) (*Post, error) {
 }
 
 
+// Update saves a post's text and status if it is still at post.Version,
+// and returns ErrConflict if it isn't. When the title or content of a
+// published post change, the text being replaced is kept as a revision;
+// text written before publishing never was public and isn't kept.
+// Publishing a draft clears EditedAt: edits before then aren't edits to
+// the public.
 func (s *PostStore) Update(ctx context.Context,post *Post) error {
-	tx := s.db.WithContext(ctx).Model(&Post{}).
-		Where("id = ? AND version = ?", post.ID, post.Version).
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`
+			INSERT INTO post_revisions (post_id, version, title, content, created_at)
+			SELECT id, version, title, content, COALESCE(edited_at, created_at)
+			FROM posts
+			WHERE id = ? AND version = ? AND status = ? AND (title <> ? OR content <> ?)
+			ON CONFLICT (post_id, version) DO NOTHING`,
+			post.ID, post.Version, PostStatusPublished, post.Title, post.Content).Error
+		if err != nil {
+			return err
+		}
+
+		res := tx.Model(&Post{}).
+			Where("id = ? AND version = ?", post.ID, post.Version).
+			Updates(map[string]interface{}{
+				"title":   post.Title,
+				"content": post.Content,
+				"status":     post.Status,
+				"publish_at": post.PublishAt,
+				"created_at": post.CreatedAt,
+				"edited_at": gorm.Expr(
+					"CASE WHEN status <> ? AND ?::text = ? THEN NULL WHEN title <> ? OR content <> ? THEN NOW() ELSE edited_at END",
+					PostStatusPublished, post.Status, PostStatusPublished, post.Title, post.Content),
+				"version": post.Version + 1,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrConflict
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	post.Version++
+	return nil
+}
+
+// SetTags replaces the tags of a post.
+func (s *PostStore) SetTags(ctx context.Context, postID uint, tags []Tag) error {
+	post := &Post{ID: postID}
+	return s.db.WithContext(ctx).Model(post).Omit("Tags.*").Association("Tags").Replace(tags)
+}
+
+// Delete moves a post to the trash if it is still at version, recording
+// who deleted it. It returns ErrConflict if the post changed since.
+func (s *PostStore) Delete(ctx context.Context, postID uint, version int, deletedByID uint) error {
+	tx := s.db.WithContext(ctx).
+		Model(&Post{}).
+		Where("id = ? AND version = ?", postID, version).
 		Updates(map[string]interface{}{
-			"title":   post.Title,
-			"content": post.Content,
-			"version": post.Version + 1,
+			"deleted_at":    time.Now(),
+			"deleted_by_id": deletedByID,
 		})
-
 	if tx.Error != nil {
 		return tx.Error
 	}
+	if tx.RowsAffected > 0 {
+		return nil
+	}
 
-	post.Version++
+	err := s.db.WithContext(ctx).Select("id").Take(&Post{}, postID).Error
+	switch {
+	case err == nil:
+		return ErrConflict
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrNotFound
+	default:
+		return err
+	}
+}
+
+// Purge deletes a post for good, trashed or not.
+func (s *PostStore) Purge(ctx context.Context, postID uint) error {
+	tx := s.db.WithContext(ctx).Unscoped().Delete(&Post{}, postID)
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return ErrNotFound
+	}
 	return nil
 }
 
-// Delete a post
-func (s *PostStore) Delete(ctx context.Context, postID uint) error {
-	tx := s.db.WithContext(ctx).Delete(&Post{}, postID)
+// GetDeleted returns a post from the trash.
+func (s *PostStore) GetDeleted(ctx context.Context, postID uint) (*Post, error) {
+	post := &Post{}
+	err := s.db.WithContext(ctx).
+		Unscoped().
+		Where("id = ? AND deleted_at IS NOT NULL", postID).
+		First(post).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return post, nil
+}
+
+// GetTrash lists trashed posts, most recently deleted first. With a userID
+// it is limited to the posts that user deleted themselves; 0 lists all.
+func (s *PostStore) GetTrash(ctx context.Context, fq PaginatedFeedQuery, userID uint) ([]Post, error) {
+	var posts []Post
+
+	query := s.db.WithContext(ctx).
+		Unscoped().
+		Preload("User", authorColumns).
+		Preload("User.Role").
+		Where("posts.deleted_at IS NOT NULL")
+
+	if userID != 0 {
+		query = query.Where("posts.user_id = ? AND posts.deleted_by_id = ?", userID, userID)
+	}
+
+	if fq.Search != "" {
+		query = query.Where("title ILIKE ? OR content ILIKE ?", "%"+fq.Search+"%", "%"+fq.Search+"%")
+	}
+
+	if fq.Sort == "" {
+		fq.Sort = "desc"
+	}
+
+	err := query.
+		Order("posts.deleted_at " + fq.Sort).
+		Limit(fq.Limit).
+		Offset(fq.Offset).
+		Find(&posts).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
+
+// Restore takes a post out of the trash. It returns ErrConflict for a
+// repost when the user has reposted the original again since.
+func (s *PostStore) Restore(ctx context.Context, postID uint) error {
+	tx := s.db.WithContext(ctx).
+		Unscoped().
+		Model(&Post{}).
+		Where("id = ? AND deleted_at IS NOT NULL", postID).
+		Updates(map[string]interface{}{
+			"deleted_at":    nil,
+			"deleted_by_id": nil,
+		})
 	if tx.Error != nil {
+		if errors.Is(tx.Error, gorm.ErrDuplicatedKey) {
+			return ErrConflict
+		}
 		return tx.Error
 	}
 	if tx.RowsAffected == 0 {
@@ -83,33 +267,97 @@ func (s *PostStore) Delete(ctx context.Context, postID uint) error {
 	return nil
 }
 
+// PurgeDeleted deletes up to limit posts trashed before cutoff for good,
+// with their comments, and returns how many went.
+func (s *PostStore) PurgeDeleted(ctx context.Context, cutoff time.Time, limit int) (int64, error) {
+	var purged int64
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var ids []uint
+		err := purgeable(tx, cutoff, limit).Pluck("id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+
+		// comments have no foreign key to cascade; reposts of the purged
+		// posts go along with them
+		posts := tx.Unscoped().Model(&Post{}).Select("id").Where("id IN ? OR repost_of_id IN ?", ids, ids)
+		if err := tx.Unscoped().Where("post_id IN (?)", posts).Delete(&Comment{}).Error; err != nil {
+			return err
+		}
+
+		res := tx.Unscoped().Delete(&Post{}, ids)
+		purged = res.RowsAffected
+		return res.Error
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return purged, nil
+}
+
+// GetPurgeable lists the posts PurgeDeleted would purge next.
+func (s *PostStore) GetPurgeable(ctx context.Context, cutoff time.Time, limit int) ([]uint, error) {
+	var ids []uint
+	err := purgeable(s.db.WithContext(ctx), cutoff, limit).Pluck("id", &ids).Error
+	return ids, err
+}
+
+func purgeable(db *gorm.DB, cutoff time.Time, limit int) *gorm.DB {
+	return db.Unscoped().
+		Model(&Post{}).
+		Where("deleted_at < ?", cutoff).
+		Order("deleted_at").
+		Limit(limit)
+}
+
+// GetShareIDs lists the reposts and quotes of the posts, trashed ones
+// included, which show the original.
+func (s *PostStore) GetShareIDs(ctx context.Context, ids []uint) ([]uint, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	var shares []uint
+	err := s.db.WithContext(ctx).
+		Unscoped().
+		Model(&Post{}).
+		Where("repost_of_id IN ? OR quote_of_id IN ?", ids, ids).
+		Order("id").
+		Pluck("id", &shares).Error
+	return shares, err
+}
+
 func (s *PostStore) GetFeed(ctx context.Context, fq PaginatedFeedQuery) ([]Post, error) {
 	var posts []Post
 	
+	// reposts only show up in timelines of the reposter's followers
 	query := s.db.WithContext(ctx).
-		Preload("User").
+		Scopes(withShares, published).
+		Preload("User", authorColumns).
 		Preload("User.Role").
 		Preload("Tags").
-		Preload("Comments").
-		Preload("Comments.User").
-		Preload("Comments.User.Role")
+		Preload("Attachments", orderedAttachments).
+		Preload("Mentions", orderedMentions).
+		Where("posts.kind <> ?", PostKindRepost)
 
 	if fq.Search != "" {
 		query = query.Where("title ILIKE ? OR content ILIKE ?", "%"+fq.Search+"%", "%"+fq.Search+"%")
 	}
 
 	if len(fq.Tags) > 0 {
-		query = query.Joins("JOIN post_tags pt ON pt.post_id = posts.id").
-			Joins("JOIN tags t ON t.id = pt.tag_id").
-			Where("t.name IN ?", fq.Tags).
-			Distinct("posts.id") // Ensure we don't get duplicate posts if multiple tags match
+		query = query.Where(`EXISTS (
+			SELECT 1 FROM post_tags pt JOIN tags t ON t.id = pt.tag_id
+			WHERE pt.post_id = COALESCE(posts.repost_of_id, posts.id) AND t.name IN ?
+		)`, fq.Tags)
 	}
 
 	if fq.Since != "" {
-		query = query.Where("created_at >= ?", fq.Since)
+		query = query.Where("posts.created_at >= ?", fq.Since)
 	}
 	if fq.Until != "" {
-		query = query.Where("created_at <= ?", fq.Until)
+		query = query.Where("posts.created_at <= ?", fq.Until)
 	}
 
 	if fq.Sort == "" {
@@ -135,6 +383,7 @@ func (s *PostStore) GetByTagID(ctx context.Context, fq PaginatedFeedQuery, TagID
 	
 	query := s.db.WithContext(ctx).
 		Model(&Post{}).
+		Scopes(withShares, published).
 		Joins("JOIN post_tags pt ON pt.post_id = posts.id").
 		Where("pt.tag_id = ?", TagID)
 
@@ -143,10 +392,10 @@ func (s *PostStore) GetByTagID(ctx context.Context, fq PaginatedFeedQuery, TagID
 	}
 
 	if fq.Since != "" {
-		query = query.Where("created_at >= ?", fq.Since)
+		query = query.Where("posts.created_at >= ?", fq.Since)
 	}
 	if fq.Until != "" {
-		query = query.Where("created_at <= ?", fq.Until)
+		query = query.Where("posts.created_at <= ?", fq.Until)
 	}
 
 	if fq.Sort == "" {
@@ -154,12 +403,11 @@ func (s *PostStore) GetByTagID(ctx context.Context, fq PaginatedFeedQuery, TagID
 	}
 
 	err := query.
-		Preload("User").
+		Preload("User", authorColumns).
 		Preload("User.Role").
 		Preload("Tags").
-		Preload("Comments").
-		Preload("Comments.User").
-		Preload("Comments.User.Role").
+		Preload("Attachments", orderedAttachments).
+		Preload("Mentions", orderedMentions).
 		Order("posts.created_at " + fq.Sort).
 		Limit(fq.Limit).
 		Offset(fq.Offset).
@@ -176,30 +424,30 @@ func (s *PostStore) GetOneUserFeed(ctx context.Context, fq PaginatedFeedQuery, U
 	var posts []Post
 	
 	query := s.db.WithContext(ctx).
-		Preload("User").
+		Preload("User", authorColumns).
 		Preload("User.Role").
 		Preload("Tags").
-		Preload("Comments").
-		Preload("Comments.User").
-		Preload("Comments.User.Role").
-		Where("user_id = ?", UserID)
+		Preload("Attachments", orderedAttachments).
+		Preload("Mentions", orderedMentions).
+		Scopes(withShares, published).
+		Where("posts.user_id = ?", UserID)
 
 	if fq.Search != "" {
 		query = query.Where("title ILIKE ? OR content ILIKE ?", "%"+fq.Search+"%", "%"+fq.Search+"%")
 	}
 
 	if len(fq.Tags) > 0 {
-		query = query.Joins("JOIN post_tags pt ON pt.post_id = posts.id").
-			Joins("JOIN tags t ON t.id = pt.tag_id").
-			Where("t.name IN ?", fq.Tags).
-			Distinct("posts.id") // Ensure we don't get duplicate posts if multiple tags match
+		query = query.Where(`EXISTS (
+			SELECT 1 FROM post_tags pt JOIN tags t ON t.id = pt.tag_id
+			WHERE pt.post_id = COALESCE(posts.repost_of_id, posts.id) AND t.name IN ?
+		)`, fq.Tags)
 	}
 
 	if fq.Since != "" {
-		query = query.Where("created_at >= ?", fq.Since)
+		query = query.Where("posts.created_at >= ?", fq.Since)
 	}
 	if fq.Until != "" {
-		query = query.Where("created_at <= ?", fq.Until)
+		query = query.Where("posts.created_at <= ?", fq.Until)
 	}
 
 	if fq.Sort == "" {
@@ -218,4 +466,350 @@ func (s *PostStore) GetOneUserFeed(ctx context.Context, fq PaginatedFeedQuery, U
 	}
 
 	return posts, nil
-}
\ No newline at end of file
+}
+
+// GetFollowedTagsFeed returns posts carrying any tag the user follows. A
+// post with several followed tags is listed once.
+func (s *PostStore) GetFollowedTagsFeed(ctx context.Context, fq PaginatedFeedQuery, userID uint) ([]Post, error) {
+	var posts []Post
+
+	query := s.db.WithContext(ctx).
+		Preload("User", authorColumns).
+		Preload("User.Role").
+		Preload("Tags").
+		Preload("Attachments", orderedAttachments).
+		Preload("Mentions", orderedMentions).
+		Scopes(withShares, published).
+		Where(`EXISTS (
+			SELECT 1 FROM post_tags pt JOIN tag_followers tf ON tf.tag_id = pt.tag_id
+			WHERE pt.post_id = posts.id AND tf.user_id = ?
+		)`, userID)
+
+	if fq.Search != "" {
+		query = query.Where("title ILIKE ? OR content ILIKE ?", "%"+fq.Search+"%", "%"+fq.Search+"%")
+	}
+
+	if len(fq.Tags) > 0 {
+		query = query.Where(`EXISTS (
+			SELECT 1 FROM post_tags pt JOIN tags t ON t.id = pt.tag_id
+			WHERE pt.post_id = COALESCE(posts.repost_of_id, posts.id) AND t.name IN ?
+		)`, fq.Tags)
+	}
+
+	if fq.Since != "" {
+		query = query.Where("posts.created_at >= ?", fq.Since)
+	}
+	if fq.Until != "" {
+		query = query.Where("posts.created_at <= ?", fq.Until)
+	}
+
+	if fq.Sort == "" {
+		fq.Sort = "desc"
+	}
+
+	err := query.
+		Order("posts.created_at " + fq.Sort).
+		Limit(fq.Limit).
+		Offset(fq.Offset).
+		Find(&posts).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
+
+// GetByIDs loads posts for a list such as trending, in no particular order.
+// Missing IDs are skipped.
+func (s *PostStore) GetByIDs(ctx context.Context, ids []uint) ([]Post, error) {
+	var posts []Post
+	if len(ids) == 0 {
+		return posts, nil
+	}
+
+	err := s.db.WithContext(ctx).
+		Preload("User", authorColumns).
+		Preload("User.Role").
+		Preload("Tags").
+		Preload("Attachments", orderedAttachments).
+		Preload("Mentions", orderedMentions).
+		Scopes(withShares, published).
+		Where("posts.id IN ?", ids).
+		Find(&posts).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
+
+// GetTimeline returns posts and reposts by the users userID follows and by
+// userID.
+func (s *PostStore) GetTimeline(ctx context.Context, fq PaginatedFeedQuery, userID uint) ([]Post, error) {
+	var posts []Post
+
+	query := s.db.WithContext(ctx).
+		Scopes(withShares, published).
+		Preload("User", authorColumns).
+		Preload("User.Role").
+		Preload("Tags").
+		Preload("Attachments", orderedAttachments).
+		Preload("Mentions", orderedMentions).
+		Where("posts.user_id = ? OR posts.user_id IN (SELECT user_id FROM followers WHERE follower_id = ?)", userID, userID)
+
+	if fq.Search != "" {
+		query = query.Where("title ILIKE ? OR content ILIKE ?", "%"+fq.Search+"%", "%"+fq.Search+"%")
+	}
+
+	if len(fq.Tags) > 0 {
+		query = query.Where(`EXISTS (
+			SELECT 1 FROM post_tags pt JOIN tags t ON t.id = pt.tag_id
+			WHERE pt.post_id = COALESCE(posts.repost_of_id, posts.id) AND t.name IN ?
+		)`, fq.Tags)
+	}
+
+	if fq.Since != "" {
+		query = query.Where("posts.created_at >= ?", fq.Since)
+	}
+	if fq.Until != "" {
+		query = query.Where("posts.created_at <= ?", fq.Until)
+	}
+
+	if fq.Sort == "" {
+		fq.Sort = "desc"
+	}
+
+	err := query.
+		Order("posts.created_at " + fq.Sort).
+		Limit(fq.Limit).
+		Offset(fq.Offset).
+		Find(&posts).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
+
+// TimelineEntry is a post on a timeline and the time it is ordered by.
+type TimelineEntry struct {
+	ID        uint
+	CreatedAt time.Time
+}
+
+// GetTimelineEntries lists the newest posts on userID's timeline, the
+// same ones GetTimeline returns, without loading them.
+func (s *PostStore) GetTimelineEntries(ctx context.Context, userID uint, limit int) ([]TimelineEntry, error) {
+	var entries []TimelineEntry
+	err := s.db.WithContext(ctx).
+		Model(&Post{}).
+		Scopes(published).
+		Select("posts.id, posts.created_at").
+		Where("posts.user_id = ? OR posts.user_id IN (SELECT user_id FROM followers WHERE follower_id = ?)", userID, userID).
+		Order("posts.created_at DESC, posts.id DESC").
+		Limit(limit).
+		Scan(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// GetEntriesByUsers lists the newest posts by any of userIDs.
+func (s *PostStore) GetEntriesByUsers(ctx context.Context, userIDs []uint, limit int) ([]TimelineEntry, error) {
+	var entries []TimelineEntry
+	if len(userIDs) == 0 {
+		return entries, nil
+	}
+
+	err := s.db.WithContext(ctx).
+		Model(&Post{}).
+		Scopes(published).
+		Select("posts.id, posts.created_at").
+		Where("posts.user_id IN ?", userIDs).
+		Order("posts.created_at DESC, posts.id DESC").
+		Limit(limit).
+		Scan(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// GetDrafts lists a user's drafts and scheduled posts, most recently
+// edited first.
+func (s *PostStore) GetDrafts(ctx context.Context, fq PaginatedFeedQuery, userID uint) ([]Post, error) {
+	var posts []Post
+
+	query := s.db.WithContext(ctx).
+		Scopes(withShares).
+		Preload("User", authorColumns).
+		Preload("User.Role").
+		Preload("Tags").
+		Preload("Attachments", orderedAttachments).
+		Preload("Mentions", orderedMentions).
+		Where("posts.user_id = ? AND posts.status <> ?", userID, PostStatusPublished)
+
+	if fq.Search != "" {
+		query = query.Where("title ILIKE ? OR content ILIKE ?", "%"+fq.Search+"%", "%"+fq.Search+"%")
+	}
+
+	if fq.Sort == "" {
+		fq.Sort = "desc"
+	}
+
+	err := query.
+		Order("posts.updated_at " + fq.Sort).
+		Limit(fq.Limit).
+		Offset(fq.Offset).
+		Find(&posts).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
+
+// PublishDue publishes scheduled posts whose time has come and returns
+// their IDs. Each post is returned by exactly one call, however many API
+// instances run it: the advisory lock keeps them from overlapping and the
+// status check from publishing twice. Another instance holding the lock
+// makes this a no-op. Published posts are left pending announcement until
+// MarkAnnounced.
+func (s *PostStore) PublishDue(ctx context.Context, now time.Time, limit int) ([]uint, error) {
+	var ids []uint
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var locked bool
+		if err := tx.Raw("SELECT pg_try_advisory_xact_lock(?)", scheduledPublishLock).Scan(&locked).Error; err != nil {
+			return err
+		}
+		if !locked {
+			return nil
+		}
+
+		return tx.Raw(`
+			UPDATE posts
+			SET status = ?, created_at = publish_at, edited_at = NULL, updated_at = NOW(), announce_pending = TRUE
+			WHERE id IN (
+				SELECT id FROM posts
+				WHERE status = ? AND publish_at <= ? AND deleted_at IS NULL
+				ORDER BY publish_at
+				LIMIT ?
+			)
+			RETURNING id`, PostStatusPublished, PostStatusScheduled, now, limit).Scan(&ids).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// GetUnannounced lists published posts whose mentions and timelines
+// still have to be handled, oldest first. Trashed posts are skipped.
+func (s *PostStore) GetUnannounced(ctx context.Context, limit int) ([]uint, error) {
+	var ids []uint
+	err := s.db.WithContext(ctx).
+		Model(&Post{}).
+		Where("announce_pending AND status = ?", PostStatusPublished).
+		Order("id").
+		Limit(limit).
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+func (s *PostStore) MarkAnnounced(ctx context.Context, postID uint) error {
+	return s.db.WithContext(ctx).
+		Unscoped().
+		Model(&Post{}).
+		Where("id = ?", postID).
+		UpdateColumn("announce_pending", false).Error
+}
+
+// Repost reshares a post for userID. It returns ErrConflict if the user
+// already reposted it and ErrNotFound if the post doesn't exist or is in
+// the trash.
+func (s *PostStore) Repost(ctx context.Context, userID, postID uint) (*Post, error) {
+	// the foreign key still accepts a post in the trash
+	if err := s.db.WithContext(ctx).Select("id").Take(&Post{}, postID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	repost := &Post{UserID: userID, Kind: PostKindRepost, RepostOfID: &postID}
+	if err := s.db.WithContext(ctx).Create(repost).Error; err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrDuplicatedKey):
+			return nil, ErrConflict
+		case errors.Is(err, gorm.ErrForeignKeyViolated):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+	return repost, nil
+}
+
+// Unrepost returns ErrNotFound if the user hasn't reposted the post.
+func (s *PostStore) Unrepost(ctx context.Context, userID, postID uint) error {
+	tx := s.db.WithContext(ctx).
+		Unscoped().
+		Where("user_id = ? AND repost_of_id = ? AND deleted_at IS NULL", userID, postID).
+		Delete(&Post{})
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// postColumns counts how often each post was reposted, quoted and
+// commented on.
+const postColumns = `posts.*,
+	(SELECT COUNT(*) FROM comments c WHERE c.post_id = posts.id AND c.deleted_at IS NULL) AS comments_count,
+	(SELECT COUNT(*) FROM posts r WHERE r.repost_of_id = posts.id AND r.deleted_at IS NULL) AS reposts_count,
+	(SELECT COUNT(*) FROM posts q WHERE q.quote_of_id = posts.id AND q.deleted_at IS NULL) AS quotes_count`
+
+// withShares fills the share counts and loads the post a repost or quote
+// refers to.
+func withShares(db *gorm.DB) *gorm.DB {
+	return db.Select(postColumns).
+		Preload("RepostOf", sharedPost).
+		Preload("RepostOf.User", authorColumns).
+		Preload("RepostOf.User.Role").
+		Preload("RepostOf.Tags").
+		Preload("RepostOf.Attachments", orderedAttachments).
+		Preload("RepostOf.Mentions", orderedMentions).
+		Preload("QuoteOf", sharedPost).
+		Preload("QuoteOf.User", authorColumns).
+		Preload("QuoteOf.User.Role")
+}
+
+// published limits a query to public posts, leaving out reposts of posts
+// in the trash.
+func published(db *gorm.DB) *gorm.DB {
+	return db.Where("posts.status = ?", PostStatusPublished).
+		Where("posts.repost_of_id IS NULL OR EXISTS (SELECT 1 FROM posts o WHERE o.id = posts.repost_of_id AND o.deleted_at IS NULL)")
+}
+
+// authorColumns loads only what lists show of a post's author.
+func authorColumns(db *gorm.DB) *gorm.DB {
+	return db.Select("id", "username", "display_name", "avatar_url", "role_id")
+}
+
+func sharedPost(db *gorm.DB) *gorm.DB {
+	return db.Select(postColumns)
+}
+
+func orderedAttachments(db *gorm.DB) *gorm.DB {
+	return db.Order("position").Preload("Variants")
+}
+
+func orderedMentions(db *gorm.DB) *gorm.DB {
+	return db.Order("start_offset").Preload("User")
+}
@@ -0,0 +1,158 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const (
+	benchUsers           = 50
+	benchPosts           = 200
+	benchCommentsPerPost = 20
+)
+
+// BenchmarkFeed compares the feed queries before and after comment counts
+// moved into a subquery. It needs a migrated database to seed, given by
+// BENCH_DB_ADDR; the seed data is rolled back afterwards.
+//
+//	BENCH_DB_ADDR=postgres://... go test ./internal/store -run '^$' -bench Feed
+func BenchmarkFeed(b *testing.B) {
+	addr := os.Getenv("BENCH_DB_ADDR")
+	if addr == "" {
+		b.Skip("BENCH_DB_ADDR is not set")
+	}
+
+	db, err := gorm.Open(postgres.Open(addr), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	var queries atomic.Int64
+	err = db.Callback().Query().After("gorm:query").Register("bench:count", func(*gorm.DB) {
+		queries.Add(1)
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	tx := db.Begin()
+	defer tx.Rollback()
+	if err := seedFeed(tx); err != nil {
+		b.Fatal(err)
+	}
+
+	ctx := context.Background()
+	fq := PaginatedFeedQuery{Limit: 20, Sort: "desc"}
+	store := &PostStore{db: tx}
+
+	run := func(name string, feed func() ([]Post, error)) {
+		b.Run(name, func(b *testing.B) {
+			queries.Store(0)
+			for b.Loop() {
+				posts, err := feed()
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(posts) != fq.Limit {
+					b.Fatalf("got %d posts, want %d", len(posts), fq.Limit)
+				}
+			}
+			b.ReportMetric(float64(queries.Load())/float64(b.N), "queries/op")
+		})
+	}
+
+	run("preloaded comments", func() ([]Post, error) { return preloadedCommentsFeed(ctx, tx, fq) })
+	run("comments count", func() ([]Post, error) { return store.GetFeed(ctx, fq) })
+}
+
+// preloadedColumns is postColumns as it was, without the comment count.
+const preloadedColumns = `posts.*,
+	(SELECT COUNT(*) FROM posts r WHERE r.repost_of_id = posts.id AND r.deleted_at IS NULL) AS reposts_count,
+	(SELECT COUNT(*) FROM posts q WHERE q.quote_of_id = posts.id AND q.deleted_at IS NULL) AS quotes_count`
+
+// preloadedCommentsFeed is GetFeed as it was, loading every comment and
+// its author to count them.
+func preloadedCommentsFeed(ctx context.Context, db *gorm.DB, fq PaginatedFeedQuery) ([]Post, error) {
+	shared := func(db *gorm.DB) *gorm.DB { return db.Select(preloadedColumns) }
+
+	var posts []Post
+	err := db.WithContext(ctx).
+		Select(preloadedColumns).
+		Preload("RepostOf", shared).
+		Preload("RepostOf.User").
+		Preload("RepostOf.User.Role").
+		Preload("RepostOf.Tags").
+		Preload("RepostOf.Attachments", orderedAttachments).
+		Preload("RepostOf.Mentions", orderedMentions).
+		Preload("RepostOf.Comments").
+		Preload("QuoteOf", shared).
+		Preload("QuoteOf.User").
+		Preload("QuoteOf.User.Role").
+		Scopes(published).
+		Preload("User").
+		Preload("User.Role").
+		Preload("Tags").
+		Preload("Attachments", orderedAttachments).
+		Preload("Mentions", orderedMentions).
+		Preload("Comments").
+		Preload("Comments.User").
+		Preload("Comments.User.Role").
+		Where("posts.kind <> ?", PostKindRepost).
+		Order("posts.created_at " + fq.Sort).
+		Limit(fq.Limit).
+		Offset(fq.Offset).
+		Find(&posts).Error
+	return posts, err
+}
+
+func seedFeed(tx *gorm.DB) error {
+	var role Role
+	if err := tx.Order("level").First(&role).Error; err != nil {
+		return err
+	}
+
+	users := make([]User, 0, benchUsers)
+	for i := range benchUsers {
+		users = append(users, User{
+			Email:    fmt.Sprintf("bench-%d@example.com", i),
+			Username: fmt.Sprintf("bench-%d", i),
+			Password: []byte("-"),
+			IsActive: true,
+			RoleID:   role.ID,
+		})
+	}
+	if err := tx.Omit("Role").CreateInBatches(&users, 100).Error; err != nil {
+		return err
+	}
+
+	posts := make([]Post, 0, benchPosts)
+	for i := range benchPosts {
+		posts = append(posts, Post{
+			Title:   fmt.Sprintf("post %d", i),
+			Content: "seeded for the feed benchmark",
+			UserID:  users[i%benchUsers].ID,
+		})
+	}
+	if err := tx.Omit("User", "Tags").CreateInBatches(&posts, 100).Error; err != nil {
+		return err
+	}
+
+	comments := make([]Comment, 0, benchPosts*benchCommentsPerPost)
+	for i, p := range posts {
+		for j := range benchCommentsPerPost {
+			comments = append(comments, Comment{
+				PostID:  p.ID,
+				UserID:  users[(i+j)%benchUsers].ID,
+				Content: "seeded comment",
+			})
+		}
+	}
+	return tx.Omit("Post", "User").CreateInBatches(&comments, 500).Error
+}
@@ -0,0 +1,294 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"gorm.io/gorm"
+)
+
+var _ = Describe("PostStore", func() {
+	var (
+		ctx    context.Context
+		tx     *gorm.DB
+		posts  *PostStore
+		author *User
+		reader *User
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		tx = testDB()
+		posts = &PostStore{db: tx}
+		author = seedUser(tx, "gopher")
+		reader = seedUser(tx, "reader")
+	})
+
+	entryIDs := func(userID uint) []uint {
+		entries, err := posts.GetEntriesByUsers(ctx, []uint{userID}, 10)
+		Expect(err).NotTo(HaveOccurred())
+		ids := make([]uint, 0, len(entries))
+		for _, e := range entries {
+			ids = append(ids, e.ID)
+		}
+		return ids
+	}
+
+	Describe("Repost", func() {
+		It("reposts a post once per user", func() {
+			original := seedPost(tx, author)
+
+			repost, err := posts.Repost(ctx, reader.ID, original.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*repost.RepostOfID).To(Equal(original.ID))
+
+			_, err = posts.Repost(ctx, reader.ID, original.ID)
+			Expect(err).To(MatchError(ErrConflict))
+		})
+
+		It("won't repost a post in the trash", func() {
+			original := seedPost(tx, author)
+			Expect(posts.Delete(ctx, original.ID, original.Version, author.ID)).To(Succeed())
+
+			_, err := posts.Repost(ctx, reader.ID, original.ID)
+			Expect(err).To(MatchError(ErrNotFound))
+		})
+
+		It("hides reposts of a post once it is trashed", func() {
+			original := seedPost(tx, author)
+			repost, err := posts.Repost(ctx, reader.ID, original.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entryIDs(reader.ID)).To(Equal([]uint{repost.ID}))
+
+			Expect(posts.Delete(ctx, original.ID, original.Version, author.ID)).To(Succeed())
+			Expect(entryIDs(reader.ID)).To(BeEmpty())
+		})
+	})
+
+	Describe("Unrepost", func() {
+		It("removes the repost so the post can be reposted again", func() {
+			original := seedPost(tx, author)
+			_, err := posts.Repost(ctx, reader.ID, original.ID)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(posts.Unrepost(ctx, reader.ID, original.ID)).To(Succeed())
+			Expect(posts.Unrepost(ctx, reader.ID, original.ID)).To(MatchError(ErrNotFound))
+
+			_, err = posts.Repost(ctx, reader.ID, original.ID)
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	Describe("quotes", func() {
+		It("loads a quote without the quoted post once it is trashed", func() {
+			original := seedPost(tx, author)
+			quote := &Post{Title: "quote", Content: "look", UserID: reader.ID, Kind: PostKindQuote, QuoteOfID: &original.ID}
+			Expect(posts.Create(ctx, quote)).To(Succeed())
+
+			loaded, err := posts.GetByID(ctx, quote.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loaded.QuoteOf).NotTo(BeNil())
+
+			Expect(posts.Delete(ctx, original.ID, original.Version, author.ID)).To(Succeed())
+
+			loaded, err = posts.GetByID(ctx, quote.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loaded.QuoteOf).To(BeNil())
+			Expect(*loaded.QuoteOfID).To(Equal(original.ID))
+		})
+	})
+
+	Describe("Delete", func() {
+		It("refuses to trash a post that changed since it was read", func() {
+			post := seedPost(tx, author)
+			read := post.Version
+			Expect(tx.Model(&Post{}).Where("id = ?", post.ID).Update("version", read+1).Error).To(Succeed())
+
+			Expect(posts.Delete(ctx, post.ID, read, author.ID)).To(MatchError(ErrConflict))
+			Expect(posts.Delete(ctx, post.ID, read+1, author.ID)).To(Succeed())
+			Expect(posts.Delete(ctx, post.ID, read+1, author.ID)).To(MatchError(ErrNotFound))
+		})
+	})
+
+	Describe("GetShareIDs", func() {
+		It("lists reposts and quotes, trashed ones included", func() {
+			original := seedPost(tx, author)
+			repost, err := posts.Repost(ctx, reader.ID, original.ID)
+			Expect(err).NotTo(HaveOccurred())
+			quote := &Post{Title: "quote", Content: "look", UserID: reader.ID, Kind: PostKindQuote, QuoteOfID: &original.ID, Status: PostStatusPublished}
+			Expect(tx.Omit("User", "Tags").Create(quote).Error).To(Succeed())
+			Expect(posts.Delete(ctx, quote.ID, quote.Version, reader.ID)).To(Succeed())
+			seedPost(tx, author)
+
+			Expect(posts.GetShareIDs(ctx, []uint{original.ID})).To(Equal([]uint{repost.ID, quote.ID}))
+		})
+	})
+
+	Describe("PublishDue", func() {
+		schedule := func(post *Post, publishAt time.Time) {
+			Expect(tx.Model(post).Updates(map[string]interface{}{
+				"status":     PostStatusScheduled,
+				"publish_at": publishAt,
+			}).Error).To(Succeed())
+		}
+
+		It("publishes scheduled posts that are due, once", func() {
+			now := time.Now().UTC().Truncate(time.Second)
+			due := seedPost(tx, author)
+			schedule(due, now.Add(-time.Minute))
+			notYet := seedPost(tx, author)
+			schedule(notYet, now.Add(time.Hour))
+			draft := seedPost(tx, author)
+			Expect(tx.Model(draft).Update("status", PostStatusDraft).Error).To(Succeed())
+
+			ids, err := posts.PublishDue(ctx, now, 10)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ids).To(Equal([]uint{due.ID}))
+
+			published, err := posts.GetByID(ctx, due.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(published.Status).To(Equal(PostStatusPublished))
+			Expect(published.CreatedAt).To(BeTemporally("==", now.Add(-time.Minute)))
+
+			ids, err = posts.PublishDue(ctx, now, 10)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ids).To(BeEmpty())
+		})
+
+		It("skips scheduled posts in the trash", func() {
+			now := time.Now()
+			trashed := seedPost(tx, author)
+			schedule(trashed, now.Add(-time.Minute))
+			Expect(posts.Delete(ctx, trashed.ID, trashed.Version, author.ID)).To(Succeed())
+
+			ids, err := posts.PublishDue(ctx, now, 10)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ids).To(BeEmpty())
+		})
+
+		It("leaves published posts pending announcement until marked", func() {
+			now := time.Now()
+			due := seedPost(tx, author)
+			schedule(due, now.Add(-time.Minute))
+			seedPost(tx, author)
+
+			_, err := posts.PublishDue(ctx, now, 10)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(posts.GetUnannounced(ctx, 10)).To(Equal([]uint{due.ID}))
+
+			Expect(posts.MarkAnnounced(ctx, due.ID)).To(Succeed())
+			Expect(posts.GetUnannounced(ctx, 10)).To(BeEmpty())
+		})
+	})
+
+	Describe("Update", func() {
+		var revisions *PostRevisionStore
+
+		BeforeEach(func() {
+			revisions = &PostRevisionStore{db: tx}
+		})
+
+		edit := func(post *Post, content string) error {
+			post.Content = content
+			return posts.Update(ctx, post)
+		}
+
+		kept := func(postID uint) []string {
+			list, err := revisions.GetByPostID(ctx, postID, PaginatedFeedQuery{Limit: 10, Sort: "asc"})
+			Expect(err).NotTo(HaveOccurred())
+			contents := make([]string, 0, len(list))
+			for _, r := range list {
+				contents = append(contents, r.Content)
+			}
+			return contents
+		}
+
+		It("keeps the replaced text of a published post", func() {
+			post := seedPost(tx, author)
+			Expect(edit(post, "second")).To(Succeed())
+			Expect(edit(post, "third")).To(Succeed())
+
+			Expect(kept(post.ID)).To(Equal([]string{"content", "second"}))
+
+			revision, err := revisions.Get(ctx, post.ID, 2)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(revision.Content).To(Equal("second"))
+		})
+
+		It("keeps nothing written before publishing", func() {
+			post := seedPost(tx, author)
+			Expect(tx.Model(post).Update("status", PostStatusDraft).Error).To(Succeed())
+			post.Status = PostStatusDraft
+
+			Expect(edit(post, "private thoughts")).To(Succeed())
+			post.Status = PostStatusPublished
+			Expect(edit(post, "public")).To(Succeed())
+			Expect(kept(post.ID)).To(BeEmpty())
+
+			Expect(edit(post, "edited")).To(Succeed())
+			Expect(kept(post.ID)).To(Equal([]string{"public"}))
+		})
+
+		It("refuses a stale version and keeps no revision", func() {
+			post := seedPost(tx, author)
+			stale := *post
+			Expect(edit(post, "second")).To(Succeed())
+
+			Expect(edit(&stale, "lost")).To(MatchError(ErrConflict))
+			Expect(kept(post.ID)).To(Equal([]string{"content"}))
+
+			_, err := revisions.Get(ctx, post.ID, 5)
+			Expect(err).To(MatchError(ErrNotFound))
+		})
+	})
+
+	Describe("PurgeDeleted", func() {
+		comment := func(post *Post) *Comment {
+			c := &Comment{PostID: post.ID, UserID: reader.ID, Content: "nice"}
+			Expect(tx.Omit("Post", "User").Create(c).Error).To(Succeed())
+			return c
+		}
+
+		trash := func(post *Post, at time.Time) {
+			Expect(tx.Model(post).Updates(map[string]interface{}{"deleted_at": at, "deleted_by_id": author.ID}).Error).To(Succeed())
+		}
+
+		commentIDs := func() []uint {
+			var ids []uint
+			Expect(tx.Unscoped().Model(&Comment{}).Where("user_id = ?", reader.ID).Order("id").Pluck("id", &ids).Error).To(Succeed())
+			return ids
+		}
+
+		It("deletes old trash with its reposts and their comments", func() {
+			now := time.Now()
+			old := seedPost(tx, author)
+			comment(old)
+			repost, err := posts.Repost(ctx, reader.ID, old.ID)
+			Expect(err).NotTo(HaveOccurred())
+			comment(repost)
+			trash(old, now.Add(-40*24*time.Hour))
+
+			recent := seedPost(tx, author)
+			onRecent := comment(recent)
+			trash(recent, now.Add(-time.Hour))
+
+			live := seedPost(tx, author)
+			onLive := comment(live)
+
+			n, err := posts.PurgeDeleted(ctx, now.Add(-30*24*time.Hour), 10)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(n).To(Equal(int64(1)))
+
+			Expect(commentIDs()).To(Equal([]uint{onRecent.ID, onLive.ID}))
+			var left []uint
+			Expect(tx.Unscoped().Model(&Post{}).Where("id IN ?", []uint{old.ID, repost.ID, recent.ID, live.ID}).Order("id").Pluck("id", &left).Error).To(Succeed())
+			Expect(left).To(Equal([]uint{recent.ID, live.ID}))
+
+			n, err = posts.PurgeDeleted(ctx, now.Add(-30*24*time.Hour), 10)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(n).To(BeZero())
+		})
+	})
+})
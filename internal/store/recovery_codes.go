@@ -0,0 +1,73 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RecoveryCode is a single-use fallback for a lost TOTP device. Only the
+// SHA-256 of the code is kept; the codes are random so a slow hash buys nothing.
+type RecoveryCode struct {
+	ID        uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    uint       `json:"user_id"`
+	CodeHash  string     `json:"-"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (RecoveryCode) TableName() string {
+	return "user_recovery_codes"
+}
+
+type RecoveryCodeStore struct {
+	db *gorm.DB
+}
+
+func NewRecoveryCodeStore(db *gorm.DB) *RecoveryCodeStore {
+	return &RecoveryCodeStore{db: db}
+}
+
+// Replace drops the user's existing codes and stores the new set.
+func (s *RecoveryCodeStore) Replace(ctx context.Context, userID uint, codes []string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&RecoveryCode{}).Error; err != nil {
+			return err
+		}
+
+		rows := make([]RecoveryCode, 0, len(codes))
+		for _, c := range codes {
+			rows = append(rows, RecoveryCode{
+				UserID:   userID,
+				CodeHash: hashRecoveryCode(c),
+			})
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		return tx.Create(&rows).Error
+	})
+}
+
+// Consume marks a matching unused code as used, or returns ErrNotFound.
+func (s *RecoveryCodeStore) Consume(ctx context.Context, userID uint, code string) error {
+	tx := s.db.WithContext(ctx).Model(&RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hashRecoveryCode(code)).
+		Update("used_at", time.Now())
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(code))))
+	return hex.EncodeToString(sum[:])
+}
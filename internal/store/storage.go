@@ -12,40 +12,179 @@ var (
 )
 
 type Storage struct {
-	Posts interface {
-		GetByID(ctx context.Context, id uint) (*Post, error)
-		Create(ctx context.Context, post *Post) error
-		Delete(ctx context.Context, id uint) error
-		Update(ctx context.Context, post *Post) error
-		GetFeed(ctx context.Context, fq PaginatedFeedQuery) ([]Post, error)
-		GetOneUserFeed(ctx context.Context, fq PaginatedFeedQuery, UserID uint) ([]Post, error)
-		GetByTagID(ctx context.Context, fq PaginatedFeedQuery, TagID uint) ([]Post, error)
-	}
-	Users interface {
-		GetByID(ctx context.Context, id uint) (*User, error)
-		GetByEmail(ctx context.Context, email string) (*User, error)
-		Create(ctx context.Context, u *User, plain string) error
-		Activate(ctx context.Context, userID uint) error
-		UpdateUsername(ctx context.Context, user *User) error
-		UpdatePassword(ctx context.Context, user *User, plain string) error
-		Delete(ctx context.Context, id uint) error
-	}
-	Comments interface {
-		Create(ctx context.Context, c *Comment) error
-	}
-	Tags interface {
-		GetByID(ctx context.Context,id uint) (*Tag, error)
-		Create(ctx context.Context, tag *Tag) error
-		Delete(ctx context.Context, id uint) error
-		Get(ctx context.Context, fq PaginatedFeedQuery) ([]Tag, error)
-	}
-	Followers interface {
-		Follow(ctx context.Context, userID, followerID uint) error
-		Unfollow(ctx context.Context, followerID, userID uint) error
-	}
-	Roles interface {
-		GetByName(ctx context.Context, name string) (*Role, error)
-	}
+	Posts         Posts
+	PostRevisions PostRevisions
+	Users         Users
+	RecoveryCodes RecoveryCodes
+	AccessTokens  AccessTokens
+	Identities    Identities
+	Exports       Exports
+	Media         Media
+	Mentions      Mentions
+	Comments      Comments
+	Tags          Tags
+	Trending      Trending
+	TagFollowers  TagFollowers
+	Bookmarks     Bookmarks
+	Followers     Followers
+	Roles         Roles
+}
+
+type Posts interface {
+	GetByID(ctx context.Context, id uint) (*Post, error)
+	Create(ctx context.Context, post *Post) error
+	Delete(ctx context.Context, id uint, version int, deletedByID uint) error
+	Purge(ctx context.Context, id uint) error
+	GetDeleted(ctx context.Context, id uint) (*Post, error)
+	GetTrash(ctx context.Context, fq PaginatedFeedQuery, userID uint) ([]Post, error)
+	Restore(ctx context.Context, id uint) error
+	PurgeDeleted(ctx context.Context, cutoff time.Time, limit int) (int64, error)
+	Update(ctx context.Context, post *Post) error
+	SetTags(ctx context.Context, postID uint, tags []Tag) error
+	GetFeed(ctx context.Context, fq PaginatedFeedQuery) ([]Post, error)
+	GetOneUserFeed(ctx context.Context, fq PaginatedFeedQuery, UserID uint) ([]Post, error)
+	GetByTagID(ctx context.Context, fq PaginatedFeedQuery, TagID uint) ([]Post, error)
+	GetByIDs(ctx context.Context, ids []uint) ([]Post, error)
+	GetFollowedTagsFeed(ctx context.Context, fq PaginatedFeedQuery, userID uint) ([]Post, error)
+	GetTimeline(ctx context.Context, fq PaginatedFeedQuery, userID uint) ([]Post, error)
+	GetTimelineEntries(ctx context.Context, userID uint, limit int) ([]TimelineEntry, error)
+	GetEntriesByUsers(ctx context.Context, userIDs []uint, limit int) ([]TimelineEntry, error)
+	GetDrafts(ctx context.Context, fq PaginatedFeedQuery, userID uint) ([]Post, error)
+	PublishDue(ctx context.Context, now time.Time, limit int) ([]uint, error)
+	GetUnannounced(ctx context.Context, limit int) ([]uint, error)
+	MarkAnnounced(ctx context.Context, postID uint) error
+	Repost(ctx context.Context, userID, postID uint) (*Post, error)
+	Unrepost(ctx context.Context, userID, postID uint) error
+	GetPurgeable(ctx context.Context, cutoff time.Time, limit int) ([]uint, error)
+	GetShareIDs(ctx context.Context, ids []uint) ([]uint, error)
+}
+
+type PostRevisions interface {
+	GetByPostID(ctx context.Context, postID uint, fq PaginatedFeedQuery) ([]PostRevision, error)
+	Get(ctx context.Context, postID uint, version int) (*PostRevision, error)
+}
+
+type Users interface {
+	GetByID(ctx context.Context, id uint) (*User, error)
+	GetCredentials(ctx context.Context, id uint) (*User, error)
+	GetByEmail(ctx context.Context, email string) (*User, error)
+	GetByUsernames(ctx context.Context, usernames []string) ([]User, error)
+	Create(ctx context.Context, u *User, plain string) error
+	Activate(ctx context.Context, userID uint) error
+	UpdateUsername(ctx context.Context, user *User) error
+	UpdateEmail(ctx context.Context, user *User) error
+	UpdateProfile(ctx context.Context, user *User) error
+	UpdatePassword(ctx context.Context, user *User, plain string) error
+	Delete(ctx context.Context, id uint) error
+	GetPostIDs(ctx context.Context, userID uint) ([]uint, error)
+	RequestDeletion(ctx context.Context, userID uint, at time.Time) error
+	CancelDeletion(ctx context.Context, userID uint) error
+	GetDueForDeletion(ctx context.Context, cutoff time.Time, limit int) ([]uint, error)
+	SetTOTPSecret(ctx context.Context, userID uint, secret string) error
+	EnableTOTP(ctx context.Context, userID uint) error
+	DisableTOTP(ctx context.Context, userID uint) error
+	ConsumeTOTPStep(ctx context.Context, userID uint, step int64) error
+	ReserveTOTPAttempt(ctx context.Context, userID uint, limit int, window time.Duration) error
+	ResetTOTPFailures(ctx context.Context, userID uint) error
+}
+
+type RecoveryCodes interface {
+	Replace(ctx context.Context, userID uint, codes []string) error
+	Consume(ctx context.Context, userID uint, code string) error
+}
+
+type AccessTokens interface {
+	Create(ctx context.Context, token *AccessToken, plain string) error
+	GetByToken(ctx context.Context, plain string) (*AccessToken, error)
+	GetByUserID(ctx context.Context, userID uint) ([]AccessToken, error)
+	Delete(ctx context.Context, userID, tokenID uint) error
+	Touch(ctx context.Context, token *AccessToken) error
+}
+
+type Identities interface {
+	GetByProviderSubject(ctx context.Context, provider, subject string) (*UserIdentity, error)
+	GetByUserID(ctx context.Context, userID uint) ([]UserIdentity, error)
+	Create(ctx context.Context, identity *UserIdentity) error
+	Provision(ctx context.Context, user *User, plain string, identity *UserIdentity) error
+}
+
+type Exports interface {
+	GetUserData(ctx context.Context, userID uint) (*UserExport, error)
+}
+
+type Media interface {
+	Create(ctx context.Context, m *MediaAttachment) error
+	AttachToPost(ctx context.Context, userID, postID uint, items []MediaAttachment) error
+	GetByUserID(ctx context.Context, userID uint) ([]MediaAttachment, error)
+	GetOrphaned(ctx context.Context, cutoff time.Time, limit int) ([]MediaAttachment, error)
+	Delete(ctx context.Context, id uint) error
+	Claim(ctx context.Context, id uint, staleBefore time.Time) (*MediaAttachment, error)
+	GetUnprocessedIDs(ctx context.Context, staleBefore time.Time, limit int) ([]uint, error)
+	SaveProcessed(ctx context.Context, m *MediaAttachment, variants []MediaVariant) error
+	MarkFailed(ctx context.Context, id uint) error
+}
+
+type Mentions interface {
+	ReplaceForPost(ctx context.Context, postID uint, mentions []Mention) ([]uint, error)
+	ReplaceForComment(ctx context.Context, commentID uint, mentions []Mention) ([]uint, error)
+}
+
+type Comments interface {
+	Create(ctx context.Context, c *Comment) error
+	GetByID(ctx context.Context, id uint) (*Comment, error)
+	Delete(ctx context.Context, id, deletedByID uint) error
+	GetDeleted(ctx context.Context, id uint) (*Comment, error)
+	GetTrash(ctx context.Context, fq PaginatedFeedQuery, userID uint) ([]Comment, error)
+	Restore(ctx context.Context, id uint) error
+	PurgeDeleted(ctx context.Context, cutoff time.Time, limit int) (int64, error)
+}
+
+type Tags interface {
+	GetByID(ctx context.Context,id uint) (*Tag, error)
+	Create(ctx context.Context, tag *Tag) error
+	Delete(ctx context.Context, id uint) error
+	Get(ctx context.Context, fq PaginatedFeedQuery) ([]Tag, error)
+	GetByIDs(ctx context.Context, ids []uint) ([]Tag, error)
+	GetPostIDs(ctx context.Context, id uint) ([]uint, error)
+	Update(ctx context.Context, tag *Tag) error
+	Merge(ctx context.Context, sourceID, targetID uint) error
+	AddAlias(ctx context.Context, alias *TagAlias) error
+	DeleteAlias(ctx context.Context, tagID, aliasID uint) error
+}
+
+type Trending interface {
+	Posts(ctx context.Context, q TrendingQuery) ([]Scored, error)
+	Tags(ctx context.Context, q TrendingQuery) ([]Scored, error)
+}
+
+type TagFollowers interface {
+	Follow(ctx context.Context, userID, tagID uint) error
+	Unfollow(ctx context.Context, userID, tagID uint) error
+	GetFollowedTags(ctx context.Context, userID uint, fq PaginatedFeedQuery) ([]Tag, error)
+	GetStats(ctx context.Context, tagID, userID uint) (int64, bool, error)
+}
+
+type Bookmarks interface {
+	Add(ctx context.Context, b *Bookmark) error
+	Remove(ctx context.Context, userID, postID uint) error
+	GetPosts(ctx context.Context, userID uint, collectionID *uint, fq PaginatedFeedQuery) ([]Post, error)
+	GetBookmarked(ctx context.Context, userID uint, postIDs []uint) (map[uint]bool, error)
+	CreateCollection(ctx context.Context, col *BookmarkCollection) error
+	GetCollections(ctx context.Context, userID uint) ([]BookmarkCollection, error)
+	RenameCollection(ctx context.Context, col *BookmarkCollection) error
+	DeleteCollection(ctx context.Context, userID, id uint) error
+}
+
+type Followers interface {
+	Follow(ctx context.Context, followerID, userID uint) error
+	Unfollow(ctx context.Context, followerID, userID uint) error
+	GetFollowerIDs(ctx context.Context, userID, afterID uint, limit int) ([]uint, error)
+	CountFollowers(ctx context.Context, userID uint) (int64, error)
+	GetPopularFollowed(ctx context.Context, followerID uint, minFollowers int64) ([]uint, error)
+}
+
+type Roles interface {
+	GetByName(ctx context.Context, name string) (*Role, error)
 }
 
 func NewStorage(db *gorm.DB) Storage {
@@ -56,5 +195,15 @@ func NewStorage(db *gorm.DB) Storage {
 		Tags:      &TagStore{db: db},
 		Followers: &FollowerStore{db: db},
 		Roles:     &RoleStore{db: db},
+		RecoveryCodes: &RecoveryCodeStore{db: db},
+		AccessTokens:  &AccessTokenStore{db: db},
+		Identities:    &IdentityStore{db: db},
+		Exports:       &ExportStore{db: db},
+		Media:         &MediaStore{db: db},
+		Mentions:      &MentionStore{db: db},
+		Trending:      &TrendingStore{db: db},
+		TagFollowers:  &TagFollowerStore{db: db},
+		Bookmarks:     &BookmarkStore{db: db},
+		PostRevisions: &PostRevisionStore{db: db},
 	}
 }
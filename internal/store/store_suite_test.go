@@ -0,0 +1,72 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestStore(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Store Suite")
+}
+
+var testConn *gorm.DB
+
+// testDB returns a transaction on the migrated database in TEST_DB_ADDR
+// that is rolled back after the spec. Specs skip when it isn't set.
+//
+//	TEST_DB_ADDR=postgres://... go test ./internal/store
+func testDB() *gorm.DB {
+	addr := os.Getenv("TEST_DB_ADDR")
+	if addr == "" {
+		Skip("TEST_DB_ADDR is not set")
+	}
+
+	if testConn == nil {
+		conn, err := gorm.Open(postgres.Open(addr), &gorm.Config{
+			Logger:         logger.Default.LogMode(logger.Silent),
+			TranslateError: true,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		testConn = conn
+	}
+
+	tx := testConn.Begin()
+	Expect(tx.Error).NotTo(HaveOccurred())
+	DeferCleanup(func() { tx.Rollback() })
+	return tx
+}
+
+// seedUser inserts an active user with the lowest role.
+func seedUser(tx *gorm.DB, username string) *User {
+	var role Role
+	Expect(tx.Order("level").First(&role).Error).To(Succeed())
+
+	user := &User{
+		Email:    fmt.Sprintf("%s@example.com", username),
+		Username: username,
+		Password: []byte("-"),
+		IsActive: true,
+		RoleID:   role.ID,
+		Role:     role,
+	}
+	Expect(tx.Omit("Role").Create(user).Error).To(Succeed())
+	return user
+}
+
+// seedPost inserts a published post by user on tags.
+func seedPost(tx *gorm.DB, user *User, tags ...Tag) *Post {
+	post := &Post{Title: "title", Content: "content", UserID: user.ID, Status: "published"}
+	Expect(tx.Omit("User", "Tags").Create(post).Error).To(Succeed())
+	for _, tag := range tags {
+		Expect(tx.Exec("INSERT INTO post_tags (post_id, tag_id) VALUES (?, ?)", post.ID, tag.ID).Error).To(Succeed())
+	}
+	return post
+}
@@ -0,0 +1,105 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TagFollower is a user following a tag.
+type TagFollower struct {
+	TagID     uint      `gorm:"primaryKey" json:"tag_id"`
+	UserID    uint      `gorm:"primaryKey" json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type TagFollowerStore struct {
+	db *gorm.DB
+}
+
+func NewTagFollowerStore(db *gorm.DB) *TagFollowerStore {
+	return &TagFollowerStore{db: db}
+}
+
+// Follow returns ErrConflict if the user already follows the tag and
+// ErrNotFound if the tag doesn't exist.
+func (s *TagFollowerStore) Follow(ctx context.Context, userID, tagID uint) error {
+	err := s.db.WithContext(ctx).Create(&TagFollower{TagID: tagID, UserID: userID}).Error
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrDuplicatedKey):
+			return ErrConflict
+		case errors.Is(err, gorm.ErrForeignKeyViolated):
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// Unfollow returns ErrNotFound if the user wasn't following the tag.
+func (s *TagFollowerStore) Unfollow(ctx context.Context, userID, tagID uint) error {
+	tx := s.db.WithContext(ctx).
+		Where("tag_id = ? AND user_id = ?", tagID, userID).
+		Delete(&TagFollower{})
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetFollowedTags lists the tags a user follows, most recently followed
+// first.
+func (s *TagFollowerStore) GetFollowedTags(ctx context.Context, userID uint, fq PaginatedFeedQuery) ([]Tag, error) {
+	var tags []Tag
+
+	query := s.db.WithContext(ctx).
+		Scopes(withPostsCount).
+		Joins("JOIN tag_followers tf ON tf.tag_id = tags.id").
+		Where("tf.user_id = ?", userID)
+
+	if fq.Search != "" {
+		query = query.Where("tags.title ILIKE ?", "%"+fq.Search+"%")
+	}
+
+	if fq.Sort == "" {
+		fq.Sort = "desc"
+	}
+
+	err := query.
+		Order("tf.created_at " + fq.Sort).
+		Limit(fq.Limit).
+		Offset(fq.Offset).
+		Find(&tags).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// GetStats returns how many users follow a tag and whether userID is one
+// of them. Pass zero for an anonymous viewer.
+func (s *TagFollowerStore) GetStats(ctx context.Context, tagID, userID uint) (int64, bool, error) {
+	var stats struct {
+		Followers int64
+		Following bool
+	}
+
+	err := s.db.WithContext(ctx).
+		Model(&TagFollower{}).
+		Select("COUNT(*) AS followers, COALESCE(BOOL_OR(user_id = ?), false) AS following", userID).
+		Where("tag_id = ?", tagID).
+		Scan(&stats).Error
+	if err != nil {
+		return 0, false, err
+	}
+
+	return stats.Followers, stats.Following, nil
+}
@@ -2,19 +2,36 @@ package store
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/pangdfg/gopher-social/internal/richtext"
 )
 
 type Tag struct {
 	ID        uint           `gorm:"primaryKey;autoIncrement" json:"id"`
 	Title     string         `gorm:"size:255" json:"title"`
+	Name      string         `gorm:"size:200;uniqueIndex" json:"name"`
+	Description string       `json:"description"`
+	PostsCount  int64        `gorm:"->;-:migration" json:"posts_count"`
+	Aliases   []TagAlias     `gorm:"foreignKey:TagID" json:"aliases"`
 	Posts     []Post 		 `gorm:"many2many:post_tags;" json:"posts"`
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 }
 
+// TagAlias is another name for a tag. Posts tagged with it get the tag.
+type TagAlias struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	TagID     uint      `json:"tag_id"`
+	Name      string    `gorm:"size:200" json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
 type TagStore struct {
 	db *gorm.DB
 }
@@ -23,25 +40,38 @@ func NewTagStore(db *gorm.DB) *TagStore {
 	return &TagStore{db: db}
 }
 
+// Create returns the tag with tag.Name, creating it if needed. Name must
+// already be normalized; the title of an existing tag is kept, and an alias
+// resolves to its tag.
 func (s *TagStore) Create(ctx context.Context, tag *Tag) error {
-	var existing Tag
-	err := s.db.WithContext(ctx).
-		Where("title = ?", tag.Title).
-		First(&existing).Error
+	if tag.Name == "" {
+		return errors.New("tag name is required")
+	}
+
+	var alias TagAlias
+	err := s.db.WithContext(ctx).Where("name = ?", tag.Name).Limit(1).Find(&alias).Error
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return s.db.WithContext(ctx).Create(tag).Error
-		}
 		return err
 	}
-	tag.ID = existing.ID
+	if alias.ID != 0 {
+		return s.db.WithContext(ctx).First(tag, alias.TagID).Error
+	}
 
-	return nil
+	err = s.db.WithContext(ctx).
+		Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "name"}}, DoNothing: true}).
+		Create(tag).Error
+	if err != nil {
+		return err
+	}
+
+	return s.db.WithContext(ctx).Where("name = ?", tag.Name).First(tag).Error
 }
 
 func (s *TagStore) GetByID(ctx context.Context, id uint) (*Tag, error) {
 	tag := &Tag{}
 	err := s.db.WithContext(ctx).
+				Scopes(withPostsCount).
+				Preload("Aliases", func(db *gorm.DB) *gorm.DB { return db.Order("name") }).
 				First(tag, id).Error
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
@@ -56,7 +86,7 @@ func (s *TagStore) Get(ctx context.Context, fq PaginatedFeedQuery) ([]Tag , erro
 
 	var tags []Tag
 	
-	query := s.db.WithContext(ctx)
+	query := s.db.WithContext(ctx).Scopes(withPostsCount)
 
 	if fq.Search != "" {
 		query = query.Where("title ILIKE ?", "%"+fq.Search+"%")
@@ -87,6 +117,238 @@ func (s *TagStore) Get(ctx context.Context, fq PaginatedFeedQuery) ([]Tag , erro
 	return tags, nil
 }
 
+// GetByIDs loads tags in no particular order; missing IDs are skipped.
+func (s *TagStore) GetByIDs(ctx context.Context, ids []uint) ([]Tag, error) {
+	var tags []Tag
+	if len(ids) == 0 {
+		return tags, nil
+	}
+
+	if err := s.db.WithContext(ctx).Where("id IN ?", ids).Find(&tags).Error; err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// GetPostIDs lists the posts on a tag, trashed ones included, and the
+// reposts of them, which show the original's tags.
+func (s *TagStore) GetPostIDs(ctx context.Context, id uint) ([]uint, error) {
+	var ids []uint
+	err := s.db.WithContext(ctx).Raw(`
+		SELECT post_id FROM post_tags WHERE tag_id = ?
+		UNION
+		SELECT r.id FROM posts r JOIN post_tags pt ON pt.post_id = r.repost_of_id WHERE pt.tag_id = ?`,
+		id, id).Scan(&ids).Error
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// Update saves a tag's title, name and description. A new name must not
+// belong to another tag or be an alias; the old name becomes an alias so
+// posts written with it keep landing on the tag.
+func (s *TagStore) Update(ctx context.Context, tag *Tag) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var current Tag
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, tag.ID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrNotFound
+			}
+			return err
+		}
+
+		if tag.Name != current.Name {
+			// the tag may be taking back one of its own aliases
+			res := tx.Where("name = ? AND tag_id = ?", tag.Name, tag.ID).Delete(&TagAlias{})
+			if res.Error != nil {
+				return res.Error
+			}
+			if taken, err := aliasExists(tx, tag.Name); err != nil {
+				return err
+			} else if taken {
+				return ErrConflict
+			}
+		}
+
+		err := tx.Model(&Tag{}).Where("id = ?", tag.ID).Updates(map[string]interface{}{
+			"title":       tag.Title,
+			"name":        tag.Name,
+			"description": tag.Description,
+			"updated_at":  time.Now(),
+		}).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return ErrConflict
+			}
+			return err
+		}
+
+		if tag.Name != current.Name {
+			return tx.Create(&TagAlias{TagID: tag.ID, Name: current.Name}).Error
+		}
+		return nil
+	})
+}
+
+// Merge moves the posts, followers and aliases of tag sourceID onto
+// targetID and deletes the source, whose name becomes an alias of the
+// target.
+func (s *TagStore) Merge(ctx context.Context, sourceID, targetID uint) error {
+	if sourceID == targetID {
+		return ErrConflict
+	}
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var tags []Tag
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id IN ?", []uint{sourceID, targetID}).
+			Order("id").
+			Find(&tags).Error
+		if err != nil {
+			return err
+		}
+		if len(tags) != 2 {
+			return ErrNotFound
+		}
+
+		source := tags[0]
+		if source.ID != sourceID {
+			source = tags[1]
+		}
+
+		if err := moveTag(tx, sourceID, targetID); err != nil {
+			return err
+		}
+
+		return tx.Create(&TagAlias{TagID: targetID, Name: source.Name}).Error
+	})
+}
+
+// BackfillNames names the tags left without one by the migration that
+// added names, with richtext.NormalizeTag of their title, or "tag<id>" when
+// the title isn't a valid tag. A tag whose name is already taken by a tag or
+// an alias is merged into that tag. It returns how many tags it handled.
+func (s *TagStore) BackfillNames(ctx context.Context) (int, error) {
+	var done int
+	for {
+		var tags []Tag
+		err := s.db.WithContext(ctx).Where("name IS NULL").Order("id").Limit(100).Find(&tags).Error
+		if err != nil || len(tags) == 0 {
+			return done, err
+		}
+
+		for _, tag := range tags {
+			if err := s.backfillName(ctx, tag); err != nil {
+				return done, err
+			}
+			done++
+		}
+	}
+}
+
+func (s *TagStore) backfillName(ctx context.Context, tag Tag) error {
+	name, ok := richtext.NormalizeTag(tag.Title)
+	if !ok {
+		name = fmt.Sprintf("tag%d", tag.ID)
+	}
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var target Tag
+		if err := tx.Select("id").Where("name = ?", name).Limit(1).Find(&target).Error; err != nil {
+			return err
+		}
+		if target.ID == 0 {
+			var alias TagAlias
+			if err := tx.Where("name = ?", name).Limit(1).Find(&alias).Error; err != nil {
+				return err
+			}
+			target.ID = alias.TagID
+		}
+
+		if target.ID == 0 {
+			return tx.Model(&Tag{}).Where("id = ?", tag.ID).Update("name", name).Error
+		}
+		return moveTag(tx, tag.ID, target.ID)
+	})
+}
+
+// moveTag moves the posts, followers and aliases of tag sourceID onto
+// targetID and deletes the source.
+func moveTag(tx *gorm.DB, sourceID, targetID uint) error {
+	// posts on both tags keep the target row they already have
+	err := tx.Exec(`
+		INSERT INTO post_tags (post_id, tag_id, created_at)
+		SELECT post_id, ?, created_at FROM post_tags WHERE tag_id = ?
+		ON CONFLICT DO NOTHING`, targetID, sourceID).Error
+	if err != nil {
+		return err
+	}
+
+	err = tx.Exec(`
+		INSERT INTO tag_followers (tag_id, user_id, created_at)
+		SELECT ?, user_id, created_at FROM tag_followers WHERE tag_id = ?
+		ON CONFLICT DO NOTHING`, targetID, sourceID).Error
+	if err != nil {
+		return err
+	}
+
+	err = tx.Model(&TagAlias{}).Where("tag_id = ?", sourceID).Update("tag_id", targetID).Error
+	if err != nil {
+		return err
+	}
+
+	// post_tags and tag_followers rows of the source cascade
+	return tx.Delete(&Tag{}, sourceID).Error
+}
+
+// AddAlias returns ErrConflict if name is already a tag or an alias and
+// ErrNotFound if the tag doesn't exist.
+func (s *TagStore) AddAlias(ctx context.Context, alias *TagAlias) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&Tag{}).Where("name = ?", alias.Name).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrConflict
+		}
+
+		if err := tx.Create(alias).Error; err != nil {
+			switch {
+			case errors.Is(err, gorm.ErrDuplicatedKey):
+				return ErrConflict
+			case errors.Is(err, gorm.ErrForeignKeyViolated):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *TagStore) DeleteAlias(ctx context.Context, tagID, aliasID uint) error {
+	tx := s.db.WithContext(ctx).Where("id = ? AND tag_id = ?", aliasID, tagID).Delete(&TagAlias{})
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func aliasExists(tx *gorm.DB, name string) (bool, error) {
+	var count int64
+	err := tx.Model(&TagAlias{}).Where("name = ?", name).Count(&count).Error
+	return count > 0, err
+}
+
+// withPostsCount fills Tag.PostsCount.
+func withPostsCount(db *gorm.DB) *gorm.DB {
+	return db.Select("tags.*, (SELECT COUNT(*) FROM post_tags pt JOIN posts p ON p.id = pt.post_id WHERE pt.tag_id = tags.id AND p.status = 'published' AND p.deleted_at IS NULL) AS posts_count")
+}
 
 func (s *TagStore) Delete(ctx context.Context, tagId uint) error {
 	tx := s.db.WithContext(ctx).Delete(&Tag{}, tagId)
@@ -0,0 +1,183 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"gorm.io/gorm"
+)
+
+var _ = Describe("TagStore", func() {
+	var (
+		ctx  context.Context
+		tx   *gorm.DB
+		tags *TagStore
+		user *User
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		tx = testDB()
+		tags = &TagStore{db: tx}
+		user = seedUser(tx, "gopher")
+	})
+
+	create := func(name string) Tag {
+		tag := Tag{Title: name, Name: name}
+		Expect(tags.Create(ctx, &tag)).To(Succeed())
+		return tag
+	}
+
+	aliasNames := func(tagID uint) []string {
+		var names []string
+		Expect(tx.Model(&TagAlias{}).Where("tag_id = ?", tagID).Order("name").Pluck("name", &names).Error).To(Succeed())
+		return names
+	}
+
+	postTagIDs := func(postID uint) []uint {
+		var ids []uint
+		Expect(tx.Table("post_tags").Where("post_id = ?", postID).Order("tag_id").Pluck("tag_id", &ids).Error).To(Succeed())
+		return ids
+	}
+
+	Describe("Update", func() {
+		It("keeps the old name as an alias that resolves to the tag", func() {
+			tag := create("golang")
+			tag.Name, tag.Title = "go", "Go"
+			Expect(tags.Update(ctx, &tag)).To(Succeed())
+
+			Expect(aliasNames(tag.ID)).To(Equal([]string{"golang"}))
+
+			resolved := Tag{Title: "golang", Name: "golang"}
+			Expect(tags.Create(ctx, &resolved)).To(Succeed())
+			Expect(resolved.ID).To(Equal(tag.ID))
+			Expect(resolved.Name).To(Equal("go"))
+		})
+
+		It("lets a tag take back one of its own aliases", func() {
+			tag := create("golang")
+			tag.Name = "go"
+			Expect(tags.Update(ctx, &tag)).To(Succeed())
+
+			tag.Name = "golang"
+			Expect(tags.Update(ctx, &tag)).To(Succeed())
+			Expect(aliasNames(tag.ID)).To(Equal([]string{"go"}))
+		})
+
+		It("refuses a name that belongs to another tag or alias", func() {
+			other := create("rust")
+			Expect(tags.AddAlias(ctx, &TagAlias{TagID: other.ID, Name: "rustlang"})).To(Succeed())
+
+			tag := create("go")
+			tag.Name = "rust"
+			Expect(tags.Update(ctx, &tag)).To(MatchError(ErrConflict))
+			tag.Name = "rustlang"
+			Expect(tags.Update(ctx, &tag)).To(MatchError(ErrConflict))
+		})
+
+		It("returns ErrNotFound for a missing tag", func() {
+			Expect(tags.Update(ctx, &Tag{ID: 1 << 30, Name: "nope"})).To(MatchError(ErrNotFound))
+		})
+	})
+
+	Describe("Merge", func() {
+		var source, target Tag
+
+		BeforeEach(func() {
+			source = create("golang")
+			target = create("go")
+			Expect(tags.AddAlias(ctx, &TagAlias{TagID: source.ID, Name: "gopher"})).To(Succeed())
+		})
+
+		It("moves posts and aliases onto the target and aliases the source name", func() {
+			onSource := seedPost(tx, user, source)
+			onBoth := seedPost(tx, user, source, target)
+
+			Expect(tags.Merge(ctx, source.ID, target.ID)).To(Succeed())
+
+			Expect(postTagIDs(onSource.ID)).To(Equal([]uint{target.ID}))
+			Expect(postTagIDs(onBoth.ID)).To(Equal([]uint{target.ID}))
+			Expect(aliasNames(target.ID)).To(Equal([]string{"golang", "gopher"}))
+
+			_, err := tags.GetByID(ctx, source.ID)
+			Expect(err).To(MatchError(ErrNotFound))
+		})
+
+		It("changes nothing when a step fails", func() {
+			post := seedPost(tx, user, source)
+			// an alias that clashes with the source name makes the last
+			// step of the merge fail
+			Expect(tx.Exec("UPDATE tag_aliases SET name = ? WHERE tag_id = ?", "golang", source.ID).Error).To(Succeed())
+
+			Expect(tags.Merge(ctx, source.ID, target.ID)).NotTo(Succeed())
+
+			Expect(postTagIDs(post.ID)).To(Equal([]uint{source.ID}))
+			Expect(aliasNames(source.ID)).To(Equal([]string{"golang"}))
+			Expect(aliasNames(target.ID)).To(BeEmpty())
+		})
+
+		It("refuses to merge a tag into itself or a missing tag", func() {
+			Expect(tags.Merge(ctx, source.ID, source.ID)).To(MatchError(ErrConflict))
+			Expect(tags.Merge(ctx, source.ID, 1<<30)).To(MatchError(ErrNotFound))
+		})
+	})
+
+	Describe("GetPostIDs", func() {
+		It("lists the posts on a tag and the reposts of them", func() {
+			tag := create("go")
+			other := create("rust")
+			post := seedPost(tx, user, tag)
+			trashed := seedPost(tx, user, tag)
+			Expect(tx.Model(trashed).Update("deleted_at", time.Now()).Error).To(Succeed())
+			seedPost(tx, user, other)
+
+			repost := &Post{UserID: seedUser(tx, "reader").ID, Kind: PostKindRepost, RepostOfID: &post.ID}
+			Expect(tx.Omit("User", "Tags").Create(repost).Error).To(Succeed())
+
+			ids, err := tags.GetPostIDs(ctx, tag.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ids).To(ConsistOf(post.ID, trashed.ID, repost.ID))
+		})
+	})
+
+	Describe("BackfillNames", func() {
+		unnamed := func(title string) Tag {
+			var tag Tag
+			Expect(tx.Raw("INSERT INTO tags (title) VALUES (?) RETURNING *", title).Scan(&tag).Error).To(Succeed())
+			return tag
+		}
+
+		It("names tags like NormalizeTag and merges the ones that collide", func() {
+			first := unnamed("#ＧｏＬａｎｇ")
+			second := unnamed("golang")
+			odd := unnamed("not a tag")
+			post := seedPost(tx, user, second)
+
+			Expect(tags.BackfillNames(ctx)).To(Equal(3))
+
+			var named []Tag
+			Expect(tx.Order("id").Find(&named).Error).To(Succeed())
+			Expect(named).To(HaveLen(2))
+			Expect(named[0].ID).To(Equal(first.ID))
+			Expect(named[0].Name).To(Equal("golang"))
+			Expect(named[1].Name).To(Equal(fmt.Sprintf("tag%d", odd.ID)))
+			Expect(postTagIDs(post.ID)).To(ConsistOf(first.ID))
+		})
+
+		It("merges into a tag or alias that already has the name", func() {
+			existing := create("golang")
+			Expect(tags.AddAlias(ctx, &TagAlias{TagID: existing.ID, Name: "go"})).To(Succeed())
+			unnamed("GoLang")
+			unnamed("Go")
+
+			Expect(tags.BackfillNames(ctx)).To(Equal(2))
+
+			var left int64
+			Expect(tx.Model(&Tag{}).Count(&left).Error).To(Succeed())
+			Expect(left).To(Equal(int64(1)))
+		})
+	})
+})
@@ -0,0 +1,104 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Activity weights. A new post counts more than a comment on one. There
+// are no reactions yet; they would join the events below with a lower
+// weight still.
+const (
+	postActivityWeight    = 3.0
+	commentActivityWeight = 1.0
+)
+
+// TrendingQuery scores activity since Since. Each event's weight halves
+// every HalfLife, and items touched by fewer than MinAuthors distinct
+// users are left out so a single account can't push something up.
+type TrendingQuery struct {
+	Since      time.Time
+	Now        time.Time
+	HalfLife   time.Duration
+	MinAuthors int
+	Limit      int
+}
+
+// Scored is a post or tag ID with its decayed activity score.
+type Scored struct {
+	ID      uint    `json:"id"`
+	Score   float64 `json:"score"`
+	Authors int     `json:"authors"`
+}
+
+type TrendingStore struct {
+	db *gorm.DB
+}
+
+func NewTrendingStore(db *gorm.DB) *TrendingStore {
+	return &TrendingStore{db: db}
+}
+
+// trendingEvents is a post's creation, its reposts and the comments on it,
+// one row per event, in the window.
+const trendingEvents = `
+	WITH events AS (
+		SELECT COALESCE(p.repost_of_id, p.id) AS post_id, p.user_id, p.created_at, @post_weight::float8 AS weight
+		FROM posts p
+		WHERE p.created_at >= @since AND p.status = 'published' AND p.deleted_at IS NULL
+		UNION ALL
+		SELECT c.post_id, c.user_id, c.created_at, @comment_weight::float8
+		FROM comments c
+		JOIN posts cp ON cp.id = c.post_id AND cp.status = 'published' AND cp.deleted_at IS NULL
+		WHERE c.created_at >= @since AND c.deleted_at IS NULL
+	)`
+
+const trendingScore = `SUM(e.weight * EXP(-LN(2) * GREATEST(EXTRACT(EPOCH FROM (@now::timestamptz - e.created_at)), 0) / @half_life))`
+
+// Posts returns the highest scoring posts.
+func (s *TrendingStore) Posts(ctx context.Context, q TrendingQuery) ([]Scored, error) {
+	var out []Scored
+	err := s.db.WithContext(ctx).Raw(trendingEvents+`
+		SELECT e.post_id AS id, `+trendingScore+` AS score, COUNT(DISTINCT e.user_id) AS authors
+		FROM events e
+		GROUP BY e.post_id
+		HAVING COUNT(DISTINCT e.user_id) >= @min_authors
+		ORDER BY score DESC, e.post_id DESC
+		LIMIT @limit`, q.args()).Scan(&out).Error
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Tags returns the highest scoring tags; a tag scores the activity on the
+// posts it is on.
+func (s *TrendingStore) Tags(ctx context.Context, q TrendingQuery) ([]Scored, error) {
+	var out []Scored
+	err := s.db.WithContext(ctx).Raw(trendingEvents+`
+		SELECT pt.tag_id AS id, `+trendingScore+` AS score, COUNT(DISTINCT e.user_id) AS authors
+		FROM events e
+		JOIN post_tags pt ON pt.post_id = e.post_id
+		GROUP BY pt.tag_id
+		HAVING COUNT(DISTINCT e.user_id) >= @min_authors
+		ORDER BY score DESC, pt.tag_id DESC
+		LIMIT @limit`, q.args()).Scan(&out).Error
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (q TrendingQuery) args() map[string]any {
+	return map[string]any{
+		"since":          q.Since,
+		"now":            q.Now,
+		"half_life":      q.HalfLife.Seconds(),
+		"min_authors":    q.MinAuthors,
+		"limit":          q.Limit,
+		"post_weight":    postActivityWeight,
+		"comment_weight": commentActivityWeight,
+	}
+}
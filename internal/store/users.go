@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/lib/pq"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
@@ -14,6 +15,7 @@ var (
 	ErrDuplicateEmail    = errors.New("a user with that email already exists")
 	ErrDuplicateUsername = errors.New("a user with that username already exists")
 	ErrNotFound          = errors.New("not found")
+	ErrTooManyAttempts   = errors.New("too many failed attempts")
 )
 
 // User model
@@ -23,6 +25,19 @@ type User struct {
 	Username  string    `gorm:"uniqueIndex;size:255" json:"username"`
 	Password []byte `gorm:"column:password;not null" json:"-"`
 	IsActive  bool      `gorm:"default:false" json:"is_active"`
+	TOTPSecret   string `gorm:"column:totp_secret" json:"-"`
+	TOTPEnabled  bool   `gorm:"column:totp_enabled;default:false" json:"totp_enabled"`
+	TOTPLastStep *int64 `gorm:"column:totp_last_step" json:"-"`
+	DeletionRequestedAt *time.Time `json:"deletion_requested_at"`
+	DisplayName string         `gorm:"size:100" json:"display_name"`
+	Bio         string         `json:"bio"`
+	Location    string         `gorm:"size:100" json:"location"`
+	Links       pq.StringArray `gorm:"column:website_links;type:text[]" json:"links"`
+	AvatarURL   string         `gorm:"column:avatar_url" json:"avatar_url"`
+	BannerURL   string         `gorm:"column:banner_url" json:"banner_url"`
+	Language    string         `gorm:"size:35" json:"language"`
+	// Version is bumped by profile and username changes
+	Version     int            `gorm:"default:1" json:"version"`
 	RoleID    uint 
 	Role   	  Role    `gorm:"foreignKey:RoleID;references:ID"`
 	CreatedAt time.Time
@@ -104,6 +119,11 @@ func (s *UserStore) GetByID(ctx context.Context, userID uint) (*User, error) {
 	return user, nil
 }
 
+// GetCredentials is GetByID for checking a password or 2FA code. It is
+// never served from a cache, as cached users leave the secrets out.
+func (s *UserStore) GetCredentials(ctx context.Context, userID uint) (*User, error) {
+	return s.GetByID(ctx, userID)
+}
 
 func (s *UserStore) GetByEmail(ctx context.Context, email string) (*User, error) {
 	user := &User{}
@@ -116,13 +136,99 @@ func (s *UserStore) GetByEmail(ctx context.Context, email string) (*User, error)
 	return user, nil
 }
 
+// GetByUsernames returns the active users among usernames, skipping
+// accounts pending deletion.
+func (s *UserStore) GetByUsernames(ctx context.Context, usernames []string) ([]User, error) {
+	var users []User
+	if len(usernames) == 0 {
+		return users, nil
+	}
+
+	err := s.db.WithContext(ctx).
+		Where("username IN ? AND is_active = ? AND deletion_requested_at IS NULL", usernames, true).
+		Find(&users).Error
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
 
 func (s *UserStore) Activate(ctx context.Context, userID uint) error {
 	return s.db.Model(&User{}).Where("id = ? ", userID).Update("is_active", true).Error
 }
 
+// Delete removes the user together with everything that references it.
+// Tables with ON DELETE CASCADE (post_tags, tokens, identities...) follow
+// along; comments and posts have to be cleared first.
 func (s *UserStore) Delete(ctx context.Context, userID uint) error {
-	tx := s.db.Delete(&User{}, userID)
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		owned := tx.Unscoped().Model(&Post{}).Select("id").Where("user_id = ?", userID)
+		// other users' reposts of these posts cascade away with them, like in
+		// PostStore.PurgeDeleted; quotes outlive the original and keep theirs
+		posts := tx.Unscoped().Model(&Post{}).Select("id").Where("id IN (?) OR repost_of_id IN (?)", owned, owned)
+
+		if err := tx.Unscoped().Where("user_id = ? OR post_id IN (?)", userID, posts).Delete(&Comment{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ? OR follower_id = ?", userID, userID).Delete(&Follower{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&Post{}).Error; err != nil {
+			return err
+		}
+
+		res := tx.Delete(&User{}, userID)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
+
+// GetPostIDs lists the posts that show the user, trashed ones included:
+// their own, the ones they commented on or are mentioned in, and the
+// reposts and quotes of all of these.
+func (s *UserStore) GetPostIDs(ctx context.Context, userID uint) ([]uint, error) {
+	db := s.db.WithContext(ctx)
+
+	commentMentions := db.Model(&Mention{}).Select("comment_id").Where("user_id = ? AND comment_id IS NOT NULL", userID)
+	comments := db.Unscoped().Model(&Comment{}).Select("post_id").Where("user_id = ? OR id IN (?)", userID, commentMentions)
+	postMentions := db.Model(&Mention{}).Select("post_id").Where("user_id = ? AND post_id IS NOT NULL", userID)
+	shown := db.Unscoped().Model(&Post{}).Select("id").
+		Where("user_id = ? OR id IN (?) OR id IN (?)", userID, comments, postMentions)
+
+	var ids []uint
+	err := db.Unscoped().
+		Model(&Post{}).
+		Where("id IN (?) OR repost_of_id IN (?) OR quote_of_id IN (?)", shown, shown, shown).
+		Order("id").
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+// RequestDeletion schedules the account for deletion; it is purged once
+// the grace period after at has passed unless cancelled.
+func (s *UserStore) RequestDeletion(ctx context.Context, userID uint, at time.Time) error {
+	tx := s.db.WithContext(ctx).Model(&User{}).
+		Where("id = ? AND deletion_requested_at IS NULL", userID).
+		Update("deletion_requested_at", at)
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+func (s *UserStore) CancelDeletion(ctx context.Context, userID uint) error {
+	tx := s.db.WithContext(ctx).Model(&User{}).
+		Where("id = ? AND deletion_requested_at IS NOT NULL", userID).
+		Update("deletion_requested_at", nil)
 	if tx.Error != nil {
 		return tx.Error
 	}
@@ -132,9 +238,24 @@ func (s *UserStore) Delete(ctx context.Context, userID uint) error {
 	return nil
 }
 
+// GetDueForDeletion returns users whose deletion was requested before cutoff.
+func (s *UserStore) GetDueForDeletion(ctx context.Context, cutoff time.Time, limit int) ([]uint, error) {
+	var ids []uint
+	err := s.db.WithContext(ctx).Model(&User{}).
+		Where("deletion_requested_at IS NOT NULL AND deletion_requested_at <= ?", cutoff).
+		Order("deletion_requested_at asc").
+		Limit(limit).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
 func (s *UserStore) UpdateUsername(ctx context.Context, user *User) error {
 	tx := s.db.Model(&User{}).Where("id = ? AND is_active = ?", user.ID, true).Updates(map[string]interface{}{
 		"Username": user.Username,
+		"version":  gorm.Expr("version + 1"),
 	})
 	if tx.Error != nil {
 		if errors.Is(tx.Error, gorm.ErrRecordNotFound) {
@@ -148,6 +269,50 @@ func (s *UserStore) UpdateUsername(ctx context.Context, user *User) error {
 	return nil
 }
 
+func (s *UserStore) UpdateEmail(ctx context.Context, user *User) error {
+	tx := s.db.WithContext(ctx).Model(&User{}).Where("id = ? AND is_active = ?", user.ID, true).Updates(map[string]interface{}{
+		"Email": user.Email,
+	})
+	if tx.Error != nil {
+		if errors.Is(tx.Error, gorm.ErrDuplicatedKey) {
+			return ErrDuplicateEmail
+		}
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// UpdateProfile saves the user's public profile fields if the user is
+// still at user.Version, and returns ErrConflict if not.
+func (s *UserStore) UpdateProfile(ctx context.Context, user *User) error {
+	if user.Links == nil {
+		user.Links = pq.StringArray{}
+	}
+
+	tx := s.db.WithContext(ctx).Model(&User{}).Where("id = ? AND is_active = ? AND version = ?", user.ID, true, user.Version).Updates(map[string]interface{}{
+		"display_name":  user.DisplayName,
+		"bio":           user.Bio,
+		"location":      user.Location,
+		"website_links": user.Links,
+		"avatar_url":    user.AvatarURL,
+		"banner_url":    user.BannerURL,
+		"language":      user.Language,
+		"version":       user.Version + 1,
+	})
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return ErrConflict
+	}
+
+	user.Version++
+	return nil
+}
+
 func (s *UserStore) UpdatePassword(ctx context.Context, user *User, plain string) error {
 	var p password
 	if err := p.Set(plain); err != nil {
@@ -165,4 +330,100 @@ func (s *UserStore) UpdatePassword(ctx context.Context, user *User, plain string
 		return tx.Error
 	}
 	return nil
-}
\ No newline at end of file
+}
+
+// SetTOTPSecret stores a pending secret; 2FA stays off until EnableTOTP.
+func (s *UserStore) SetTOTPSecret(ctx context.Context, userID uint, secret string) error {
+	tx := s.db.WithContext(ctx).Model(&User{}).
+		Where("id = ? AND is_active = ?", userID, true).
+		Updates(map[string]interface{}{
+			"totp_secret":    secret,
+			"totp_enabled":   false,
+			"totp_last_step": nil,
+			"totp_failures":  0,
+			"totp_failed_at": nil,
+		})
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *UserStore) EnableTOTP(ctx context.Context, userID uint) error {
+	tx := s.db.WithContext(ctx).Model(&User{}).
+		Where("id = ? AND totp_secret IS NOT NULL", userID).
+		Update("totp_enabled", true)
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DisableTOTP clears the secret and drops every recovery code.
+func (s *UserStore) DisableTOTP(ctx context.Context, userID uint) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"totp_secret":    nil,
+			"totp_enabled":   false,
+			"totp_last_step": nil,
+			"totp_failures":  0,
+			"totp_failed_at": nil,
+		}).Error
+	})
+}
+
+// ReserveTOTPAttempt counts a second-factor attempt against the user before
+// the code is checked, so parallel guesses can't slip past the limit. Once
+// limit attempts failed within window it returns ErrTooManyAttempts until
+// window has passed since the last one; ResetTOTPFailures clears the count.
+func (s *UserStore) ReserveTOTPAttempt(ctx context.Context, userID uint, limit int, window time.Duration) error {
+	now := time.Now()
+	cutoff := now.Add(-window)
+
+	tx := s.db.WithContext(ctx).Model(&User{}).
+		Where("id = ? AND NOT (totp_failures >= ? AND COALESCE(totp_failed_at > ?, false))", userID, limit, cutoff).
+		Updates(map[string]interface{}{
+			"totp_failures":  gorm.Expr("CASE WHEN totp_failed_at IS NULL OR totp_failed_at <= ? THEN 1 ELSE totp_failures + 1 END", cutoff),
+			"totp_failed_at": now,
+		})
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return ErrTooManyAttempts
+	}
+	return nil
+}
+
+func (s *UserStore) ResetTOTPFailures(ctx context.Context, userID uint) error {
+	return s.db.WithContext(ctx).Model(&User{}).
+		Where("id = ?", userID).
+		Updates(map[string]interface{}{
+			"totp_failures":  0,
+			"totp_failed_at": nil,
+		}).Error
+}
+
+// ConsumeTOTPStep records step as used and returns ErrConflict when the
+// same or a later step was already accepted, so a code can't be replayed.
+func (s *UserStore) ConsumeTOTPStep(ctx context.Context, userID uint, step int64) error {
+	tx := s.db.WithContext(ctx).Model(&User{}).
+		Where("id = ? AND (totp_last_step IS NULL OR totp_last_step < ?)", userID, step).
+		Update("totp_last_step", step)
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return ErrConflict
+	}
+	return nil
+}
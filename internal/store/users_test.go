@@ -0,0 +1,94 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"gorm.io/gorm"
+)
+
+var _ = Describe("UserStore", func() {
+	var (
+		ctx   context.Context
+		tx    *gorm.DB
+		users *UserStore
+		user  *User
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		tx = testDB()
+		users = &UserStore{db: tx}
+		user = seedUser(tx, "gopher")
+	})
+
+	Describe("ConsumeTOTPStep", func() {
+		It("refuses a step that was already used", func() {
+			Expect(users.ConsumeTOTPStep(ctx, user.ID, 100)).To(Succeed())
+			Expect(users.ConsumeTOTPStep(ctx, user.ID, 100)).To(MatchError(ErrConflict))
+		})
+
+		It("refuses steps older than the last one used", func() {
+			Expect(users.ConsumeTOTPStep(ctx, user.ID, 100)).To(Succeed())
+			Expect(users.ConsumeTOTPStep(ctx, user.ID, 99)).To(MatchError(ErrConflict))
+			Expect(users.ConsumeTOTPStep(ctx, user.ID, 101)).To(Succeed())
+		})
+	})
+
+	Describe("ReserveTOTPAttempt", func() {
+		It("refuses attempts past the limit until a reset", func() {
+			for range 3 {
+				Expect(users.ReserveTOTPAttempt(ctx, user.ID, 3, time.Minute)).To(Succeed())
+			}
+			Expect(users.ReserveTOTPAttempt(ctx, user.ID, 3, time.Minute)).To(MatchError(ErrTooManyAttempts))
+
+			Expect(users.ResetTOTPFailures(ctx, user.ID)).To(Succeed())
+			Expect(users.ReserveTOTPAttempt(ctx, user.ID, 3, time.Minute)).To(Succeed())
+		})
+
+		It("forgets failures older than the window", func() {
+			for range 3 {
+				Expect(users.ReserveTOTPAttempt(ctx, user.ID, 3, time.Minute)).To(Succeed())
+			}
+
+			Expect(users.ReserveTOTPAttempt(ctx, user.ID, 3, -time.Second)).To(Succeed())
+		})
+	})
+
+	Describe("GetPostIDs", func() {
+		It("lists the posts that show the user and shares of them", func() {
+			other := seedUser(tx, "other")
+			own := seedPost(tx, user)
+			commented := seedPost(tx, other)
+			mentioned := seedPost(tx, other)
+			unrelated := seedPost(tx, other)
+			Expect(tx.Omit("Post", "User").Create(&Comment{PostID: commented.ID, UserID: user.ID, Content: "hi"}).Error).To(Succeed())
+			Expect(tx.Omit("User").Create(&Mention{UserID: user.ID, PostID: &mentioned.ID}).Error).To(Succeed())
+			repost, err := (&PostStore{db: tx}).Repost(ctx, other.ID, own.ID)
+			Expect(err).NotTo(HaveOccurred())
+
+			ids, err := users.GetPostIDs(ctx, user.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ids).To(ConsistOf(own.ID, commented.ID, mentioned.ID, repost.ID))
+			Expect(ids).NotTo(ContainElement(unrelated.ID))
+		})
+	})
+
+	Describe("Delete", func() {
+		It("drops comments on other users' reposts of the user's posts", func() {
+			other := seedUser(tx, "other")
+			post := seedPost(tx, user)
+			repost, err := (&PostStore{db: tx}).Repost(ctx, other.ID, post.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tx.Omit("Post", "User").Create(&Comment{PostID: repost.ID, UserID: other.ID, Content: "nice"}).Error).To(Succeed())
+
+			Expect(users.Delete(ctx, user.ID)).To(Succeed())
+
+			var left int64
+			Expect(tx.Unscoped().Model(&Comment{}).Where("post_id = ?", repost.ID).Count(&left).Error).To(Succeed())
+			Expect(left).To(BeZero())
+		})
+	})
+})